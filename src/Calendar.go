@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"io/ioutil"
-	"net/http"
 	"time"
 )
 
@@ -34,16 +33,9 @@ type CalNames []struct {
 // GetCalendarNames returns the names and colours of the configured calendars
 func GetCalendarNames() (CalNames, error) {
 	c := CalNames{}
-	resp, err := http.Get("http://localhost:20513/calendar/get")
-	if resp != nil {
-		defer resp.Body.Close()
-		resp.Close = true
-	}
+	b, err := GetHTTPClient().Get("http://localhost:20513/calendar/get")
 	if err == nil {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			err = json.Unmarshal(b, &c)
-		}
+		err = json.Unmarshal(b, &c)
 	}
 
 	if err == nil {
@@ -56,16 +48,9 @@ func GetCalendarNames() (CalNames, error) {
 // GetCalendarEvents returns the calendar events for the next 4 days
 func GetCalendarEvents() (CalEvents, error) {
 	c := CalEvents{}
-	resp, err := http.Get("http://localhost:20513/calendar/get/4")
-	if resp != nil {
-		defer resp.Body.Close()
-		resp.Close = true
-	}
+	b, err := GetHTTPClient().Get("http://localhost:20513/calendar/get/4")
 	if err == nil {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			err = json.Unmarshal(b, &c)
-		}
+		err = json.Unmarshal(b, &c)
 	}
 
 	if err == nil {
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// Severities of an alert, as used by the Common Alerting Protocol
+const (
+	SeverityExtreme  = "Extreme"
+	SeveritySevere   = "Severe"
+	SeverityModerate = "Moderate"
+	SeverityMinor    = "Minor"
+	SeverityUnknown  = "Unknown"
+)
+
+// alertBannerHeight is the height of the alert banner in pixels
+const alertBannerHeight = 44
+
+// severityRanks orders the severities from the most to the least severe
+var severityRanks = map[string]int{
+	SeverityExtreme:  0,
+	SeveritySevere:   1,
+	SeverityModerate: 2,
+	SeverityMinor:    3,
+	SeverityUnknown:  4,
+}
+
+// severityColours holds the colour of the banner for each severity
+var severityColours = map[string]color.NRGBA{
+	SeverityExtreme:  {183, 28, 28, 240},
+	SeveritySevere:   {230, 81, 0, 240},
+	SeverityModerate: {255, 179, 0, 240},
+	SeverityMinor:    {253, 216, 53, 240},
+	SeverityUnknown:  {84, 110, 122, 240},
+}
+
+// Alert holds a severe weather alert
+type Alert struct {
+	ID          string    `json:"id"`
+	Event       string    `json:"event"`
+	Headline    string    `json:"headline"`
+	Severity    string    `json:"severity"` // Extreme, Severe, Moderate, Minor or Unknown
+	Onset       time.Time `json:"onset"`
+	Expires     time.Time `json:"expires"`
+	Sender      string    `json:"sender"`
+	Area        string    `json:"area"`
+	Description string    `json:"description"`
+}
+
+// WeatherAlert holds an alert sent by the weather service, in the format of the OpenWeatherMap One Call API
+type WeatherAlert struct {
+	SenderName  string   `json:"sender_name"`
+	Event       string   `json:"event"`
+	Start       int64    `json:"start"`
+	End         int64    `json:"end"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+// capInfo holds the details of a CAP alert in a language
+type capInfo struct {
+	Language    string `xml:"language"`
+	Event       string `xml:"event"`
+	Severity    string `xml:"severity"`
+	Effective   string `xml:"effective"`
+	Onset       string `xml:"onset"`
+	Expires     string `xml:"expires"`
+	SenderName  string `xml:"senderName"`
+	Headline    string `xml:"headline"`
+	Description string `xml:"description"`
+	Areas       []struct {
+		AreaDesc string `xml:"areaDesc"`
+	} `xml:"area"`
+}
+
+// capAlert holds a Common Alerting Protocol (CAP) alert message
+type capAlert struct {
+	XMLName    xml.Name
+	Identifier string    `xml:"identifier"`
+	Sender     string    `xml:"sender"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	Infos      []capInfo `xml:"info"`
+}
+
+// capFeedEntry holds an entry of an Atom feed of CAP alerts, with the main CAP fields in the entry
+type capFeedEntry struct {
+	ID        string `xml:"id"`
+	Title     string `xml:"title"`
+	Summary   string `xml:"summary"`
+	Event     string `xml:"event"`
+	Severity  string `xml:"severity"`
+	Status    string `xml:"status"`
+	MsgType   string `xml:"msgType"`
+	Effective string `xml:"effective"`
+	Onset     string `xml:"onset"`
+	Expires   string `xml:"expires"`
+	AreaDesc  string `xml:"areaDesc"`
+	Links     []struct {
+		Href string `xml:"href,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+}
+
+// capFeed holds an Atom feed of CAP alerts
+type capFeed struct {
+	Entries []capFeedEntry `xml:"entry"`
+}
+
+// metAlerts holds the current alerts from the Met Norway MetAlerts API
+type metAlerts struct {
+	Features []struct {
+		Properties struct {
+			ID          string `json:"id"`
+			Event       string `json:"eventAwarenessName"`
+			Title       string `json:"title"`
+			Severity    string `json:"severity"`
+			Area        string `json:"area"`
+			Description string `json:"description"`
+		} `json:"properties"`
+		When struct {
+			Interval []string `json:"interval"`
+		} `json:"when"`
+	} `json:"features"`
+}
+
+// owmAlerts holds the alerts from the OpenWeatherMap One Call API
+type owmAlerts struct {
+	Alerts []WeatherAlert `json:"alerts"`
+}
+
+// GetAlerts returns the active alerts from the alert feeds and the weather service,
+// with the most severe first
+func GetAlerts(c Config, w Weather) ([]Alert, error) {
+	l := []Alert{}
+	for _, a := range w.Alerts {
+		l = append(l, a.toAlert())
+	}
+	var lerr error
+	for _, u := range c.AlertFeeds {
+		al, err := getAlertFeed(u, c.GetLocale())
+		if err != nil {
+			lerr = fmt.Errorf("Error getting alerts from %s. %s", u, err.Error())
+			continue
+		}
+		l = append(l, al...)
+	}
+	return activeAlerts(l, time.Now()), lerr
+}
+
+// getAlertFeed returns the alerts from the url, which can be a CAP alert, an Atom feed of CAP alerts,
+// the Met Norway MetAlerts API or the OpenWeatherMap One Call API
+func getAlertFeed(u string, loc Locale) ([]Alert, error) {
+	b, err := GetHTTPClient().Get(u)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) != 0 && b[0] == '{' {
+		return parseAlertJSON(b)
+	}
+	l, links, err := parseCAP(b, loc)
+	if err != nil {
+		return nil, err
+	}
+	// Some feeds only link to the CAP alert of each entry
+	for _, cu := range links {
+		cb, err := GetHTTPClient().Get(cu)
+		if err != nil {
+			return nil, err
+		}
+		cl, _, err := parseCAP(cb, loc)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, cl...)
+	}
+	return l, nil
+}
+
+// parseCAP returns the alerts from a CAP alert or an Atom feed of CAP alerts.
+// The links to the CAP alerts of the feed entries that do not hold the alert details are also returned.
+func parseCAP(b []byte, loc Locale) ([]Alert, []string, error) {
+	root := struct{ XMLName xml.Name }{}
+	if err := xml.Unmarshal(b, &root); err != nil {
+		return nil, nil, err
+	}
+
+	switch root.XMLName.Local {
+	case "alert":
+		ca := capAlert{}
+		if err := xml.Unmarshal(b, &ca); err != nil {
+			return nil, nil, err
+		}
+		if a, ok := ca.toAlert(loc); ok {
+			return []Alert{a}, nil, nil
+		}
+		return []Alert{}, nil, nil
+	case "feed":
+		cf := capFeed{}
+		if err := xml.Unmarshal(b, &cf); err != nil {
+			return nil, nil, err
+		}
+		l := []Alert{}
+		links := []string{}
+		for _, e := range cf.Entries {
+			if e.Event == "" {
+				for _, k := range e.Links {
+					if strings.Contains(k.Type, "cap") {
+						links = append(links, k.Href)
+						break
+					}
+				}
+				continue
+			}
+			if !isActualAlert(e.Status, e.MsgType) {
+				continue
+			}
+			a := Alert{
+				ID:          e.ID,
+				Event:       e.Event,
+				Headline:    strings.TrimSpace(e.Title),
+				Severity:    getSeverity(e.Severity),
+				Onset:       parseAlertTime(e.Onset, e.Effective),
+				Expires:     parseAlertTime(e.Expires),
+				Area:        strings.TrimSpace(e.AreaDesc),
+				Description: strings.TrimSpace(e.Summary),
+			}
+			l = append(l, a)
+		}
+		return l, links, nil
+	}
+	return nil, nil, fmt.Errorf("Unexpected CAP element '%s'", root.XMLName.Local)
+}
+
+// parseAlertJSON returns the alerts from the Met Norway MetAlerts or the OpenWeatherMap One Call API
+func parseAlertJSON(b []byte) ([]Alert, error) {
+	l := []Alert{}
+	if bytes.Contains(b, []byte(`"features"`)) {
+		ma := metAlerts{}
+		if err := json.Unmarshal(b, &ma); err != nil {
+			return nil, err
+		}
+		for _, f := range ma.Features {
+			p := f.Properties
+			a := Alert{
+				ID:          p.ID,
+				Event:       p.Event,
+				Headline:    p.Title,
+				Severity:    getSeverity(p.Severity),
+				Area:        p.Area,
+				Description: p.Description,
+				Sender:      "MET Norway",
+			}
+			if len(f.When.Interval) == 2 {
+				a.Onset = parseAlertTime(f.When.Interval[0])
+				a.Expires = parseAlertTime(f.When.Interval[1])
+			}
+			l = append(l, a)
+		}
+		return l, nil
+	}
+
+	oa := owmAlerts{}
+	if err := json.Unmarshal(b, &oa); err != nil {
+		return nil, err
+	}
+	for _, a := range oa.Alerts {
+		l = append(l, a.toAlert())
+	}
+	return l, nil
+}
+
+// toAlert returns the alert from the info in the language of the locale, or English, or the first info
+func (ca capAlert) toAlert(loc Locale) (Alert, bool) {
+	if !isActualAlert(ca.Status, ca.MsgType) || len(ca.Infos) == 0 {
+		return Alert{}, false
+	}
+	in := ca.Infos[0]
+	for _, lang := range []string{loc.Code, defaultLocale} {
+		found := false
+		for _, i := range ca.Infos {
+			if strings.HasPrefix(strings.ToLower(i.Language), lang) {
+				in = i
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+
+	a := Alert{
+		ID:          ca.Identifier,
+		Event:       strings.TrimSpace(in.Event),
+		Headline:    strings.TrimSpace(in.Headline),
+		Severity:    getSeverity(in.Severity),
+		Onset:       parseAlertTime(in.Onset, in.Effective, ca.Sent),
+		Expires:     parseAlertTime(in.Expires),
+		Sender:      strings.TrimSpace(in.SenderName),
+		Description: strings.TrimSpace(in.Description),
+	}
+	if a.Sender == "" {
+		a.Sender = ca.Sender
+	}
+	al := []string{}
+	for _, ar := range in.Areas {
+		if s := strings.TrimSpace(ar.AreaDesc); s != "" {
+			al = append(al, s)
+		}
+	}
+	a.Area = strings.Join(al, ", ")
+	return a, true
+}
+
+// toAlert returns the alert sent by the weather service
+func (w WeatherAlert) toAlert() Alert {
+	a := Alert{
+		ID:          fmt.Sprintf("%s|%s|%d", w.SenderName, w.Event, w.Start),
+		Event:       w.Event,
+		Headline:    w.Event,
+		Severity:    SeverityUnknown,
+		Sender:      w.SenderName,
+		Description: w.Description,
+	}
+	if w.Start > 0 {
+		a.Onset = time.Unix(w.Start, 0)
+	}
+	if w.End > 0 {
+		a.Expires = time.Unix(w.End, 0)
+	}
+	return a
+}
+
+// isActualAlert returns whether the CAP message is a live alert, and not a test, exercise or cancellation
+func isActualAlert(status string, msgType string) bool {
+	return (status == "" || strings.EqualFold(status, "Actual")) && !strings.EqualFold(msgType, "Cancel")
+}
+
+// getSeverity returns the CAP severity of the value
+func getSeverity(s string) string {
+	for k := range severityRanks {
+		if strings.EqualFold(strings.TrimSpace(s), k) {
+			return k
+		}
+	}
+	return SeverityUnknown
+}
+
+// parseAlertTime returns the first of the times that can be parsed
+func parseAlertTime(v ...string) time.Time {
+	for _, s := range v {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(s)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// activeAlerts returns the alerts that have not expired, without duplicates, with the most severe first
+func activeAlerts(l []Alert, now time.Time) []Alert {
+	al := []Alert{}
+	seen := map[string]bool{}
+	for _, a := range l {
+		if !a.Expires.IsZero() && !a.Expires.After(now) {
+			continue
+		}
+		k := a.GetKey()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		al = append(al, a)
+	}
+	sort.SliceStable(al, func(i, j int) bool {
+		if severityRanks[al[i].Severity] != severityRanks[al[j].Severity] {
+			return severityRanks[al[i].Severity] < severityRanks[al[j].Severity]
+		}
+		return al[i].Onset.Before(al[j].Onset)
+	})
+	return al
+}
+
+// hasNewAlerts returns whether there are alerts in the list that are not in the old list
+func hasNewAlerts(old []Alert, l []Alert) bool {
+	seen := map[string]bool{}
+	for _, a := range old {
+		seen[a.GetKey()] = true
+	}
+	for _, a := range l {
+		if !seen[a.GetKey()] {
+			return true
+		}
+	}
+	return false
+}
+
+// GetKey returns the key identifying the alert
+func (a Alert) GetKey() string {
+	if a.ID != "" {
+		return a.ID
+	}
+	return a.Event + "|" + a.Onset.Format(time.RFC3339)
+}
+
+// GetHeadline returns the headline of the alert, or the event if there is no headline
+func (a Alert) GetHeadline() string {
+	if a.Headline != "" {
+		return a.Headline
+	}
+	return a.Event
+}
+
+// GetValidity returns when the alert is valid, e.g. Mon 14:00 - Tue 06:00
+func (a Alert) GetValidity(loc Locale, now time.Time) string {
+	f := func(t time.Time) string {
+		t = t.In(time.Local)
+		return loc.ShortDayName(t) + " " + loc.FormatTime(t)
+	}
+	switch {
+	case a.Expires.IsZero() && a.Onset.After(now):
+		return loc.T("from") + " " + f(a.Onset)
+	case a.Expires.IsZero():
+		return ""
+	case a.Onset.IsZero() || !a.Onset.After(now):
+		return loc.T("until") + " " + f(a.Expires)
+	}
+	return f(a.Onset) + " - " + f(a.Expires)
+}
+
+// AlertWatcher checks for new alerts between the scheduled display builds,
+// so that the display is rebuilt as soon as a new alert is issued.
+// The alert feeds are checked each time, the weather service alerts are taken from the forecast
+// of the last build, so they only change when the display is built.
+// The interval is read when the schedule starts, so a change takes effect after a restart.
+type AlertWatcher struct {
+	Srv     *Server
+	last    []Alert     // Alerts the watcher last acted on, nil until the first check
+	rebuild func() bool // Starts a display build, Display.TryRun if nil
+}
+
+// Run is called from the scheduler (ClockWerk).
+func (a *AlertWatcher) Run() {
+	c := a.Srv.Config
+	d := &a.Srv.Display
+	if !c.Alerts {
+		return
+	}
+	w := Weather{}
+	if c.Weather {
+		w = d.GetWeather()
+	}
+	l, err := GetAlerts(*c, w)
+	if err != nil {
+		a.logError(err.Error())
+	}
+	if a.last == nil {
+		a.last = d.GetAlerts()
+	}
+	if !hasNewAlerts(a.last, l) {
+		if err == nil {
+			a.last = l
+		}
+		return
+	}
+	a.logInfo("New alert issued, rebuilding the display.")
+	rebuild := a.rebuild
+	if rebuild == nil {
+		rebuild = d.TryRun
+	}
+	if !rebuild() {
+		// The alerts are checked again next time, once the build has finished
+		a.logInfo("The display is already being built.")
+		return
+	}
+	// Remember the alerts even if the build failed, so that it is not retried on every check
+	a.last = l
+}
+
+func (a *AlertWatcher) logInfo(v ...interface{}) {
+	m := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("AlertWatcher: [Inf] ", m)
+	} else {
+		fmt.Println("AlertWatcher: [Inf] ", m)
+	}
+}
+
+func (a *AlertWatcher) logError(v ...interface{}) {
+	m := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Error("AlertWatcher: [Err] ", m)
+	} else {
+		fmt.Println("AlertWatcher: [Err] ", m)
+	}
+}
+
+// addAlertBanners draws the banner of the most severe alert across the top of each display image
+func (d *Display) addAlertBanners(dl []DisplayImage, al []Alert) {
+	if len(al) == 0 {
+		return
+	}
+	for _, i := range dl {
+		img, err := gg.LoadImage(i.ImagePath)
+		if err != nil {
+			d.logError("Error loading image for the alert banner. " + err.Error())
+			continue
+		}
+		dc := canvas{Context: gg.NewContextForImage(img)}
+		d.drawAlertBanner(dc, al)
+		if err := dc.SavePNG(i.ImagePath); err != nil {
+			d.logError("Error saving image with the alert banner. " + err.Error())
+		}
+	}
+}
+
+// drawAlertBanner draws the headline and validity of the most severe alert in the colour of its severity,
+// with the number of other alerts
+func (d *Display) drawAlertBanner(dc canvas, al []Alert) {
+	dc.Widget = "alert"
+	loc := d.Srv.Config.GetLocale()
+	a := al[0]
+	bc := severityColours[a.Severity]
+	tc := overlayLight
+	if contrastRatio(relLuminance(overlayDark), relLuminance(bc)) > contrastRatio(relLuminance(overlayLight), relLuminance(bc)) {
+		tc = overlayDark
+	}
+	w := float64(dc.Width())
+
+	dc.SetColor(bc)
+	dc.DrawRectangle(0, 0, w, alertBannerHeight)
+	dc.Fill()
+	dc.SetColor(tc)
+	dc.DrawRectangle(0, alertBannerHeight-3, w, 3)
+	dc.Fill()
+
+	// Draw the validity and the number of other alerts on the right
+	right := w - 12
+	face := d.setFont(dc, 14)
+	v := a.GetValidity(loc, time.Now())
+	if len(al) > 1 {
+		v = strings.TrimSpace(fmt.Sprintf("%s  (+%d)", v, len(al)-1))
+	}
+	if v != "" {
+		vw := measureText(face, v)
+		right = right - vw
+		dc.DrawString(v, right, 28)
+		right = right - 16
+	}
+
+	// Draw the headline in the space left
+	face = d.setFont(dc, 20)
+	s := ellipsize(face, a.GetHeadline(), right-12)
+	dc.SetColor(tc)
+	dc.DrawString(s, 12, 29)
+}
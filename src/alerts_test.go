@@ -0,0 +1,211 @@
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+func readAlertFixture(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCanParseCAPAlert(t *testing.T) {
+	l, links, err := parseCAP(readAlertFixture(t, "cap_alert.xml"), GetLocale("en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || len(links) != 0 {
+		t.Fatal("Expected 1 alert, got", len(l), len(links))
+	}
+	a := l[0]
+	if a.ID != "urn:oid:2.49.0.0.710.0.2024.6.1.0930.1" || a.Event != "Disruptive Rain" || a.Severity != SeveritySevere {
+		t.Error("Unexpected alert", a)
+	}
+	if a.Headline != "Orange Level 6 Warning for Disruptive Rain" || a.Sender != "South African Weather Service" {
+		t.Error("Unexpected headline or sender", a.Headline, a.Sender)
+	}
+	if a.Area != "City of Cape Town, Stellenbosch" {
+		t.Error("Unexpected area", a.Area)
+	}
+	if !a.Onset.Equal(time.Date(2024, 6, 1, 16, 0, 0, 0, time.UTC)) || !a.Expires.Equal(time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Error("Unexpected validity", a.Onset, a.Expires)
+	}
+}
+
+func TestCanParseCAPAlertInLocale(t *testing.T) {
+	l, _, err := parseCAP(readAlertFixture(t, "cap_alert.xml"), GetLocale("af"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].Headline != "Oranje Vlak 6 Waarskuwing vir Ontwrigtende Reën" {
+		t.Error("Expected the Afrikaans info", l)
+	}
+	// There is no German info, so the English one is used
+	l, _, _ = parseCAP(readAlertFixture(t, "cap_alert.xml"), GetLocale("de"))
+	if len(l) != 1 || l[0].Event != "Disruptive Rain" {
+		t.Error("Expected the English info", l)
+	}
+}
+
+func TestCanIgnoreCAPCancelAndExercise(t *testing.T) {
+	for _, f := range []string{"cap_cancel.xml", "cap_exercise.xml"} {
+		l, _, err := parseCAP(readAlertFixture(t, f), GetLocale("en"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l) != 0 {
+			t.Error(f, "should not return an alert", l)
+		}
+	}
+	if _, _, err := parseCAP([]byte("<rss></rss>"), GetLocale("en")); err == nil {
+		t.Error("Expected an error for a document that is not CAP")
+	}
+}
+
+func TestCanGetAlertFeeds(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := readAlertFixture(t, path.Base(r.URL.Path))
+		w.Write([]byte(strings.Replace(string(b), "CAP_URL", srv.URL, -1)))
+	}))
+	defer srv.Close()
+
+	l, err := getAlertFeed(srv.URL+"/cap_feed.atom", GetLocale("en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 {
+		t.Fatal("Expected 2 alerts, got", len(l))
+	}
+	if l[0].Event != "Damaging Winds" || l[0].Severity != SeverityModerate || l[0].Area != "Overberg; Cape Winelands" {
+		t.Error("Unexpected feed alert", l[0])
+	}
+	if l[0].Onset.IsZero() || l[0].Expires.IsZero() {
+		t.Error("Expected the effective time to be used for the onset", l[0])
+	}
+	// The second entry only links to the CAP alert
+	if l[1].Event != "Disruptive Rain" || l[1].Severity != SeveritySevere {
+		t.Error("Unexpected linked alert", l[1])
+	}
+
+	l, err = getAlertFeed(srv.URL+"/metalerts.json", GetLocale("en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].Event != "Regnflom" || l[0].Severity != SeveritySevere || l[0].Expires.IsZero() {
+		t.Error("Unexpected Met Norway alert", l)
+	}
+
+	l, err = getAlertFeed(srv.URL+"/owm_alerts.json", GetLocale("en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0].Headline != "Heat Advisory" || l[0].Expires.Unix() != 1717286400 {
+		t.Error("Unexpected OpenWeatherMap alert", l)
+	}
+}
+
+func TestCanGetActiveAlerts(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := []Alert{
+		{ID: "minor", Severity: SeverityMinor, Expires: now.Add(time.Hour)},
+		{ID: "expired", Severity: SeverityExtreme, Expires: now.Add(-time.Hour)},
+		{ID: "severe", Severity: SeveritySevere, Expires: now.Add(time.Hour)},
+		{ID: "minor", Severity: SeverityMinor, Expires: now.Add(time.Hour)},
+		{ID: "open", Severity: SeverityUnknown},
+	}
+	al := activeAlerts(l, now)
+	ids := []string{}
+	for _, a := range al {
+		ids = append(ids, a.ID)
+	}
+	if strings.Join(ids, ",") != "severe,minor,open" {
+		t.Error("Unexpected active alerts", ids)
+	}
+	if hasNewAlerts(al, al[:2]) {
+		t.Error("Expected no new alerts")
+	}
+	if !hasNewAlerts(al[:2], al) {
+		t.Error("Expected a new alert")
+	}
+}
+
+func TestCanGetAlertValidity(t *testing.T) {
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.Local)
+	on := time.Date(2024, 6, 3, 18, 0, 0, 0, time.Local)
+	ex := time.Date(2024, 6, 4, 6, 0, 0, 0, time.Local)
+	de := GetLocale("de")
+	if s := (Alert{Onset: on, Expires: ex}).GetValidity(de, now); s != "Mo 18:00 - Di 06:00" {
+		t.Error("Unexpected validity", s)
+	}
+	if s := (Alert{Onset: now.Add(-time.Hour), Expires: ex}).GetValidity(de, now); s != "bis Di 06:00" {
+		t.Error("Unexpected validity", s)
+	}
+	if s := (Alert{Onset: on}).GetValidity(GetLocale("en"), now); s != "from Mon 6:00PM" {
+		t.Error("Unexpected validity", s)
+	}
+}
+
+func TestCanDrawAlertBanner(t *testing.T) {
+	c := Config{}
+	d := Display{Srv: &Server{Config: &c}}
+	dc := canvas{Context: gg.NewContextForImage(image.NewRGBA(image.Rect(0, 0, 800, 480)))}
+	al := []Alert{{Headline: "Orange Level 6 Warning for Disruptive Rain", Severity: SeveritySevere}}
+	d.drawAlertBanner(dc, al)
+
+	out := dc.Image()
+	if r, g, b, _ := out.At(790, 5).RGBA(); r>>8 < 200 || g>>8 > 100 || b>>8 > 30 {
+		t.Error("Expected the severe colour across the top, got", out.At(790, 5))
+	}
+	if _, _, _, a := out.At(400, 100).RGBA(); a != 0 {
+		t.Error("Expected nothing drawn below the banner, got", out.At(400, 100))
+	}
+}
+
+func TestCanWatchForNewAlerts(t *testing.T) {
+	s := &Server{Config: &Config{Alerts: true, Weather: true}}
+	end := time.Now().Add(time.Hour).Unix()
+	s.Display.Weather = Weather{Alerts: []WeatherAlert{{Event: "Heat Advisory", Start: time.Now().Unix(), End: end}}}
+	started := true
+	builds := 0
+	a := &AlertWatcher{Srv: s, rebuild: func() bool {
+		builds++
+		return started
+	}}
+
+	// The build is already running, so the alert is checked again next time
+	started = false
+	a.Run()
+	if builds != 1 {
+		t.Fatal("Expected a rebuild for the new alert, got", builds)
+	}
+	started = true
+	a.Run()
+	if builds != 2 {
+		t.Fatal("Expected the rebuild to be tried again, got", builds)
+	}
+
+	// The build did not put the alert on the frame, but it is not rebuilt again
+	a.Run()
+	if builds != 2 {
+		t.Error("Expected no rebuild for an alert already acted on, got", builds)
+	}
+
+	s.Display.Weather.Alerts = append(s.Display.Weather.Alerts, WeatherAlert{Event: "Flood Watch", Start: time.Now().Unix(), End: end})
+	a.Run()
+	if builds != 3 {
+		t.Error("Expected a rebuild for the second alert, got", builds)
+	}
+}
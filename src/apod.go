@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type apodData []struct {
+	Date      string `json:"date"`
+	Title     string `json:"title"`
+	Copyright string `json:"copyright"`
+	MediaType string `json:"media_type"`
+	URL       string `json:"url"`
+	HDURL     string `json:"hdurl"`
+}
+
+// Apod is an image provider that selects images from
+// the NASA Astronomy Picture of the Day
+type Apod struct {
+	Config Config
+	apiURL string // URL of the APOD API, defaults to https://api.nasa.gov/planetary/apod
+}
+
+// SetConfig sets the configuration for this provider
+func (p *Apod) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *Apod) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Downloading images from NASA Astronomy Picture of the Day.")
+
+	l := []DisplayImage{}
+	// Get twice as many days as needed, as some of the entries will be videos.
+	// The end date is left out, so the API ends at its own today, which is behind the frame east of US Eastern time.
+	start := apodToday().AddDate(0, 0, 1-2*p.Config.ImgCount)
+	u := fmt.Sprintf("%s?api_key=%s&start_date=%s&thumbs=false",
+		p.getAPIURL(), p.Config.ApodKey, start.Format("2006-01-02"))
+	ad, err := p.getApodData(u)
+
+	if err == nil {
+		// Download the images
+		l, err = p.downloadImages(ad)
+	}
+
+	if err != nil {
+		p.LogError("Error getting Images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastapod.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// apodToday returns the current time in US Eastern time, which the APOD API uses for its dates
+func apodToday() time.Time {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.FixedZone("EST", -5*60*60)
+	}
+	return time.Now().In(loc)
+}
+
+func (p *Apod) getApodData(url string) (apodData, error) {
+	// Get the data from the APOD API
+	j, err := GetHTTPClient().Get(url)
+	if err != nil {
+		return apodData{}, err
+	}
+	ad := apodData{}
+	err = json.Unmarshal(j, &ad)
+	if err != nil {
+		return apodData{}, err
+	}
+	return ad, nil
+}
+
+func (p *Apod) downloadImages(ad apodData) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/apod"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	xRes, yRes := p.Config.GetResolution()
+
+	r := GetRatings()
+	d := NewDownloader(p.Config)
+	// The API returns the oldest entry first
+	n := len(ad) - 1
+	for n >= 0 && len(l) < p.Config.ImgCount {
+		// Download only as many images as are still needed, then try the next ones if any failed
+		il := []DisplayImage{}
+		dl := []DownloadJob{}
+		for ; n >= 0 && len(l)+len(il) < p.Config.ImgCount; n-- {
+			i := ad[n]
+			if i.MediaType != "image" {
+				p.LogInfo("Skipping ", i.MediaType, " entry for ", i.Date)
+				continue
+			}
+			url := i.HDURL
+			if url == "" {
+				url = i.URL
+			}
+			if url == "" {
+				continue
+			}
+			fn := p.getImageName(i.Date, url)
+			fp := filepath.Join(path, fn)
+			if r.IsBanned(fp) {
+				p.LogInfo("Skipping banned image '", fn, "'")
+				continue
+			}
+			p.LogInfo("Checking image ", fp)
+			if _, err := os.Stat(fp); os.IsNotExist(err) {
+				p.LogInfo("Downloading ", i.Title, " ", fn)
+				dl = append(dl, DownloadJob{URL: url, Path: fp, Width: xRes, Height: yRes})
+			}
+			il = append(il, DisplayImage{
+				Name:      fn,
+				Copyright: p.getCopyright(i.Title, i.Copyright),
+				ImagePath: fp,
+			})
+		}
+		failed := map[string]bool{}
+		for k, err := range d.Download(dl) {
+			if err != nil {
+				p.LogError("Error getting image file from url ", dl[k].URL, ". ", err.Error())
+				failed[dl[k].Path] = true
+			}
+		}
+		for _, i := range il {
+			// Add the image to the list to return
+			if !failed[i.ImagePath] {
+				l = append(l, i)
+			}
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			}
+		}
+	}
+	if err == nil {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastapod.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// getImageName returns the file name for the image of the specified day
+func (p *Apod) getImageName(date string, url string) string {
+	ext := strings.ToLower(path.Ext(strings.Split(url, "?")[0]))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif":
+	default:
+		ext = ".jpg"
+	}
+	return "apod" + strings.Replace(date, "-", "", -1) + ext
+}
+
+// getCopyright returns the copyright text for the image.
+// Images without a copyright are in the public domain.
+func (p *Apod) getCopyright(title string, cr string) string {
+	cr = strings.Join(strings.Fields(cr), " ")
+	if cr == "" {
+		cr = "NASA"
+	}
+	return fmt.Sprintf("%s - %s", title, cr)
+}
+
+func (p *Apod) getAPIURL() string {
+	if p.apiURL != "" {
+		return p.apiURL
+	}
+	return "https://api.nasa.gov/planetary/apod"
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *Apod) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Apod: [Inf] ", a)
+	} else {
+		fmt.Println("Apod: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *Apod) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Apod: [Err] ", a)
+	} else {
+		fmt.Println("Apod: [Err] ", a)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestCanGetApodImages(t *testing.T) {
+	os.RemoveAll("./img/apod")
+
+	var srv *httptest.Server
+	requested := map[string]bool{}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/planetary/apod":
+			if r.URL.Query().Get("api_key") != "DEMO_KEY" {
+				http.Error(w, "Forbidden", 403)
+				return
+			}
+			// The API rejects dates after today in US Eastern time
+			q := r.URL.Query()
+			if q.Get("end_date") != "" || q.Get("start_date") > apodToday().Format("2006-01-02") {
+				http.Error(w, "Date must be between Jun 16, 1995 and today", 400)
+				return
+			}
+			b, err := ioutil.ReadFile("testdata/apod.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte(strings.Replace(string(b), "https://apod.nasa.gov", srv.URL, -1)))
+		case strings.HasSuffix(r.URL.Path, ".png"):
+			requested[r.URL.Path] = true
+			png.Encode(w, image.NewRGBA(image.Rect(0, 0, 400, 400)))
+		default:
+			requested[r.URL.Path] = true
+			jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 400, 400)), nil)
+		}
+	}))
+	defer srv.Close()
+
+	c := Config{
+		Provider: 6,
+		ImgCount: 8,
+	}
+	c.SetDefaults()
+
+	i := Apod{Config: c, apiURL: srv.URL + "/planetary/apod"}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 3 {
+		t.Fatal(len(l), "images returned, expected 3.")
+	}
+	if l[0].Name != "apod20240304.jpg" || l[1].Name != "apod20240303.png" || l[2].Name != "apod20240301.jpg" {
+		t.Error("Images not returned newest first.", l)
+	}
+	if !requested["/apod/image/2403/M101_Hubble_4000.png"] || requested["/apod/image/2403/M101_Hubble_960.jpg"] {
+		t.Error("The hdurl was not used.")
+	}
+	if l[0].Copyright != "Aurora over Iceland - Jane Doe" {
+		t.Error("Unexpected copyright", l[0].Copyright)
+	}
+	if l[1].Copyright != "M101: The Pinwheel Galaxy - NASA" {
+		t.Error("Unexpected copyright", l[1].Copyright)
+	}
+	if l[2].Copyright != "Milky Way over the Atlantic - Miguel Claro" {
+		t.Error("Unexpected copyright", l[2].Copyright)
+	}
+	img, err := imaging.Open(l[0].ImagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coversFrame(img.Bounds(), 800, 480) {
+		t.Error("Image was not resized to cover the display resolution", img.Bounds())
+	}
+}
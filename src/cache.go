@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cacheFile = "cache.json"
+
+// cacheLock serializes updates to the cache file
+var cacheLock sync.Mutex
+
+// cachedFile holds the details about a file in the image folders
+type cachedFile struct {
+	Path  string
+	Size  int64
+	Shown time.Time
+}
+
+// ImageCache tracks when the images in the image folders were last shown
+// and evicts the least recently shown images when the folders are over the quota.
+// Favourites, the display images, the images in the file folder and the mirrored WebDAV and S3
+// images are never evicted, but their sizes are included in the total.
+type ImageCache struct {
+	Path  string // Folder holding the image folders
+	Quota int64  // Maximum total size of the image folders in bytes
+	File  string // File the last shown times are saved to
+}
+
+// NewImageCache creates an ImageCache for the image folders using the quota in the configuration
+func NewImageCache(c Config) *ImageCache {
+	return &ImageCache{
+		Path:  "./img",
+		Quota: int64(c.CacheQuotaMB) * 1024 * 1024,
+		File:  cacheFile,
+	}
+}
+
+// MarkShown records the time the images were sent to the display
+func (c *ImageCache) MarkShown(l []DisplayImage, t time.Time) error {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	s := c.readState()
+	for _, i := range l {
+		s[ratingKey(i.ImagePath)] = t
+	}
+	return c.writeState(s)
+}
+
+// Enforce removes the least recently shown images until the image folders are within the quota.
+// The images in the keep list are not removed. The paths of the removed images are returned.
+func (c *ImageCache) Enforce(keep []DisplayImage) ([]string, error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	rl := []string{}
+	if c.Quota <= 0 {
+		return rl, nil
+	}
+
+	s := c.readState()
+	r := GetRatings()
+	k := map[string]bool{}
+	for _, i := range keep {
+		k[ratingKey(i.ImagePath)] = true
+	}
+
+	total := int64(0)
+	fl := []cachedFile{}
+	err := filepath.Walk(c.Path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		total = total + fi.Size()
+		key := ratingKey(p)
+		if k[key] || r.IsFavourite(key) || !c.canEvict(p) {
+			return nil
+		}
+		// Images that have never been shown are treated as shown when they were downloaded
+		t, ok := s[key]
+		if !ok {
+			t = fi.ModTime()
+		}
+		fl = append(fl, cachedFile{Path: p, Size: fi.Size(), Shown: t})
+		return nil
+	})
+	if err != nil {
+		return rl, err
+	}
+	if total <= c.Quota {
+		return rl, nil
+	}
+
+	sort.SliceStable(fl, func(i, j int) bool {
+		return fl[i].Shown.Before(fl[j].Shown)
+	})
+	for _, f := range fl {
+		if total <= c.Quota {
+			break
+		}
+		c.LogInfo("Evicting ", f.Path, " (last shown ", f.Shown.Format("2006-01-02 15:04"), ")")
+		if err := os.Remove(f.Path); err != nil {
+			c.LogError("Error removing image file ", f.Path, ". ", err.Error())
+			continue
+		}
+		total = total - f.Size
+		delete(s, ratingKey(f.Path))
+		rl = append(rl, f.Path)
+	}
+	if total > c.Quota {
+		c.LogError(fmt.Sprintf("Image folders still use %d MB after eviction.", total/1024/1024))
+	}
+
+	// Forget the images that are no longer on the disk
+	for p := range s {
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			delete(s, p)
+		}
+	}
+	return rl, c.writeState(s)
+}
+
+// canEvict returns false for the files that are rebuilt on every run,
+// that are the only copy of the image, or that are in a mirror, which would
+// download the missing files again on the next run
+func (c *ImageCache) canEvict(p string) bool {
+	rp, err := filepath.Rel(c.Path, p)
+	if err != nil {
+		return false
+	}
+	switch strings.Split(filepath.ToSlash(rp), "/")[0] {
+	case "display", "filefolder", "webdav", "s3":
+		return false
+	}
+	return true
+}
+
+func (c *ImageCache) readState() map[string]time.Time {
+	s := map[string]time.Time{}
+	if b, err := ioutil.ReadFile(c.File); err == nil {
+		json.Unmarshal(b, &s)
+	}
+	return s
+}
+
+func (c *ImageCache) writeState(s map[string]time.Time) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.File, b, 0666)
+}
+
+// LogInfo is used to log information messages for this controller.
+func (c *ImageCache) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("ImageCache: [Inf] ", a)
+	} else {
+		fmt.Println("ImageCache: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (c *ImageCache) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("ImageCache: [Err] ", a)
+	} else {
+		fmt.Println("ImageCache: [Err] ", a)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCanEvictLeastRecentlyShownImages(t *testing.T) {
+	if _, err := os.Stat(ratingsFile); err == nil {
+		t.Skip("Ratings file already exists.")
+	}
+	defer os.Remove(ratingsFile)
+
+	path := t.TempDir()
+	now := time.Now()
+	files := map[string]time.Time{
+		"bing/old.jpg":        now.AddDate(0, 0, -10),
+		"bing/new.jpg":        now.AddDate(0, 0, -1),
+		"pexels/unshown.jpg":  now.AddDate(0, 0, -5),
+		"pexels/fav.jpg":      now.AddDate(0, 0, -20),
+		"pexels/current.jpg":  now.AddDate(0, 0, -30),
+		"display/image0.png":  now.AddDate(0, 0, -30),
+		"filefolder/mine.jpg": now.AddDate(0, 0, -30),
+		"webdav/shared.jpg":   now.AddDate(0, 0, -30),
+		"s3/bucket.jpg":       now.AddDate(0, 0, -30),
+	}
+	for n, mt := range files {
+		fp := filepath.Join(path, n)
+		os.MkdirAll(filepath.Dir(fp), 0777)
+		if err := ioutil.WriteFile(fp, make([]byte, 1024), 0666); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(fp, mt, mt)
+	}
+	UpdateRating(DisplayImage{ImagePath: filepath.Join(path, "pexels/fav.jpg")}, func(r *ImageRating) {
+		r.Favourite = true
+	})
+
+	c := ImageCache{Path: path, Quota: 8 * 1024, File: filepath.Join(path, "cache.json")}
+	c.MarkShown([]DisplayImage{{ImagePath: filepath.Join(path, "bing/old.jpg")}}, now.AddDate(0, 0, -8))
+	c.MarkShown([]DisplayImage{{ImagePath: filepath.Join(path, "bing/new.jpg")}}, now.AddDate(0, 0, -2))
+
+	// 9 files of 1KB and the cache file against a quota of 8KB
+	rl, err := c.Enforce([]DisplayImage{{ImagePath: filepath.Join(path, "pexels/current.jpg")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rl) != 2 || filepath.Base(rl[0]) != "old.jpg" || filepath.Base(rl[1]) != "unshown.jpg" {
+		t.Error("Least recently shown images not evicted first", rl)
+	}
+	for _, n := range []string{"bing/new.jpg", "pexels/fav.jpg", "pexels/current.jpg", "display/image0.png", "filefolder/mine.jpg", "webdav/shared.jpg", "s3/bucket.jpg"} {
+		if _, err := os.Stat(filepath.Join(path, n)); err != nil {
+			t.Error("Protected image", n, "was evicted")
+		}
+	}
+	if s := c.readState(); len(s) != 1 {
+		t.Error("Evicted images not removed from the cache state", s)
+	}
+}
+
+func TestCanGetFreeSpace(t *testing.T) {
+	free, err := getFreeSpace(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free <= 0 {
+		t.Error("Unexpected free space", free)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// commandImage is a line of JSON printed by the external command
+type commandImage struct {
+	Name      string `json:"name"`
+	Copyright string `json:"copyright"`
+	Path      string `json:"path"`
+	URL       string `json:"url"`
+}
+
+// Command is an image provider that runs a configured executable
+// which prints the images to display as JSON lines on stdout
+type Command struct {
+	Config Config
+}
+
+// SetConfig sets the configuration for this provider
+func (p *Command) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *Command) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Getting images from ", p.Config.CommandPath)
+
+	l := []DisplayImage{}
+	cl, err := p.runCommand()
+	if err == nil {
+		l, err = p.loadImages(cl)
+	}
+
+	if err != nil {
+		p.LogError("Error getting images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastcommand.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// runCommand runs the executable and parses the images that it prints
+func (p *Command) runCommand() ([]commandImage, error) {
+	if p.Config.CommandPath == "" {
+		return nil, fmt.Errorf("The command has not been configured")
+	}
+
+	to := time.Duration(p.Config.CommandTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), to)
+	defer cancel()
+
+	xRes, yRes := p.Config.GetResolution()
+	cmd := exec.CommandContext(ctx, p.Config.CommandPath, p.Config.CommandArgs...)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("PHOTOFRAME_IMGCOUNT=%d", p.Config.ImgCount),
+		fmt.Sprintf("PHOTOFRAME_WIDTH=%d", xRes),
+		fmt.Sprintf("PHOTOFRAME_HEIGHT=%d", yRes))
+	// Don't wait on any child processes still holding the output open
+	cmd.WaitDelay = time.Second
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	// Copy the error output of the command to the log
+	s := bufio.NewScanner(&stderr)
+	for s.Scan() {
+		if t := strings.TrimSpace(s.Text()); t != "" {
+			p.LogInfo("stderr: ", t)
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("The command did not complete within %s", to)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("The command failed. %s", err.Error())
+	}
+
+	cl := []commandImage{}
+	s = bufio.NewScanner(&stdout)
+	for n := 1; s.Scan(); n++ {
+		t := strings.TrimSpace(s.Text())
+		if t == "" {
+			continue
+		}
+		c := commandImage{}
+		if err := json.Unmarshal([]byte(t), &c); err != nil {
+			p.LogError("Invalid output on line ", n, ". ", err.Error())
+			continue
+		}
+		cl = append(cl, c)
+	}
+	return cl, s.Err()
+}
+
+func (p *Command) loadImages(cl []commandImage) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/command"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	xRes, yRes := p.Config.GetResolution()
+
+	r := GetRatings()
+	d := NewDownloader(p.Config)
+	names := map[string]bool{}
+	for len(cl) != 0 && len(l) < p.Config.ImgCount {
+		// Download only as many images as are still needed, then try the next ones if any failed
+		il := []DisplayImage{}
+		dl := []DownloadJob{}
+		for len(cl) != 0 && len(l)+len(il) < p.Config.ImgCount {
+			c := cl[0]
+			cl = cl[1:]
+			fn, err := p.getImageName(c)
+			if err != nil {
+				p.LogError("Skipping image. ", err.Error())
+				continue
+			}
+			fp := filepath.Join(path, fn)
+			if r.IsBanned(fp) {
+				p.LogInfo("Skipping banned image '", fn, "'")
+				continue
+			}
+			if names[fn] {
+				p.LogInfo("Skipping duplicate image '", fn, "'")
+				continue
+			}
+
+			if c.Path != "" {
+				if err = p.copyImage(fp, c.Path, xRes, yRes); err != nil {
+					// There was an issue processing the image,
+					// remove the file from the disk if anything was written
+					if _, err := os.Stat(fp); err == nil {
+						p.LogInfo("Removing file ", fp)
+						os.Remove(fp)
+					}
+					continue
+				}
+			} else if _, serr := os.Stat(fp); os.IsNotExist(serr) {
+				p.LogInfo("Downloading ", c.URL)
+				dl = append(dl, DownloadJob{URL: c.URL, Path: fp, Width: xRes, Height: yRes})
+			}
+			names[fn] = true
+			il = append(il, DisplayImage{
+				Name:      fn,
+				Copyright: c.Copyright,
+				ImagePath: fp,
+			})
+		}
+		failed := map[string]bool{}
+		for n, err := range d.Download(dl) {
+			if err != nil {
+				p.LogError("Error getting image file from url ", dl[n].URL, ". ", err.Error())
+				failed[dl[n].Path] = true
+			}
+		}
+		for _, i := range il {
+			if !failed[i.ImagePath] {
+				l = append(l, i)
+			}
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			}
+		}
+	}
+	if err == nil {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastcommand.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// getImageName validates the source of the image and returns the local file name for it
+func (p *Command) getImageName(c commandImage) (string, error) {
+	src := ""
+	switch {
+	case c.Path != "" && c.URL != "":
+		return "", fmt.Errorf("Only one of path or url may be given for '%s'", c.Name)
+	case c.Path != "":
+		fi, err := os.Stat(c.Path)
+		if err != nil {
+			return "", err
+		}
+		if !fi.Mode().IsRegular() {
+			return "", fmt.Errorf("'%s' is not a file", c.Path)
+		}
+		src = filepath.Base(c.Path)
+	case c.URL != "":
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return "", err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return "", fmt.Errorf("'%s' is not a http url", c.URL)
+		}
+		src = path.Base(u.Path)
+	default:
+		return "", fmt.Errorf("No path or url given for '%s'", c.Name)
+	}
+
+	ext := strings.ToLower(path.Ext(src))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif":
+	default:
+		if c.Path != "" {
+			return "", fmt.Errorf("'%s' is not a supported image file", c.Path)
+		}
+		ext = ".jpg"
+	}
+
+	// Only keep the characters that are safe in a file name
+	n := c.Name
+	if n == "" {
+		n = strings.TrimSuffix(src, path.Ext(src))
+	}
+	n = strings.TrimSuffix(n, path.Ext(n))
+	n = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, n)
+	if strings.Trim(n, "_") == "" {
+		return "", fmt.Errorf("Unable to get a file name for '%s%s'", c.Path, c.URL)
+	}
+	return n + ext, nil
+}
+
+// copyImage copies the local image file into the cache folder if it has changed
+func (p *Command) copyImage(fp string, src string, xRes int, yRes int) error {
+	si, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(fp); err == nil && !fi.ModTime().Before(si.ModTime()) {
+		return nil
+	}
+	p.LogInfo("Copying ", src)
+	return p.resizeImage(fp, src, xRes, yRes)
+}
+
+// resizeImage resizes the image to the frame and saves it to the cache folder
+func (p *Command) resizeImage(fp string, src string, xRes int, yRes int) error {
+	img, err := openImage(src)
+	if err != nil {
+		p.LogError("Error opening image file ", src, " for resizing. ", err.Error())
+		return err
+	}
+	img = coverImage(img, xRes, yRes)
+	err = imaging.Save(img, fp)
+	if err != nil {
+		p.LogError("Error saving resized image file ", fp, ". ", err.Error())
+	}
+	return err
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *Command) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Command: [Inf] ", a)
+	} else {
+		fmt.Println("Command: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *Command) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Command: [Err] ", a)
+	} else {
+		fmt.Println("Command: [Err] ", a)
+	}
+}
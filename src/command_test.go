@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestCanGetCommandImages(t *testing.T) {
+	os.RemoveAll("./img/command")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 1000, 1000)), nil)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "photoframe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	imaging.Save(image.NewRGBA(image.Rect(0, 0, 400, 300)), filepath.Join(dir, "local.png"))
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0666)
+
+	c := Config{
+		Provider:    13,
+		ImgCount:    5,
+		CommandPath: "./testdata/command_ok.sh",
+		CommandArgs: []string{srv.URL, dir},
+	}
+	c.SetDefaults()
+
+	i := Command{Config: c}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 2 {
+		t.Fatal(len(l), "images returned, expected 2.", l)
+	}
+	if l[0].Name != "local.png" || l[0].Copyright != "Local image" {
+		t.Error("Unexpected local image", l[0])
+	}
+	if l[1].Name != "______remote.jpg" || l[1].ImagePath != filepath.Join("img", "command", "______remote.jpg") {
+		t.Error("Unexpected remote image", l[1])
+	}
+	for _, d := range l {
+		img, err := imaging.Open(d.ImagePath)
+		if err != nil {
+			t.Error(err)
+		} else if !coversFrame(img.Bounds(), 800, 480) {
+			t.Error("Image not resized to cover the frame", d.Name, img.Bounds())
+		}
+	}
+}
+
+func TestCommandTimesOut(t *testing.T) {
+	c := Config{
+		Provider:       13,
+		ImgCount:       5,
+		CommandPath:    "./testdata/command_slow.sh",
+		CommandTimeout: 1,
+	}
+	c.SetDefaults()
+
+	i := Command{Config: c}
+	st := time.Now()
+	_, err := i.runCommand()
+	if err == nil || !strings.Contains(err.Error(), "did not complete") {
+		t.Error("Expected a timeout error, got", err)
+	}
+	if time.Since(st) > 5*time.Second {
+		t.Error("Command was not stopped at the timeout")
+	}
+}
+
+func TestCommandFails(t *testing.T) {
+	c := Config{
+		Provider:    13,
+		ImgCount:    5,
+		CommandPath: "./testdata/command_fail.sh",
+	}
+	c.SetDefaults()
+
+	i := Command{Config: c}
+	if _, err := i.runCommand(); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Error("Expected the exit status of the command, got", err)
+	}
+}
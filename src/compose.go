@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+// getImageSize returns the size of the image as it will be shown, after the EXIF orientation is applied
+func getImageSize(i DisplayImage) (int, int, error) {
+	f, err := os.Open(i.ImagePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	o := 1
+	if i.Meta != nil {
+		o = i.Meta.Orientation
+	} else if m, err := ReadMetadata(i.ImagePath); err == nil {
+		o = m.Orientation
+	}
+	if o >= 5 {
+		// Orientations 5 to 8 are rotated by 90 degrees
+		return c.Height, c.Width, nil
+	}
+	return c.Width, c.Height, nil
+}
+
+// isPortrait returns true if the image is taller than it is wide
+func isPortrait(i DisplayImage) bool {
+	w, h, err := getImageSize(i)
+	return err == nil && h > w
+}
+
+// getPairScore returns how well two images go together.
+// Images from the same album or taken on the same day score higher.
+func getPairScore(a DisplayImage, b DisplayImage) int {
+	s := 0
+	if a.Album != "" && a.Album == b.Album {
+		s = s + 2
+	}
+	if a.Meta != nil && b.Meta != nil && !a.Meta.Taken.IsZero() && !b.Meta.Taken.IsZero() &&
+		a.Meta.Taken.Format("20060102") == b.Meta.Taken.Format("20060102") {
+		s = s + 1
+	}
+	return s
+}
+
+// pairImages returns the indexes of the portrait images paired together.
+// Each portrait is paired with the best scoring portrait after it, the first one if they score the same.
+func pairImages(l []DisplayImage, portrait []bool) [][2]int {
+	pl := [][2]int{}
+	used := map[int]bool{}
+	for a := range l {
+		if !portrait[a] || used[a] {
+			continue
+		}
+		best, bs := -1, -1
+		for b := a + 1; b < len(l); b++ {
+			if !portrait[b] || used[b] || l[b].ImagePath == l[a].ImagePath {
+				continue
+			}
+			if s := getPairScore(l[a], l[b]); s > bs {
+				best, bs = b, s
+			}
+		}
+		if best >= 0 {
+			used[a] = true
+			used[best] = true
+			pl = append(pl, [2]int{a, best})
+		}
+	}
+	return pl
+}
+
+// composePair draws the two images side by side, with a gutter between them,
+// and saves the slide to the path. The credits of the images are kept in the parts.
+func composePair(a DisplayImage, b DisplayImage, fp string, w int, h int, gutter int, mode int) (DisplayImage, error) {
+	di := DisplayImage{Name: a.Name + " + " + b.Name, Parts: []DisplayImage{a, b}}
+	hw := (w - gutter) / 2
+	dst := imaging.New(w, h, color.Black)
+	for n, i := range []DisplayImage{a, b} {
+		img, err := openImage(i.ImagePath)
+		if err != nil {
+			return di, err
+		}
+		x := 0
+		if n == 1 {
+			x = w - hw
+		}
+		dst = imaging.Paste(dst, fitImage(img, hw, h, mode), image.Pt(x, 0))
+	}
+	di.ImagePath = fp
+	return di, imaging.Save(dst, fp)
+}
+
+// pairPortraits replaces pairs of portrait images with a single landscape slide.
+// Nothing is changed if the display is not landscape.
+func (d *Display) pairPortraits(l []DisplayImage, path string) []DisplayImage {
+	xRes, yRes := d.Srv.Config.GetResolution()
+	if !d.Srv.Config.PairPortraits || xRes <= yRes {
+		return l
+	}
+
+	portrait := make([]bool, len(l))
+	for n, i := range l {
+		portrait[n] = isPortrait(i)
+	}
+	pl := pairImages(l, portrait)
+	if len(pl) == 0 {
+		return l
+	}
+
+	// The slide takes the place of the first image of the pair
+	slides := map[int]DisplayImage{}
+	skip := map[int]bool{}
+	for n, p := range pl {
+		fp := filepath.Join(path, fmt.Sprintf("pair%d.png", n))
+		di, err := composePair(l[p[0]], l[p[1]], fp, xRes, yRes, d.Srv.Config.PairGutter, d.Srv.Config.GetFitMode())
+		if err != nil {
+			d.logError("Error composing portrait pair. ", err.Error())
+			continue
+		}
+		d.logInfo("Paired portrait images ", l[p[0]].Name, " and ", l[p[1]].Name)
+		slides[p[0]] = di
+		skip[p[1]] = true
+	}
+	rl := []DisplayImage{}
+	for n, i := range l {
+		if s, ok := slides[n]; ok {
+			rl = append(rl, s)
+		} else if !skip[n] {
+			rl = append(rl, i)
+		}
+	}
+	return rl
+}
+
+// Collage layouts
+const (
+	CollageAll   = 0 // Use each of the layouts in turn
+	CollageGrid  = 1 // 2x2 grid
+	CollageOneUp = 2 // One large image and two small images
+	CollageStrip = 3 // 3 columns
+)
+
+// collageCell is the position and size of an image in a collage, in grid units
+type collageCell struct {
+	X, Y, W, H int
+}
+
+// collageLayout is the grid size and cells of a collage layout
+type collageLayout struct {
+	Cols, Rows int
+	Cells      []collageCell
+}
+
+var collageLayouts = map[int]collageLayout{
+	CollageGrid:  {2, 2, []collageCell{{0, 0, 1, 1}, {1, 0, 1, 1}, {0, 1, 1, 1}, {1, 1, 1, 1}}},
+	CollageOneUp: {2, 2, []collageCell{{0, 0, 1, 2}, {1, 0, 1, 1}, {1, 1, 1, 1}}},
+	CollageStrip: {3, 1, []collageCell{{0, 0, 1, 1}, {1, 0, 1, 1}, {2, 0, 1, 1}}},
+}
+
+// getRect returns the position of the cell in pixels on a slide of the size with the gutter around each cell
+func (c collageCell) getRect(l collageLayout, w int, h int, gutter int) image.Rectangle {
+	uw := (w - gutter*(l.Cols+1)) / l.Cols
+	uh := (h - gutter*(l.Rows+1)) / l.Rows
+	x := gutter + c.X*(uw+gutter)
+	y := gutter + c.Y*(uh+gutter)
+	return image.Rect(x, y, x+c.W*uw+(c.W-1)*gutter, y+c.H*uh+(c.H-1)*gutter)
+}
+
+// composeCollage draws the images into the cells of the layout and saves the slide to the path.
+// The credits of the images are combined into the credit of the slide.
+func composeCollage(il []DisplayImage, l collageLayout, fp string, w int, h int, gutter int, radius int, mode int) (DisplayImage, error) {
+	di := DisplayImage{Name: "Collage"}
+	dc := gg.NewContext(w, h)
+	dc.SetColor(color.Black)
+	dc.Clear()
+
+	cl := []string{}
+	for n, c := range l.Cells {
+		i := il[n]
+		img, err := openImage(i.ImagePath)
+		if err != nil {
+			return di, err
+		}
+		r := c.getRect(l, w, h, gutter)
+		if radius > 0 {
+			dc.DrawRoundedRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), float64(radius))
+			dc.Clip()
+		}
+		dc.DrawImage(fitImage(img, r.Dx(), r.Dy(), mode), r.Min.X, r.Min.Y)
+		dc.ResetClip()
+
+		found := false
+		for _, s := range cl {
+			found = found || s == i.GetCredit()
+		}
+		if !found && i.GetCredit() != "" {
+			cl = append(cl, i.GetCredit())
+		}
+	}
+	di.Copyright = strings.Join(cl, " | ")
+	di.ImagePath = fp
+	return di, dc.SavePNG(fp)
+}
+
+// addCollages inserts a collage slide, made from the images in the list, every CollageEvery slides
+func (d *Display) addCollages(l []DisplayImage, path string) []DisplayImage {
+	every := d.Srv.Config.CollageEvery
+	if every < 2 {
+		return l
+	}
+	// Use the single images, not the composed slides, and each image only once as
+	// the favourites are repeated in the list
+	il := []DisplayImage{}
+	used := map[string]bool{}
+	for _, i := range l {
+		if len(i.Parts) == 0 && !used[i.ImagePath] {
+			used[i.ImagePath] = true
+			il = append(il, i)
+		}
+	}
+	if len(il) < 3 {
+		return l
+	}
+
+	xRes, yRes := d.Srv.Config.GetResolution()
+	rl := []DisplayImage{}
+	next, count := 0, 0
+	for _, i := range l {
+		rl = append(rl, i)
+		if (len(rl)+1)%every != 0 {
+			continue
+		}
+		lt := d.Srv.Config.CollageLayout
+		if _, ok := collageLayouts[lt]; !ok {
+			lt = CollageGrid + count%len(collageLayouts)
+		}
+		cl := collageLayouts[lt]
+		if len(cl.Cells) > len(il) {
+			cl = collageLayouts[CollageStrip]
+		}
+		ci := []DisplayImage{}
+		for range cl.Cells {
+			ci = append(ci, il[next%len(il)])
+			next++
+		}
+		fp := filepath.Join(path, fmt.Sprintf("collage%d.png", count))
+		di, err := composeCollage(ci, cl, fp, xRes, yRes, d.Srv.Config.CollageGutter, d.Srv.Config.CollageRadius, d.Srv.Config.GetFitMode())
+		if err != nil {
+			d.logError("Error composing collage. ", err.Error())
+			continue
+		}
+		rl = append(rl, di)
+		count++
+	}
+	return rl
+}
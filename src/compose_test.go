@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestCanPairPortraitImages(t *testing.T) {
+	day := func(d int) *ImageMetadata {
+		return &ImageMetadata{Taken: time.Date(2023, 12, d, 10, 0, 0, 0, time.Local)}
+	}
+	l := []DisplayImage{
+		{Name: "a", ImagePath: "a", Album: "Holiday", Meta: day(1)},
+		{Name: "b", ImagePath: "b", Album: "Home", Meta: day(2)},
+		{Name: "land", ImagePath: "land", Album: "Holiday"},
+		{Name: "c", ImagePath: "c", Album: "Home", Meta: day(1)},
+		{Name: "d", ImagePath: "d", Album: "Holiday", Meta: day(5)},
+		{Name: "e", ImagePath: "e", Meta: day(2)},
+	}
+	portrait := []bool{true, true, false, true, true, true}
+
+	pl := pairImages(l, portrait)
+	// a pairs with d (same album), b with c (same album), e is left over
+	if len(pl) != 2 || pl[0] != [2]int{0, 4} || pl[1] != [2]int{1, 3} {
+		t.Error("Unexpected pairs", pl)
+	}
+}
+
+func TestCanComposePair(t *testing.T) {
+	path := t.TempDir()
+	a := DisplayImage{Name: "a.png", Copyright: "Alice", ImagePath: filepath.Join(path, "a.png")}
+	b := DisplayImage{Name: "b.png", Caption: "Beach", ImagePath: filepath.Join(path, "b.png")}
+	imaging.Save(imaging.New(300, 600, color.White), a.ImagePath)
+	imaging.Save(imaging.New(300, 600, color.White), b.ImagePath)
+	if !isPortrait(a) {
+		t.Error("Image not detected as portrait")
+	}
+
+	di, err := composePair(a, b, filepath.Join(path, "pair.png"), 800, 480, 8, FitCrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := imaging.Open(di.ImagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 800 || img.Bounds().Dy() != 480 {
+		t.Error("Unexpected slide size", img.Bounds())
+	}
+	if r, _, _, _ := img.At(400, 240).RGBA(); r != 0 {
+		t.Error("Gutter not drawn")
+	}
+	if r, _, _, _ := img.At(200, 240).RGBA(); r == 0 {
+		t.Error("Left image not drawn")
+	}
+	if len(di.Parts) != 2 || di.Parts[0].GetCredit() != "Alice" || di.Parts[1].GetCredit() != "Beach" {
+		t.Error("Credits not kept", di.Parts)
+	}
+}
+
+func TestCanDetectRotatedPortrait(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "photo.jpg")
+	writeTestJPEG(t, fp, 40, 20, buildTestExif(6))
+	if !isPortrait(DisplayImage{ImagePath: fp}) {
+		t.Error("Rotated image not detected as portrait")
+	}
+}
+
+func TestCanComposeCollages(t *testing.T) {
+	path := t.TempDir()
+	il := []DisplayImage{}
+	for n, c := range []string{"Alice", "Bob", "Alice", "Carol"} {
+		i := DisplayImage{Name: c, Copyright: c, ImagePath: filepath.Join(path, fmt.Sprintf("%d.png", n))}
+		imaging.Save(imaging.New(400, 300, color.White), i.ImagePath)
+		il = append(il, i)
+	}
+
+	for lt, cl := range collageLayouts {
+		di, err := composeCollage(il, cl, filepath.Join(path, fmt.Sprintf("collage%d.png", lt)), 800, 480, 8, 20, FitCrop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := imaging.Open(di.ImagePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Dx() != 800 || img.Bounds().Dy() != 480 {
+			t.Error("Unexpected collage size", img.Bounds())
+		}
+		// The corner of the first cell is rounded and its centre is drawn
+		r := cl.Cells[0].getRect(cl, 800, 480, 8)
+		if c, _, _, _ := img.At(r.Min.X+1, r.Min.Y+1).RGBA(); c != 0 {
+			t.Error("Corner not rounded in layout", lt)
+		}
+		if c, _, _, _ := img.At((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2).RGBA(); c == 0 {
+			t.Error("Image not drawn in layout", lt)
+		}
+		if lt == CollageGrid && di.Copyright != "Alice | Bob | Carol" {
+			t.Error("Unexpected credit", di.Copyright)
+		}
+	}
+}
+
+func TestCanAddCollageEveryFewSlides(t *testing.T) {
+	path := t.TempDir()
+	l := []DisplayImage{}
+	for n := 0; n < 8; n++ {
+		i := DisplayImage{Name: fmt.Sprint(n), ImagePath: filepath.Join(path, fmt.Sprintf("%d.png", n))}
+		imaging.Save(imaging.New(400, 300, color.White), i.ImagePath)
+		l = append(l, i)
+	}
+	c := Config{CollageEvery: 3, CollageGutter: 8}
+	d := Display{Srv: &Server{Config: &c}}
+
+	rl := d.addCollages(l, path)
+	if len(rl) != 12 {
+		t.Fatal("Expected 12 slides, got", len(rl))
+	}
+	for n, i := range rl {
+		if (n+1)%3 == 0 && i.Name != "Collage" {
+			t.Error("Slide", n+1, "is not a collage")
+		}
+	}
+}
+
+func TestCanAddCollageWithoutRepeatedImages(t *testing.T) {
+	path := t.TempDir()
+	l := []DisplayImage{}
+	for n := 0; n < 4; n++ {
+		i := DisplayImage{Name: fmt.Sprint(n), Copyright: fmt.Sprint("Credit ", n), ImagePath: filepath.Join(path, fmt.Sprintf("%d.png", n))}
+		imaging.Save(imaging.New(400, 300, color.White), i.ImagePath)
+		l = append(l, i)
+	}
+	// The favourite is repeated in the list
+	l = append(l, l[0], l[0])
+	c := Config{CollageEvery: 2, CollageLayout: CollageGrid, CollageGutter: 8}
+	d := Display{Srv: &Server{Config: &c}}
+
+	count := 0
+	for _, i := range d.addCollages(l, path) {
+		if i.Name != "Collage" {
+			continue
+		}
+		count++
+		if n := len(strings.Split(i.Copyright, " | ")); n != 4 {
+			t.Error("Expected 4 different images in the collage, got", i.Copyright)
+		}
+	}
+	if count < 2 {
+		t.Error("Expected at least 2 collages, got", count)
+	}
+}
@@ -10,17 +10,89 @@ import (
 
 // Config holds the configuration required for the Soil Monitor module.
 type Config struct {
-	Resolution  int    `json:"resolution"`  // Resolution of the display, 0=800x480
-	Provider    int    `json:"provider"`    // Image of the Day provider
-	ImgCount    int    `json:"imgcount"`    // NUmber of images to retrieve
-	Weather     bool   `json:"weather"`     // Display weather data
-	WeatherUrl  string `json:"weatherurl"`  // Url for the weather service
-	Calendar    bool   `json:"calendar"`    // Display calendar data
-	Loadshed    bool   `json:"loadshed"`    // Display Load shedding data
-	LoadshedUrl string `json:"loadshedurl"` // Url for the load shedding service
-	USBPath     string `json:"usbPath"`     // Path to the USB shared folder
-	RefreshWait int    `json:"refreshwait"` // Number of seconds to wait between stop and start usb
-	Compression int    `json:"compression"` // JPEG Compression to use
+	Resolution          int               `json:"resolution"`          // Resolution of the display, 0=800x480
+	Provider            int               `json:"provider"`            // Image of the Day provider
+	ImgCount            int               `json:"imgcount"`            // NUmber of images to retrieve
+	Weather             bool              `json:"weather"`             // Display weather data
+	WeatherUrl          string            `json:"weatherurl"`          // Url for the weather service
+	HourlyChart         bool              `json:"hourlychart"`         // Display the hourly forecast chart
+	HourlyHours         int               `json:"hourlyhours"`         // Number of hours shown on the hourly forecast chart, from 12 to 24, defaults to 24
+	HourlyUrl           string            `json:"hourlyurl"`           // Url of the Open-Meteo forecast API used when the weather service has no hourly forecast
+	Latitude            float64           `json:"latitude"`            // Latitude of the frame, used for the hourly forecast
+	Longitude           float64           `json:"longitude"`           // Longitude of the frame, used for the hourly forecast
+	Alerts              bool              `json:"alerts"`              // Display a banner with the most severe weather alert
+	AlertFeeds          []string          `json:"alertfeeds"`          // Urls of the CAP alerts, Atom feeds of CAP alerts, Met Norway MetAlerts or OpenWeatherMap One Call API
+	AlertCheckMins      int               `json:"alertcheckmins"`      // Number of minutes between checks for new alerts, -1 for none, read when the schedule starts
+	Calendar            bool              `json:"calendar"`            // Display calendar data
+	Loadshed            bool              `json:"loadshed"`            // Display Load shedding data
+	LoadshedUrl         string            `json:"loadshedurl"`         // Url for the load shedding service
+	USBPath             string            `json:"usbPath"`             // Path to the USB shared folder
+	RefreshWait         int               `json:"refreshwait"`         // Number of seconds to wait between stop and start usb
+	Compression         int               `json:"compression"`         // JPEG Compression to use
+	HTTPTimeout         int               `json:"httptimeout"`         // Number of seconds to wait for a network request
+	HTTPRetries         int               `json:"httpretries"`         // Number of times a failed network request is retried, -1 for none
+	HTTPProxy           string            `json:"httpproxy"`           // Url of the proxy server, the environment settings are used if blank
+	HTTPMaxMB           int               `json:"httpmaxmb"`           // Maximum size of a network response in MB
+	DownloadWorkers     int               `json:"downloadworkers"`     // Number of images downloaded at the same time
+	CacheQuotaMB        int               `json:"cachequotamb"`        // Maximum size of the image folders in MB, the least recently shown images are removed first
+	FitMode             int               `json:"fitmode"`             // Mode used to fit the images to the display. 0=Crop, 1=Smart crop, 2=Letterbox
+	ProviderFitModes    map[int]int       `json:"providerfitmodes"`    // Fit mode for each provider, overrides FitMode
+	PairPortraits       bool              `json:"pairportraits"`       // Show two portrait images side by side on a landscape display
+	PairGutter          int               `json:"pairgutter"`          // Width of the gap between paired images in pixels
+	CollageEvery        int               `json:"collageevery"`        // Every nth slide is a collage, 0=No collages
+	CollageLayout       int               `json:"collagelayout"`       // Layout of the collages. 0=All in turn, 1=2x2 grid, 2=One large and two small, 3=3 columns
+	CollageGutter       int               `json:"collagegutter"`       // Width of the gap around the images in a collage in pixels
+	CollageRadius       int               `json:"collageradius"`       // Radius of the rounded corners of the images in a collage in pixels
+	OverlayStyle        int               `json:"overlaystyle"`        // Style used to keep the overlay text readable. 0=Auto, 1=Shadow, 2=Outline, 3=Scrim, 4=Panel
+	WidgetStyles        map[string]int    `json:"widgetstyles"`        // Overlay style for each widget (temp, humidity, loadshed, sun, wind, moon, forecast, hourly, days, calendar, calnames, credit, alert), overrides OverlayStyle
+	ScrimOpacity        int               `json:"scrimopacity"`        // Opacity of the scrims and panels behind the overlay text in percent
+	FontFamily          string            `json:"fontfamily"`          // Font used for the overlay text, either the name of a font in html/assets/font or the path to a TrueType file
+	FontFamilies        map[string]string `json:"fontfamilies"`        // Font for each widget, overrides FontFamily
+	FallbackFonts       []string          `json:"fallbackfonts"`       // Fonts used for the characters missing from the font, defaults to the installed DejaVu, Noto and Symbola fonts
+	Locale              string            `json:"locale"`              // Language of the overlay text and the configuration page, e.g. en, af, de or fr
+	TempUnit            string            `json:"tempunit"`            // Unit of the temperatures shown. C or F
+	WindUnit            string            `json:"windunit"`            // Unit of the wind speed shown. kmh, ms, mph or bft for the Beaufort scale
+	PressureUnit        string            `json:"pressureunit"`        // Unit of the air pressure shown. hpa, inhg or mmhg
+	WeatherTempUnit     string            `json:"weathertempunit"`     // Unit of the temperatures sent by the weather service. C or F
+	WeatherWindUnit     string            `json:"weatherwindunit"`     // Unit of the wind speed sent by the weather service. kmh, ms or mph
+	WeatherPressureUnit string            `json:"weatherpressureunit"` // Unit of the air pressure sent by the weather service. hpa, inhg or mmhg
+	FavWeight           int               `json:"favweight"`           // Number of times a favourite image is shown per rebuild
+	BingMarkets         []string          `json:"bingmarkets"`         // Bing markets to get the images of the day from, e.g. en-ZA
+	BingArchiveDays     int               `json:"bingarchivedays"`     // Number of days of Bing images to keep for rotation
+	PexelsKey           string            `json:"pexelskey"`           // Pexels API key, defaults to the PEXELS_API_KEY environment variable
+	PexelsQuery         string            `json:"pexelsquery"`         // Pexels search query, curated photos are shown if blank
+	PexelsColor         string            `json:"pexelscolor"`         // Pexels search colour, e.g. blue or #ffffff
+	UnsplashKey         string            `json:"unsplashkey"`         // Unsplash API access key
+	UnsplashMode        int               `json:"unsplashmode"`        // Unsplash mode, 0=random, 1=collection, 2=topic, 3=search
+	UnsplashQuery       string            `json:"unsplashquery"`       // Unsplash collection IDs, topic slugs or search query
+	ApodKey             string            `json:"apodkey"`             // NASA API key for the Astronomy Picture of the Day
+	FeedUrls            []string          `json:"feedurls"`            // Urls of the RSS, Atom or Media RSS image feeds
+	WebDAVUrl           string            `json:"webdavurl"`           // Url of the WebDAV collection holding the images
+	WebDAVUser          string            `json:"webdavuser"`          // WebDAV user name
+	WebDAVPassword      string            `json:"webdavpassword"`      // WebDAV password or app token
+	WebDAVRecursive     bool              `json:"webdavrecursive"`     // Include the images in sub collections
+	WebDAVCacheMB       int               `json:"webdavcachemb"`       // Maximum size of the WebDAV cache folder in MB
+	S3Endpoint          string            `json:"s3endpoint"`          // Url of the S3 compatible service
+	S3Region            string            `json:"s3region"`            // Region of the S3 bucket
+	S3Bucket            string            `json:"s3bucket"`            // Name of the S3 bucket
+	S3Prefix            string            `json:"s3prefix"`            // Key prefix of the album in the bucket
+	S3AccessKey         string            `json:"s3accesskey"`         // S3 access key ID
+	S3SecretKey         string            `json:"s3secretkey"`         // S3 secret access key
+	S3PathStyle         bool              `json:"s3pathstyle"`         // Use path style addressing (e.g. MinIO)
+	GalleryUrl          string            `json:"galleryurl"`          // Url of the Immich or PhotoPrism server
+	GalleryKey          string            `json:"gallerykey"`          // Immich API key or PhotoPrism app password
+	GalleryMode         int               `json:"gallerymode"`         // Gallery mode, 0=random from library, 1=album, 2=person
+	GalleryQuery        string            `json:"galleryquery"`        // Gallery album or person ID
+	CommandPath         string            `json:"commandpath"`         // Path to the executable that lists the images
+	CommandArgs         []string          `json:"commandargs"`         // Arguments passed to the executable
+	CommandTimeout      int               `json:"commandtimeout"`      // Number of seconds the executable is allowed to run
+	EmailServer         string            `json:"emailserver"`         // Url of the IMAP server, e.g. imaps://imap.example.com
+	EmailUser           string            `json:"emailuser"`           // IMAP user name
+	EmailPassword       string            `json:"emailpassword"`       // IMAP password
+	EmailMailbox        string            `json:"emailmailbox"`        // Mailbox that is checked for new images
+	EmailFolder         string            `json:"emailfolder"`         // Mailbox the processed messages are moved to
+	EmailSenders        []string          `json:"emailsenders"`        // Email addresses allowed to send images
+	EmailTrustSender    bool              `json:"emailtrustsender"`    // Accept the envelope sender when the IMAP server does not add an Authentication-Results header
 }
 
 // GetResolution returns the required image resolution (x,y)
@@ -37,6 +109,72 @@ func (c *Config) GetResolution() (int, int) {
 	return xRes, yRes
 }
 
+// GetOrientation returns the orientation of the display (landscape, portrait or squarish)
+func (c *Config) GetOrientation() string {
+	xRes, yRes := c.GetResolution()
+	switch {
+	case xRes > yRes:
+		return "landscape"
+	case xRes < yRes:
+		return "portrait"
+	default:
+		return "squarish"
+	}
+}
+
+// GetFitMode returns the mode used to fit the images from the current provider to the display
+func (c *Config) GetFitMode() int {
+	if m, ok := c.ProviderFitModes[c.Provider]; ok {
+		return m
+	}
+	return c.FitMode
+}
+
+// GetOverlayStyle returns the style used to draw the text of the widget
+func (c *Config) GetOverlayStyle(widget string) int {
+	if s, ok := c.WidgetStyles[widget]; ok {
+		return s
+	}
+	return c.OverlayStyle
+}
+
+// GetFontFamily returns the font used to draw the text of the widget
+func (c *Config) GetFontFamily(widget string) string {
+	if f, ok := c.FontFamilies[widget]; ok && f != "" {
+		return f
+	}
+	return c.FontFamily
+}
+
+// GetLocale returns the locale of the overlay text and the configuration page
+func (c *Config) GetLocale() Locale {
+	return GetLocale(c.Locale)
+}
+
+// GetUnits returns the units the weather readings are shown in
+func (c *Config) GetUnits() Units {
+	return newUnits(c.TempUnit, c.WindUnit, c.PressureUnit)
+}
+
+// GetWeatherUnits returns the units of the readings sent by the weather service
+func (c *Config) GetWeatherUnits() Units {
+	return newUnits(c.WeatherTempUnit, c.WeatherWindUnit, c.WeatherPressureUnit)
+}
+
+// GetHourlyHours returns the number of hours shown on the hourly forecast chart
+func (c *Config) GetHourlyHours() int {
+	if c.HourlyHours == 0 {
+		return 24
+	}
+	if c.HourlyHours < 12 {
+		return 12
+	}
+	if c.HourlyHours > 24 {
+		return 24
+	}
+	return c.HourlyHours
+}
+
 // ReadFromFile will read the configuration settings from the specified file
 func (c *Config) ReadFromFile(path string) error {
 	_, err := os.Stat(path)
@@ -71,9 +209,17 @@ func (c *Config) ReadFrom(r io.ReadCloser) error {
 	return err
 }
 
-// WriteTo serializes the entity and writes it to the http response
+// WriteTo serializes the entity, without the keys and passwords, and writes it to the http response
 func (c *Config) WriteTo(w http.ResponseWriter) error {
-	b, err := json.Marshal(c)
+	v := *c
+	v.PexelsKey = ""
+	v.UnsplashKey = ""
+	v.ApodKey = ""
+	v.WebDAVPassword = ""
+	v.S3SecretKey = ""
+	v.GalleryKey = ""
+	v.EmailPassword = ""
+	b, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
@@ -127,4 +273,61 @@ func (c *Config) SetDefaults() {
 	if c.Compression < 20 || c.Compression > 90 {
 		c.Compression = 80
 	}
+	if c.HTTPTimeout < 1 {
+		c.HTTPTimeout = 30
+	}
+	if c.HTTPRetries == 0 {
+		c.HTTPRetries = 2
+	}
+	if c.HTTPMaxMB < 1 {
+		c.HTTPMaxMB = 50
+	}
+	if c.DownloadWorkers < 1 {
+		c.DownloadWorkers = 4
+	}
+	if c.CacheQuotaMB < 1 {
+		c.CacheQuotaMB = 800
+	}
+	if c.AlertCheckMins == 0 {
+		c.AlertCheckMins = 5
+	}
+	if c.PairGutter < 1 {
+		c.PairGutter = 8
+	}
+	if c.CollageGutter < 1 {
+		c.CollageGutter = 8
+	}
+	if c.ScrimOpacity < 1 || c.ScrimOpacity > 100 {
+		c.ScrimOpacity = 50
+	}
+	if c.FavWeight < 1 {
+		c.FavWeight = 2
+	}
+	if len(c.BingMarkets) == 0 {
+		c.BingMarkets = []string{"en-ZA"}
+	}
+	if c.BingArchiveDays < 1 {
+		c.BingArchiveDays = 30
+	}
+	if c.ApodKey == "" {
+		c.ApodKey = "DEMO_KEY"
+	}
+	if c.WebDAVCacheMB < 1 {
+		c.WebDAVCacheMB = 500
+	}
+	if c.S3Endpoint == "" {
+		c.S3Endpoint = "https://s3.amazonaws.com"
+	}
+	if c.S3Region == "" {
+		c.S3Region = "us-east-1"
+	}
+	if c.CommandTimeout < 1 {
+		c.CommandTimeout = 60
+	}
+	if c.EmailMailbox == "" {
+		c.EmailMailbox = "INBOX"
+	}
+	if c.EmailFolder == "" {
+		c.EmailFolder = "Photoframe"
+	}
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCanHideSecretsFromConfig(t *testing.T) {
+	s := Server{Config: &Config{}}
+	s.Config.PexelsKey = "pexels-hunter2"
+	s.Config.UnsplashKey = "unsplash-hunter2"
+	s.Config.ApodKey = "apod-hunter2"
+	s.Config.WebDAVPassword = "webdav-hunter2"
+	s.Config.S3SecretKey = "s3-hunter2"
+	s.Config.GalleryKey = "gallery-hunter2"
+	s.Config.EmailPassword = "email-hunter2"
+	s.Config.UnsplashQuery = "frame"
+	c := ConfigController{Srv: &s}
+
+	w := httptest.NewRecorder()
+	c.handleGetConfig(w, httptest.NewRequest("GET", "/config", nil))
+	b := w.Body.String()
+	if strings.Contains(b, "hunter2") {
+		t.Error("Expected the keys and passwords to be hidden, got", b)
+	}
+	if !strings.Contains(b, `"unsplashquery":"frame"`) {
+		t.Error("Expected the other settings to be returned, got", b)
+	}
+	if s.Config.UnsplashKey != "unsplash-hunter2" {
+		t.Error("Expected the configuration to keep the key")
+	}
+}
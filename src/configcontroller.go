@@ -19,8 +19,15 @@ type ConfigPageData struct {
 	Resolution     int
 	Provider       int
 	ImgCount       int
+	FitMode        int
 	EnableWeather  string
+	EnablePairing  string
 	EnableCalendar string
+	EnableHourly   string
+	EnableAlerts   string
+	Locale         string
+	Locales        []Locale
+	Units          Units
 }
 
 // AddController adds the controller routes to the router
@@ -34,19 +41,33 @@ func (c *ConfigController) AddController(router *mux.Router, s *Server) {
 }
 
 func (c *ConfigController) handleConfigWebPage(w http.ResponseWriter, r *http.Request) {
-	t := template.Must(template.ParseFiles("./html/config.html"))
+	loc := c.Srv.Config.GetLocale()
+	t := template.Must(template.New("config.html").Funcs(template.FuncMap{"t": loc.T}).ParseFiles("./html/config.html"))
 
 	v := ConfigPageData{
+		Locale:     loc.Code,
+		Locales:    GetLocales(),
+		Units:      c.Srv.Config.GetUnits(),
 		Resolution: c.Srv.Config.Resolution,
 		Provider:   c.Srv.Config.Provider,
 		ImgCount:   c.Srv.Config.ImgCount,
+		FitMode:    c.Srv.Config.GetFitMode(),
 	}
 	if c.Srv.Config.Weather {
 		v.EnableWeather = "checked"
 	}
+	if c.Srv.Config.PairPortraits {
+		v.EnablePairing = "checked"
+	}
 	if c.Srv.Config.Calendar {
 		v.EnableCalendar = "checked"
 	}
+	if c.Srv.Config.HourlyChart {
+		v.EnableHourly = "checked"
+	}
+	if c.Srv.Config.Alerts {
+		v.EnableAlerts = "checked"
+	}
 
 	err := t.Execute(w, v)
 	if err != nil {
@@ -62,39 +83,69 @@ func (c *ConfigController) handleGetConfig(w http.ResponseWriter, r *http.Reques
 
 func (c *ConfigController) handleSetConfig(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
+	tr := c.Srv.Config.GetLocale().T
 
 	res := r.Form.Get("resolution")
 	pro := r.Form.Get("provider")
 	img := r.Form.Get("imgcount")
+	fit := r.Form.Get("fitmode")
+	lang := r.Form.Get("locale")
+	tu := r.Form.Get("tempunit")
+	wu := r.Form.Get("windunit")
+	pu := r.Form.Get("pressureunit")
 
 	weather := r.Form.Get("weather")
 	calendar := r.Form.Get("calendar")
+	pairing := r.Form.Get("pairing")
+	hourly := r.Form.Get("hourly")
+	alerts := r.Form.Get("alerts")
 
 	if res == "" {
-		http.Error(w, "The Resolution must be specified", 500)
+		http.Error(w, tr("The Resolution must be specified"), 500)
 		return
 	}
 	resv, err := strconv.Atoi(res)
 	if err != nil || resv != 0 {
-		http.Error(w, "Invalid Resolution value", 500)
+		http.Error(w, tr("Invalid Resolution value"), 500)
 		return
 	}
 	if pro == "" {
-		http.Error(w, "The Image Provider must be selected", 500)
+		http.Error(w, tr("The Image Provider must be selected"), 500)
 		return
 	}
 	prov, err := strconv.Atoi(pro)
-	if err != nil || prov < 0 || prov > 4 {
-		http.Error(w, "Invalid Image Provider value", 500)
+	if err != nil || prov < 0 || prov > 14 {
+		http.Error(w, tr("Invalid Image Provider value"), 500)
 		return
 	}
 	if img == "" {
-		http.Error(w, "The Image Count must be provided", 500)
+		http.Error(w, tr("The Image Count must be provided"), 500)
 		return
 	}
 	imgv, err := strconv.Atoi(img)
 	if err != nil || imgv <= 0 {
-		http.Error(w, "Image Count must be greater than zero", 500)
+		http.Error(w, tr("Image Count must be greater than zero"), 500)
+		return
+	}
+
+	fitv := -1
+	if fit != "" {
+		fitv, err = strconv.Atoi(fit)
+		if err != nil || fitv < FitCrop || fitv > FitLetterbox {
+			http.Error(w, tr("Invalid Fit Mode value"), 500)
+			return
+		}
+	}
+
+	if lang != "" && !hasLocale(lang) {
+		http.Error(w, tr("Invalid Language value"), 500)
+		return
+	}
+
+	if (tu != "" && !isUnit(tu, TempCelsius, TempFahrenheit)) ||
+		(wu != "" && !isUnit(wu, WindKmh, WindMs, WindMph, WindBeaufort)) ||
+		(pu != "" && !isUnit(pu, PressureHpa, PressureInHg, PressureMmHg)) {
+		http.Error(w, tr("Invalid Unit value"), 500)
 		return
 	}
 
@@ -103,8 +154,30 @@ func (c *ConfigController) handleSetConfig(w http.ResponseWriter, r *http.Reques
 	c.Srv.Config.Resolution = resv
 	c.Srv.Config.Provider = prov
 	c.Srv.Config.ImgCount = imgv
+	if fitv >= 0 {
+		// The fit mode is saved against the provider, so each provider keeps its own
+		if c.Srv.Config.ProviderFitModes == nil {
+			c.Srv.Config.ProviderFitModes = map[int]int{}
+		}
+		c.Srv.Config.ProviderFitModes[prov] = fitv
+	}
 	c.Srv.Config.Weather = (weather == "on")
 	c.Srv.Config.Calendar = (calendar == "on")
+	c.Srv.Config.HourlyChart = (hourly == "on")
+	c.Srv.Config.Alerts = (alerts == "on")
+	c.Srv.Config.PairPortraits = (pairing == "on")
+	if lang != "" {
+		c.Srv.Config.Locale = lang
+	}
+	if tu != "" {
+		c.Srv.Config.TempUnit = tu
+	}
+	if wu != "" {
+		c.Srv.Config.WindUnit = wu
+	}
+	if pu != "" {
+		c.Srv.Config.PressureUnit = pu
+	}
 	c.Srv.Config.SetDefaults()
 
 	c.Srv.Config.WriteToFile("config.json")
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// getFreeSpace returns the number of bytes available to the user on the file system holding the path
+func getFreeSpace(path string) (int64, error) {
+	s := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &s); err != nil {
+		return 0, err
+	}
+	return int64(s.Bavail) * int64(s.Bsize), nil
+}
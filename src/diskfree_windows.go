@@ -0,0 +1,21 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// getFreeSpace returns the number of bytes available to the user on the volume holding the path
+func getFreeSpace(path string) (int64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	free := int64(0)
+	proc := syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+	r, _, err := proc.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if r == 0 {
+		return 0, err
+	}
+	return free, nil
+}
@@ -4,35 +4,73 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	gopifinder "github.com/brumawen/gopi-finder/src"
 	"github.com/disintegration/imaging"
 	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
 )
 
 // Display is used to redraw the display images
 type Display struct {
-	Srv       *Server   // Server object
-	LastRun   time.Time // Last run time
-	IsRunning bool      // Indicates if the display build is running
-	LastErr   error     // Last error encountered
-	xBlock    int       // x block width
-	yBlock    int       // y block height
+	Srv       *Server        // Server object
+	LastRun   time.Time      // Last run time
+	IsRunning bool           // Indicates if the display build is running
+	LastErr   error          // Last error encountered
+	Images    []DisplayImage // Provider images currently on the frame, use GetImages to read them
+	Alerts    []Alert        // Alerts currently on the frame, use GetAlerts to read them
+	Weather   Weather        // Forecast used for the last build, use GetWeather to read it
+	mu        sync.Mutex     // Guards Images, Alerts and Weather, which are read while the display is rebuilt
+	build     sync.Mutex     // Held while the display is built, so that only one build runs at a time
+	xBlock    int            // x block width
+	yBlock    int            // y block height
+}
+
+// canvas is the context a widget is drawn with.  Base is the image before anything was drawn on it,
+// used to pick the text colours, or nil to use the image being drawn on.  Widget is the name of the
+// widget, used to pick its overlay style and font.  It is passed by value, so each widget names its own copy.
+type canvas struct {
+	*gg.Context
+	Base   image.Image
+	Widget string
 }
 
 // Run is called from the scheduler (ClockWerk).
+// It waits for a build that is already running to finish first.
 func (d *Display) Run() {
+	d.build.Lock()
+	defer d.build.Unlock()
+	d.run()
+}
+
+// TryRun builds the display images unless a build is already running, in which case false is returned
+func (d *Display) TryRun() bool {
+	if !d.build.TryLock() {
+		return false
+	}
+	defer d.build.Unlock()
+	d.run()
+	return true
+}
+
+func (d *Display) run() {
 	var err error
 
 	d.logInfo("Starting Processing.")
 
 	d.IsRunning = true
+	defer func() {
+		d.IsRunning = false
+	}()
 
 	// Wait until we have an internet connection
 	a := 0
@@ -68,6 +106,12 @@ func (d *Display) Run() {
 	}
 	d.logInfo("Retrieved ", len(l), " image(s) to display from ", n, ".")
 
+	// Remove the banned images and add the favourites
+	l = GetRatings().Apply(l, d.Srv.Config.FavWeight)
+
+	// Get the capture details recorded in the image files
+	l = addMetadata(l, d.Srv.Config.GetLocale())
+
 	// Get the current weather forecast
 	w := Weather{}
 	m := Moon{}
@@ -79,6 +123,9 @@ func (d *Display) Run() {
 			d.LastErr = err
 			return
 		}
+		d.mu.Lock()
+		d.Weather = w
+		d.mu.Unlock()
 
 		// Get the current moon phase
 		d.logInfo("Getting moon information.")
@@ -114,6 +161,16 @@ func (d *Display) Run() {
 		}
 	}
 
+	// Get the severe weather alerts
+	al := []Alert{}
+	if d.Srv.Config.Alerts {
+		d.logInfo("Getting severe weather alerts.")
+		if al, err = GetAlerts(*d.Srv.Config, w); err != nil {
+			// The alerts that were received are still shown
+			d.logError("Error getting severe weather alerts. ", err.Error())
+		}
+	}
+
 	// Process the images
 	d.logInfo("Building display images.")
 	dl, err := d.buildDisplayImages(l, w, m, c, f)
@@ -122,6 +179,20 @@ func (d *Display) Run() {
 		d.LastErr = err
 		return
 	}
+	d.addAlertBanners(dl, al)
+	d.mu.Lock()
+	d.Images = l
+	d.Alerts = al
+	d.mu.Unlock()
+
+	// Remove the least recently shown images if the image folders are too big
+	ic := NewImageCache(*d.Srv.Config)
+	if err := ic.MarkShown(l, time.Now()); err != nil {
+		d.logError("Error saving image shown times. ", err.Error())
+	}
+	if _, err := ic.Enforce(l); err != nil {
+		d.logError("Error enforcing image cache quota. ", err.Error())
+	}
 
 	// Check if the USB folder, where the files for display will be pulled from, exists
 	_, err = os.Stat(d.Srv.Config.USBPath)
@@ -133,41 +204,71 @@ func (d *Display) Run() {
 		}
 
 	} else {
-		d.logInfo("Moving images to the USB folder.")
-
-		// Clear this folder
-		d.logInfo("Clearing old images from the USB folder.")
-		if fi, err := ioutil.ReadDir(d.Srv.Config.USBPath); err == nil {
-			for _, f := range fi {
-				n := f.Name()
-				if n != "System Volume Information" {
-					p := filepath.Join(d.Srv.Config.USBPath, f.Name())
-					err = os.Remove(p)
-					if err != nil {
-						d.logError(fmt.Sprintf("Error removing file '%s'", p))
-					}
-				}
-			}
+		// Translate the images first, to files outside the USB folder, so that the space
+		// they need is known before the old images are removed without holding them in memory
+		d.logInfo("Translating new images for the USB folder.  JPG compression = ", d.Srv.Config.Compression)
+		tp := filepath.Join(downloadPath, "usb")
+		os.RemoveAll(tp)
+		if err := os.MkdirAll(tp, 0777); err != nil {
+			d.logError("Error creating folder for the translated images. ", err.Error())
 		}
-
-		// Move the image files to the folder for display on the Photo Frame
-		d.logInfo("Translating new images into the USB folder.  JPG compression = ", d.Srv.Config.Compression)
+		defer os.RemoveAll(tp)
+		ul := []string{}
+		need := int64(0)
 		for x, i := range dl {
 			//n := filepath.Base(i.ImagePath)
 			//n = strings.TrimSuffix(n, path.Ext(n)) + ".jpg"
 			n = fmt.Sprintf("%4d%02d%02d_%02d%02d_%02d.jpg", time.Now().Year(), time.Now().Month(), time.Now().Day(), time.Now().Hour(), time.Now().Minute(), x)
 			d.logInfo("Translating '", n, "' from '", i.ImagePath, "'")
-			p := filepath.Join(d.Srv.Config.USBPath, n)
-			d.logDebug("Translating image " + p)
-			if img, err := imaging.Open(i.ImagePath); err != nil {
+			if img, err := openImage(i.ImagePath); err != nil {
 				d.logError("Failed to open image for translation. " + err.Error())
+			} else if err = imaging.Save(img, filepath.Join(tp, n), imaging.JPEGQuality(d.Srv.Config.Compression)); err != nil {
+				d.logError("Failed to translate image. " + err.Error())
+			} else if fi, err := os.Stat(filepath.Join(tp, n)); err != nil {
+				d.logError("Failed to get size of translated image. " + err.Error())
 			} else {
-				err = imaging.Save(img, p, imaging.JPEGQuality(d.Srv.Config.Compression))
-				if err != nil {
-					d.logError("Failed to save image to USB display folder. " + err.Error())
+				ul = append(ul, n)
+				need = need + fi.Size()
+			}
+		}
+
+		// Check that the new images fit once the old images have been removed
+		ol := []string{}
+		if fi, err := ioutil.ReadDir(d.Srv.Config.USBPath); err == nil {
+			for _, f := range fi {
+				if f.Name() != "System Volume Information" {
+					ol = append(ol, filepath.Join(d.Srv.Config.USBPath, f.Name()))
+					need = need - f.Size()
 				}
 			}
 		}
+		if free, err := getFreeSpace(d.Srv.Config.USBPath); err != nil {
+			d.logError("Error getting free space in the USB folder. ", err.Error())
+		} else if need > free {
+			err = fmt.Errorf("Not enough space in USB folder '%s'. %d KB more is needed", d.Srv.Config.USBPath, (need-free)/1024)
+			d.logError(err.Error())
+			d.LastErr = err
+			return
+		}
+
+		// Clear this folder
+		d.logInfo("Clearing old images from the USB folder.")
+		for _, p := range ol {
+			err = os.Remove(p)
+			if err != nil {
+				d.logError(fmt.Sprintf("Error removing file '%s'", p))
+			}
+		}
+
+		// Move the image files to the folder for display on the Photo Frame
+		d.logInfo("Moving images to the USB folder.")
+		for _, n := range ul {
+			p := filepath.Join(d.Srv.Config.USBPath, n)
+			d.logDebug("Writing image " + p)
+			if err = copyFile(filepath.Join(tp, n), p); err != nil {
+				d.logError("Failed to save image to USB display folder. " + err.Error())
+			}
+		}
 
 		d.logInfo("Refreshing USB. Refresh wait = ", d.Srv.Config.RefreshWait)
 		d.StopUSB()
@@ -175,7 +276,6 @@ func (d *Display) Run() {
 		d.StartUSB()
 	}
 
-	d.IsRunning = false
 	d.LastErr = nil
 	d.logInfo("Processing complete.")
 }
@@ -227,6 +327,66 @@ func (d *Display) StartUSB() {
 	}()
 }
 
+// copyFile streams the file to the destination, which may be on another file system
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// GetImages returns a copy of the provider images currently on the frame
+func (d *Display) GetImages() []DisplayImage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DisplayImage{}, d.Images...)
+}
+
+// SetImages sets the provider images currently on the frame
+func (d *Display) SetImages(l []DisplayImage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Images = l
+}
+
+// GetAlerts returns a copy of the alerts currently on the frame
+func (d *Display) GetAlerts() []Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Alert{}, d.Alerts...)
+}
+
+// GetWeather returns the forecast used for the last build
+func (d *Display) GetWeather() Weather {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Weather
+}
+
+// RemoveImage removes the provider image from the images currently on the frame
+func (d *Display) RemoveImage(p string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	k := ratingKey(p)
+	l := []DisplayImage{}
+	for _, i := range d.Images {
+		if ratingKey(i.ImagePath) != k {
+			l = append(l, i)
+		}
+	}
+	d.Images = l
+}
+
 func (d *Display) getImageProvider() (ImageProvider, string, error) {
 	switch d.Srv.Config.Provider {
 	case 0:
@@ -254,6 +414,56 @@ func (d *Display) getImageProvider() (ImageProvider, string, error) {
 		p := new(FileFolder)
 		p.SetConfig(*d.Srv.Config)
 		return p, n, nil
+	case 5:
+		n := "Unsplash"
+		p := new(Unsplash)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 6:
+		n := "NASA Astronomy Picture of the Day"
+		p := new(Apod)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 7:
+		n := "Wikimedia Commons Picture of the Day"
+		p := new(Wikimedia)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 8:
+		n := "Image Feed"
+		p := new(Feed)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 9:
+		n := "WebDAV Folder"
+		p := new(WebDAV)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 10:
+		n := "S3 Bucket"
+		p := new(S3)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 11:
+		n := "Immich"
+		p := new(Immich)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 12:
+		n := "PhotoPrism"
+		p := new(PhotoPrism)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 13:
+		n := "External Command"
+		p := new(Command)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
+	case 14:
+		n := "Email"
+		p := new(Email)
+		p.SetConfig(*d.Srv.Config)
+		return p, n, nil
 	default:
 		n := "Unknown Image Provider"
 		return nil, n, fmt.Errorf("Image Provider '%d' is invalid", d.Srv.Config.Provider)
@@ -284,6 +494,12 @@ func (d *Display) buildDisplayImages(dl []DisplayImage, w Weather, m Moon, c Cal
 		}
 	}
 
+	// Show the portrait images in pairs on a landscape display
+	dl = d.pairPortraits(dl, path)
+
+	// Mix collages in with the single image slides
+	dl = d.addCollages(dl, path)
+
 	if d.Srv.Config.Weather || d.Srv.Config.Calendar {
 		n := 0
 		for _, i := range dl {
@@ -303,28 +519,56 @@ func (d *Display) buildDisplayImages(dl []DisplayImage, w Weather, m Moon, c Cal
 		}
 	} else {
 		d.logInfo("Both weather and calendar are turned off.  Using plain images.")
-		rl = dl
+		for n, i := range dl {
+			img, err := d.loadImage(i.ImagePath)
+			if err != nil {
+				d.logError("Error loading image " + i.ImagePath + " - " + err.Error())
+				continue
+			}
+			i.ImagePath = filepath.Join(path, fmt.Sprintf("image%d.png", n))
+			if err = imaging.Save(img, i.ImagePath); err != nil {
+				d.logError("Error saving image. " + err.Error())
+				continue
+			}
+			rl = append(rl, i)
+		}
 	}
 
 	return rl, nil
 }
 
+// loadImage opens the image and fits it to the display using the fit mode of the provider,
+// so that the overlays are always drawn onto an image of the display size
+func (d *Display) loadImage(p string) (image.Image, error) {
+	img, err := openImage(p)
+	if err != nil {
+		return nil, err
+	}
+	xRes, yRes := d.Srv.Config.GetResolution()
+	return fitImage(img, xRes, yRes, d.Srv.Config.GetFitMode()), nil
+}
+
 func (d *Display) buildWeatherImage(n int, i DisplayImage, w Weather, m Moon, f Loadshed) (DisplayImage, error) {
 	// Load the image
 	di := DisplayImage{Name: i.Name}
-	img, err := gg.LoadImage(i.ImagePath)
+	img, err := d.loadImage(i.ImagePath)
 	if err != nil {
 		d.logError("Error loading image " + i.ImagePath + " - " + err.Error())
 		return di, err
 	}
 
 	// Create a context for the image
-	dc := gg.NewContextForImage(img)
+	dc := canvas{Context: gg.NewContextForImage(img), Base: img}
 
 	// Draw the sections
 	d.drawCurrentTemp(dc, w, 0, 0)
 	d.drawHumidPressure(dc, w, 0, 1)
-	d.drawLoadshed(dc, f, 0, 2)
+	if d.Srv.Config.HourlyChart && len(w.Hourly) > 1 && len(f.Events) == 0 {
+		// The chart takes the bottom row unless there is load shedding to show
+		d.drawHourlyChart(dc, w, 0, 2, 3)
+	} else {
+		d.drawLoadshed(dc, f, 0, 2)
+	}
 	d.drawSunRiseSet(dc, w, 1, 1)
 	d.drawWind(dc, w, 2, 1)
 	d.drawMoon(dc, m, 2, 0)
@@ -338,7 +582,7 @@ func (d *Display) buildWeatherImage(n int, i DisplayImage, w Weather, m Moon, f
 		}
 	}
 
-	d.drawCopyright(dc, i.Copyright)
+	d.drawCredits(dc, i)
 
 	// Save the new image
 	di.ImagePath = filepath.Join("./img/display", fmt.Sprintf("image%d.png", n))
@@ -354,14 +598,14 @@ func (d *Display) buildWeatherImage(n int, i DisplayImage, w Weather, m Moon, f
 func (d *Display) buildCalendarImage(n int, i DisplayImage, c CalEvents) (DisplayImage, error) {
 	// Load the image
 	di := DisplayImage{Name: i.Name}
-	img, err := gg.LoadImage(i.ImagePath)
+	img, err := d.loadImage(i.ImagePath)
 	if err != nil {
 		d.logError("Error loading image " + i.ImagePath + " - " + err.Error())
 		return di, err
 	}
 
 	// Create a context for the image
-	dc := gg.NewContextForImage(img)
+	dc := canvas{Context: gg.NewContextForImage(img), Base: img}
 
 	// Draw the day names
 	now := time.Now()
@@ -373,8 +617,13 @@ func (d *Display) buildCalendarImage(n int, i DisplayImage, c CalEvents) (Displa
 
 	solcol := gg.NewSolidPattern(color.RGBA{0, 0, 0, 128})
 
-	_, h := dc.MeasureString(now.Weekday().String())
+	loc := d.Srv.Config.GetLocale()
+	_, h := dc.MeasureString(loc.DayName(now))
 
+	// The day names are drawn on their own panels, so check the contrast against the panels
+	days := dc
+	days.Widget = "days"
+	days.Base = nil
 	for i := 0; i < 4; i++ {
 		xb := i*d.xBlock + 20
 
@@ -382,7 +631,7 @@ func (d *Display) buildCalendarImage(n int, i DisplayImage, c CalEvents) (Displa
 		dc.DrawRoundedRectangle(float64(xb-10), 5, float64(d.xBlock-20), h+15, 5)
 		dc.Fill()
 
-		d.drawString(dc, cd.Weekday().String(), 20, xb, 10)
+		d.drawString(days, loc.DayName(cd), 20, xb, 10)
 		cd = cd.Add(24 * time.Hour)
 	}
 	ht := int(h + 30)
@@ -402,7 +651,7 @@ func (d *Display) buildCalendarImage(n int, i DisplayImage, c CalEvents) (Displa
 
 	d.drawCalNames(dc, 3, 3)
 
-	d.drawCopyright(dc, i.Copyright)
+	d.drawCredits(dc, i)
 
 	// Save the new image
 	di.ImagePath = filepath.Join("./img/display", fmt.Sprintf("image%d.png", n))
@@ -414,15 +663,39 @@ func (d *Display) buildCalendarImage(n int, i DisplayImage, c CalEvents) (Displa
 	return di, err
 }
 
-func (d *Display) drawCopyright(dc *gg.Context, cw string) {
+// drawCredits draws the credit of the image, or the credit of each image in a composed slide under its part
+func (d *Display) drawCredits(dc canvas, i DisplayImage) {
+	dc.Widget = "credit"
+	if len(i.Parts) == 0 {
+		d.drawCopyright(dc, i.GetCredit())
+		return
+	}
+	pw := dc.Width() / len(i.Parts)
+	for n, p := range i.Parts {
+		if cw := p.GetCredit(); cw != "" {
+			max := pw - 30
+			if n == len(i.Parts)-1 {
+				max = pw - 100
+			}
+			cw = ellipsize(d.setFont(dc, 14), cw, float64(max))
+			d.drawString(dc, cw, 14, n*pw+20, dc.Height()-16)
+		}
+	}
+	d.drawCopyright(dc, "")
+}
+
+func (d *Display) drawCopyright(dc canvas, cw string) {
 	if cw != "" {
+		// Leave room for the time
+		cw = ellipsize(d.setFont(dc, 14), cw, float64(dc.Width()-100))
 		d.drawString(dc, cw, 14, 20, dc.Height()-16)
 	}
-	ts := time.Now().Format("15:04")
+	ts := d.Srv.Config.GetLocale().FormatClock(time.Now())
 	d.drawString(dc, ts, 12, dc.Width()-56, dc.Height()-18)
 }
 
-func (d *Display) drawCurrentTemp(dc *gg.Context, w Weather, xq int, yq int) {
+func (d *Display) drawCurrentTemp(dc canvas, w Weather, xq int, yq int) {
+	dc.Widget = "temp"
 	xb := xq*d.xBlock + 15
 	yb := yq*d.yBlock + 10
 	// Draw the icon
@@ -431,14 +704,16 @@ func (d *Display) drawCurrentTemp(dc *gg.Context, w Weather, xq int, yq int) {
 	}
 	// Draw the weather description
 	if w.Current.WeatherDesc != "" {
-		d.drawString(dc, w.Current.WeatherDesc, 24, xb+10, yb+70)
+		d.drawString(dc, d.Srv.Config.GetLocale().T(w.Current.WeatherDesc), 24, xb+10, yb+70)
 	}
 	// Draw the temperature
-	temp := fmt.Sprintf("%.1f", w.Current.Temp)
-	d.drawString(dc, temp, 50, xb+100, yb+10)
+	u := d.Srv.Config.GetUnits()
+	temp := u.FormatTemp(w.Current.Temp, d.Srv.Config.GetWeatherUnits(), 1)
+	d.drawValue(dc, temp, unitLabel(u.Temp), 50, xb+100, yb+10)
 }
 
-func (d *Display) drawHumidPressure(dc *gg.Context, w Weather, xq int, yq int) {
+func (d *Display) drawHumidPressure(dc canvas, w Weather, xq int, yq int) {
+	dc.Widget = "humidity"
 	xb := xq*d.xBlock + 15
 	yb := yq * d.yBlock
 
@@ -447,8 +722,8 @@ func (d *Display) drawHumidPressure(dc *gg.Context, w Weather, xq int, yq int) {
 		dc.DrawImage(img, xb, yb)
 	}
 	// Draw the humidity value
-	h := fmt.Sprintf("%.1f", w.Current.Humidity)
-	d.drawString(dc, h, 20, xb+60, yb+12)
+	h := fmt.Sprintf("%.0f", w.Current.Humidity)
+	d.drawValue(dc, h, "%", 20, xb+60, yb+12)
 
 	yb = yb + 55
 
@@ -457,12 +732,13 @@ func (d *Display) drawHumidPressure(dc *gg.Context, w Weather, xq int, yq int) {
 		dc.DrawImage(img, xb+4, yb)
 	}
 	// Draw the pressure value
-	p := fmt.Sprintf("%.1f", w.Current.Pressure)
-	d.drawString(dc, p, 20, xb+60, yb+12)
-
+	u := d.Srv.Config.GetUnits()
+	p := u.FormatPressure(w.Current.Pressure, d.Srv.Config.GetWeatherUnits())
+	d.drawValue(dc, p, unitLabel(u.Pressure), 20, xb+60, yb+12)
 }
 
-func (d *Display) drawLoadshed(dc *gg.Context, f Loadshed, xq int, yq int) {
+func (d *Display) drawLoadshed(dc canvas, f Loadshed, xq int, yq int) {
+	dc.Widget = "loadshed"
 	xb := xq*d.xBlock + 18
 	yb := yq * d.yBlock
 
@@ -479,17 +755,22 @@ func (d *Display) drawLoadshed(dc *gg.Context, f Loadshed, xq int, yq int) {
 	yb = yq * d.yBlock
 	db := 1
 	day := ""
+	loc := d.Srv.Config.GetLocale()
 
 	for i, e := range f.Events {
+		ed := loc.ShortDayOf(e.Day)
+		if !e.Start.IsZero() {
+			ed = loc.ShortDayName(e.Start)
+		}
 		if i == 0 {
-			day = e.Day[:3]
+			day = ed
 			d.drawString(dc, day, 20, xb-50, yb)
 			t := fmt.Sprintf("%s (%d)", e.Display, e.Stage)
 			d.drawString(dc, t, 20, xb, yb)
 			yb = yb + 30
 		} else {
-			if e.Day[:3] != day {
-				day = e.Day[:3]
+			if ed != day {
+				day = ed
 				if db == 1 {
 					db = 2
 					xb = (xq + 2) * d.xBlock
@@ -508,7 +789,8 @@ func (d *Display) drawLoadshed(dc *gg.Context, f Loadshed, xq int, yq int) {
 	}
 }
 
-func (d *Display) drawSunRiseSet(dc *gg.Context, w Weather, xq int, yq int) {
+func (d *Display) drawSunRiseSet(dc canvas, w Weather, xq int, yq int) {
+	dc.Widget = "sun"
 	xb := xq * d.xBlock
 	yb := yq * d.yBlock
 
@@ -517,7 +799,8 @@ func (d *Display) drawSunRiseSet(dc *gg.Context, w Weather, xq int, yq int) {
 		dc.DrawImage(img, xb, yb)
 	}
 	// Draw the sunrise time
-	t := w.Current.Sunrise.Format("3:04PM")
+	loc := d.Srv.Config.GetLocale()
+	t := loc.FormatTime(w.Current.Sunrise)
 	d.drawString(dc, t, 20, xb+60, yb+12)
 
 	yb = yb + 55
@@ -527,11 +810,12 @@ func (d *Display) drawSunRiseSet(dc *gg.Context, w Weather, xq int, yq int) {
 		dc.DrawImage(img, xb, yb)
 	}
 	// Draw the sunset time
-	t = w.Current.Sunset.Format("3:04PM")
+	t = loc.FormatTime(w.Current.Sunset)
 	d.drawString(dc, t, 20, xb+60, yb+12)
 }
 
-func (d *Display) drawWind(dc *gg.Context, w Weather, xq int, yq int) {
+func (d *Display) drawWind(dc canvas, w Weather, xq int, yq int) {
+	dc.Widget = "wind"
 	xb := xq * d.xBlock
 	yb := yq * d.yBlock
 
@@ -541,11 +825,15 @@ func (d *Display) drawWind(dc *gg.Context, w Weather, xq int, yq int) {
 		dc.DrawImage(newImg, xb, yb)
 	}
 	// Draw the wind speed value
-	s := fmt.Sprintf("%.1f", w.Current.WindSpeed)
-	d.drawString(dc, s, 20, xb+60, yb+12)
+	u := d.Srv.Config.GetUnits()
+	s := u.FormatWind(w.Current.WindSpeed, d.Srv.Config.GetWeatherUnits())
+	d.drawValue(dc, s, unitLabel(u.Wind), 20, xb+60, yb+12)
+	// Draw the compass point the wind is blowing from
+	d.drawString(dc, d.Srv.Config.GetLocale().CompassPoint(w.Current.WindDirection), 16, xb+60, yb+40)
 }
 
-func (d *Display) drawMoon(dc *gg.Context, m Moon, xq int, yq int) {
+func (d *Display) drawMoon(dc canvas, m Moon, xq int, yq int) {
+	dc.Widget = "moon"
 	xb := xq * d.xBlock
 	yb := yq * d.yBlock
 
@@ -555,14 +843,16 @@ func (d *Display) drawMoon(dc *gg.Context, m Moon, xq int, yq int) {
 	}
 	// Draw the moon description
 	if m.PhaseName != "" {
-		d.drawString(dc, m.PhaseName, 15, xb+10, yb+70)
+		d.drawString(dc, d.Srv.Config.GetLocale().T(m.PhaseName), 15, xb+10, yb+70)
 	}
 }
 
-func (d *Display) drawForecast(dc *gg.Context, w Weather, i int, xq int, yq int) {
+func (d *Display) drawForecast(dc canvas, w Weather, i int, xq int, yq int) {
+	dc.Widget = "forecast"
 	xb := xq*d.xBlock - 15
 	yb := yq * d.yBlock
 	fd := w.Forecast[i]
+	loc := d.Srv.Config.GetLocale()
 
 	// Draw the icon
 	if img, err := d.getWeatherIconImage(fd.WeatherIcon); err == nil {
@@ -570,76 +860,59 @@ func (d *Display) drawForecast(dc *gg.Context, w Weather, i int, xq int, yq int)
 	}
 	// Draw the weather description
 	if fd.WeatherDesc != "" {
-		d.drawString(dc, fd.WeatherDesc, 16, xb+10, yb+70)
+		d.drawString(dc, loc.T(fd.WeatherDesc), 16, xb+10, yb+70)
 	}
 	// Draw the day name
-	if fd.Name != "" {
+	if !fd.Day.IsZero() {
+		d.drawString(dc, loc.DayName(fd.Day), 20, xb+100, yb+10)
+	} else if fd.Name != "" {
 		d.drawString(dc, fd.Name, 20, xb+100, yb+10)
 	}
 	// Draw the temperature
-	temp := fmt.Sprintf("%.0f / %.0f", fd.TempMax, fd.TempMin)
-	d.drawString(dc, temp, 20, xb+100, yb+40)
+	u := d.Srv.Config.GetUnits()
+	wu := d.Srv.Config.GetWeatherUnits()
+	temp := u.FormatTemp(fd.TempMax, wu, 0) + " / " + u.FormatTemp(fd.TempMin, wu, 0)
+	d.drawValue(dc, temp, unitLabel(u.Temp), 20, xb+100, yb+40)
 }
 
-func (d *Display) drawCalEvent(dc *gg.Context, e CalEvent, xq int, y int) int {
+func (d *Display) drawCalEvent(dc canvas, e CalEvent, xq int, y int) int {
+	dc.Widget = "calendar"
 	xb := xq*d.xBlock + 20
 	gap := 15
 	fsize := 20
+	max := float64(d.xBlock - 10)
 
-	if err := dc.LoadFontFace("./html/assets/font/Roboto-Black.ttf", float64(fsize)); err != nil {
-		d.logError("Error loading font. " + err.Error())
-	}
-
-	var t string
-	var w float64
-	var h float64
-	var max float64
+	face := d.setFont(dc, fsize)
+	_, h := dc.MeasureString(e.Summary)
 
 	if e.Duration != "All Day" {
 		//t = fmt.Sprintf("%s (%s)", e.Time, e.Duration)
-		t = fmt.Sprintf("%s", e.Time)
-
-		w, h = dc.MeasureString(t)
-		max = float64(d.xBlock - 10)
-		for w > max {
-			t = t[:len(t)-1]
-			w, h = dc.MeasureString(t)
-		}
-		t = strings.TrimSpace(t)
-
+		t := ellipsize(face, strings.TrimSpace(e.Time), max)
 		d.drawColourString(dc, t, fsize, e.Colour, int(xb), y)
 
 		y = y + int(h) + 5
 	}
 
-	sm := strings.Replace(e.Summary, "'s birthday", "", -1)
-
-	t = fmt.Sprintf("%s", sm)
-	t = strings.TrimSpace(t)
-
-	w, h = dc.MeasureString(t)
-	max = float64(d.xBlock - 10)
-	for w > max {
-		t = t[:len(t)-1]
-		w, h = dc.MeasureString(t)
+	// Long summaries are wrapped onto a second line
+	sm := d.Srv.Config.GetLocale().StripBirthday(e.Summary)
+	for n, t := range wrapText(face, sm, max, 2) {
+		if n > 0 {
+			y = y + int(h) + 5
+		}
+		d.drawColourString(dc, t, fsize, e.Colour, int(xb), y)
 	}
-	t = strings.TrimSpace(t)
-
-	d.drawColourString(dc, t, fsize, e.Colour, int(xb), y)
 
 	return y + int(h) + gap
 }
 
-func (d *Display) drawCalNames(dc *gg.Context, xq int, yq int) {
+func (d *Display) drawCalNames(dc canvas, xq int, yq int) {
+	dc.Widget = "calnames"
 	nl, err := GetCalendarNames()
 	if err != nil {
 		d.logError("Failed to get Calendar Names. " + err.Error())
 	} else {
 		fsize := 20
-
-		if err := dc.LoadFontFace("./html/assets/font/Roboto-Black.ttf", float64(fsize)); err != nil {
-			d.logError("Error loading font. " + err.Error())
-		}
+		d.setFont(dc, fsize)
 
 		yb := d.yBlock * yq
 		xb := d.xBlock * xq
@@ -691,34 +964,60 @@ func (d *Display) getMoonIconImage(i float32) (image.Image, error) {
 	return gg.LoadImage(p)
 }
 
-func (d *Display) drawString(dc *gg.Context, s string, h int, x int, y int) {
-	//if int(dc.FontHeight()) != h {
-	if err := dc.LoadFontFace("./html/assets/font/Roboto-Black.ttf", float64(h)); err != nil {
+func (d *Display) drawString(dc canvas, s string, h int, x int, y int) {
+	d.setFont(dc, h)
+	d.drawStyledString(dc, s, nil, x, y)
+}
+
+// setFont sets the font of the widget at the size in points, and returns the face.
+// The faces are cached, so the font file is only read once.
+func (d *Display) setFont(dc canvas, h int) font.Face {
+	face, err := GetFontCache().GetFace(d.Srv.Config.GetFontFamily(dc.Widget), float64(h), getFallbackFonts(*d.Srv.Config))
+	if err != nil {
 		d.logError("Error loading font. " + err.Error())
+		face, err = GetFontCache().GetFace(defaultFontFamily, float64(h), nil)
+		if err != nil {
+			face = basicfont.Face7x13
+		}
 	}
-	//}
-	_, sh := dc.MeasureString(s)
-
-	dc.SetColor(color.Black)
-	dc.DrawString(s, float64(x+1), float64(y+1)+sh)
-
-	dc.SetColor(color.White)
-	dc.DrawString(s, float64(x), float64(y)+sh)
+	dc.SetFontFace(face)
+	return face
 }
 
-func (d *Display) drawColourString(dc *gg.Context, s string, h int, c string, x int, y int) {
-	if int(dc.FontHeight()) != h {
-		if err := dc.LoadFontFace("./html/assets/font/Roboto-Black.ttf", float64(h)); err != nil {
-			d.logError("Error loading font. " + err.Error())
-		}
+// drawValue draws the value with the label of its unit after it in a smaller font on the same baseline
+func (d *Display) drawValue(dc canvas, v string, unit string, h int, x int, y int) {
+	face := d.setFont(dc, h)
+	vw := measureText(face, v)
+	_, vh := dc.MeasureString(v)
+	d.drawStyledString(dc, v, nil, x, y)
+	if unit == "" {
+		return
 	}
-	_, sh := dc.MeasureString(s)
+	uh := h / 2
+	if uh < 12 {
+		uh = 12
+	}
+	d.setFont(dc, uh)
+	_, h2 := dc.MeasureString(unit)
+	d.drawStyledString(dc, unit, nil, x+int(vw)+3, y+int(vh-h2))
+}
 
-	dc.SetColor(color.Black)
-	dc.DrawString(s, float64(x+1), float64(y+1)+sh)
+func (d *Display) drawColourString(dc canvas, s string, h int, c string, x int, y int) {
+	d.setFont(dc, h)
+	d.drawStyledString(dc, s, d.getColour(c), x, y)
+}
 
-	dc.SetColor(d.getColour(c))
-	dc.DrawString(s, float64(x), float64(y)+sh)
+// drawStyledString draws the text in the overlay style of the widget,
+// picking the text colour from the image behind it if no colour is specified
+func (d *Display) drawStyledString(dc canvas, s string, c color.Color, x int, y int) {
+	img := dc.Base
+	if img == nil {
+		img = dc.Image()
+	}
+	w, h := dc.MeasureString(s)
+	r := image.Rect(x-4, y-2, x+int(w)+4, y+int(h)+6)
+	ts := chooseTextStyle(img, r, d.Srv.Config.GetOverlayStyle(dc.Widget), c, d.Srv.Config.ScrimOpacity)
+	drawStyledString(dc.Context, s, float64(x), float64(y), ts)
 }
 
 func (d *Display) getColour(c string) color.Color {
@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
 
 func TestCanBuildDisplayImages(t *testing.T) {
 	c := Config{}
@@ -14,3 +18,39 @@ func TestCanBuildDisplayImages(t *testing.T) {
 		t.Error(d.LastErr)
 	}
 }
+
+func TestCanRemoveDisplayImage(t *testing.T) {
+	d := Display{}
+	d.SetImages([]DisplayImage{{ImagePath: "./img/bing/a.jpg"}, {ImagePath: "img/bing/b.jpg"}})
+	l := d.GetImages()
+	d.RemoveImage("img/bing/a.jpg")
+	if n := len(d.GetImages()); n != 1 {
+		t.Error("Expected 1 image, got", n)
+	}
+	// The copy returned earlier is not changed
+	if len(l) != 2 {
+		t.Error("Expected the copy to keep 2 images, got", len(l))
+	}
+}
+
+func TestCanCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.jpg")
+	dst := filepath.Join(dir, "dst.jpg")
+	ioutil.WriteFile(src, []byte("image data"), 0666)
+	if err := copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(dst); err != nil || string(b) != "image data" {
+		t.Error("Unexpected copy", string(b), err)
+	}
+}
+
+func TestCanSkipBuildWhileRunning(t *testing.T) {
+	d := Display{}
+	d.build.Lock()
+	if d.TryRun() {
+		t.Error("Expected the build to be skipped while another build is running")
+	}
+	d.build.Unlock()
+}
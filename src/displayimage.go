@@ -1,8 +1,70 @@
 package main
 
+import (
+	"strings"
+	"time"
+)
+
 // DisplayImage holds the details about an image that will be used for display
 type DisplayImage struct {
 	Name      string
 	Copyright string
+	Caption   string
 	ImagePath string
+	Album     string         `json:",omitempty"` // Album or folder the image came from
+	Meta      *ImageMetadata `json:",omitempty"` // Metadata read from the image file, if it has any
+	Parts     []DisplayImage `json:",omitempty"` // Images composed into this slide, each with its own credit
+}
+
+// GetCredit returns the text that is drawn along the bottom of the image
+func (i DisplayImage) GetCredit() string {
+	if i.Caption == "" {
+		return i.Copyright
+	}
+	if i.Copyright == "" {
+		return i.Caption
+	}
+	return i.Caption + " - " + i.Copyright
+}
+
+// addMetadata reads the metadata from the image files that have not been read yet.
+// The caption is taken from the metadata if the provider did not set one.
+func addMetadata(l []DisplayImage, loc Locale) []DisplayImage {
+	for n, i := range l {
+		if i.Meta != nil {
+			continue
+		}
+		m, err := ReadMetadata(i.ImagePath)
+		if err != nil {
+			continue
+		}
+		l[n].Meta = &m
+		if i.Caption == "" {
+			l[n].Caption = m.GetCaption(loc)
+		}
+	}
+	return l
+}
+
+// getGalleryCaption builds an image caption from the people, place and date
+// recorded against a photo in a self-hosted gallery
+func getGalleryCaption(people []string, place []string, taken time.Time, loc Locale) string {
+	s := []string{}
+	if len(people) != 0 {
+		s = append(s, strings.Join(people, ", "))
+	}
+	pl := []string{}
+	for _, p := range place {
+		p = strings.TrimSpace(p)
+		if p != "" && (len(pl) == 0 || pl[len(pl)-1] != p) {
+			pl = append(pl, p)
+		}
+	}
+	if len(pl) != 0 {
+		s = append(s, strings.Join(pl, ", "))
+	}
+	if !taken.IsZero() {
+		s = append(s, loc.FormatDate(taken))
+	}
+	return strings.Join(s, " - ")
 }
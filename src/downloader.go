@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// downloadPath is the folder holding the partial downloads, outside of the image folders
+// so that the partial files are not removed when a provider cleans up its folder
+const downloadPath = "./download"
+
+// DownloadJob holds the details about an image file to download
+type DownloadJob struct {
+	URL    string                  // Url of the image
+	Path   string                  // Path the image file is written to once it has been downloaded and verified
+	Width  int                     // Width the resized image must cover, the image is not resized if 0
+	Height int                     // Height the resized image must cover
+	Header http.Header             // Headers sent with the request, e.g. to authenticate with the server
+	Sign   func(req *http.Request) // Signs the request once all its headers have been set, e.g. for S3
+}
+
+// downloadState is saved next to a partial download so that it can be resumed
+type downloadState struct {
+	URL  string `json:"url"`  // Url the partial download came from, after any redirects
+	ETag string `json:"etag"` // ETag of the partial download
+}
+
+// Downloader downloads image files concurrently using a pool of workers.
+// Partial downloads are resumed using HTTP Range requests, and an image
+// is only moved into place once it has been verified by decoding it.
+type Downloader struct {
+	Workers  int                                          // Number of files downloaded at the same time
+	Progress func(j DownloadJob, done int64, total int64) // Called as each file is downloaded, total is -1 if unknown
+	Client   *HTTPClient                                  // Client used for the downloads, defaults to the shared client
+}
+
+// NewDownloader creates a Downloader using the number of workers in the configuration,
+// logging the progress of each file
+func NewDownloader(c Config) *Downloader {
+	d := &Downloader{Workers: c.DownloadWorkers}
+	d.Progress = d.logProgress()
+	return d
+}
+
+// logProgress returns a Progress function that logs each time another quarter of a file has been downloaded
+func (d *Downloader) logProgress() func(DownloadJob, int64, int64) {
+	m := sync.Mutex{}
+	last := map[string]int64{}
+	return func(j DownloadJob, done int64, total int64) {
+		if total <= 0 {
+			return
+		}
+		pc := done * 100 / total / 25 * 25
+		m.Lock()
+		defer m.Unlock()
+		if pc >= 100 {
+			delete(last, j.Path)
+			return
+		}
+		if pc > last[j.Path] {
+			last[j.Path] = pc
+			d.LogInfo("Downloading ", j.URL, " ", pc, "%")
+		}
+	}
+}
+
+// Download downloads the jobs and returns the error for each job, in the same order as the jobs.
+// A file listed more than once is only downloaded once.
+func (d *Downloader) Download(jl []DownloadJob) []error {
+	el := make([]error, len(jl))
+	if len(jl) == 0 {
+		return el
+	}
+	if err := os.MkdirAll(downloadPath, 0777); err != nil {
+		for n := range el {
+			el[n] = err
+		}
+		return el
+	}
+
+	// Point each job at the first job with the same path
+	first := make([]int, len(jl))
+	pl := map[string]int{}
+	ql := []int{}
+	for n, j := range jl {
+		if f, ok := pl[j.Path]; ok {
+			first[n] = f
+			continue
+		}
+		pl[j.Path] = n
+		first[n] = n
+		ql = append(ql, n)
+	}
+
+	w := d.Workers
+	if w < 1 {
+		w = 1
+	}
+	if w > len(ql) {
+		w = len(ql)
+	}
+	q := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < w; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range q {
+				el[n] = d.downloadFile(jl[n])
+			}
+		}()
+	}
+	for _, n := range ql {
+		q <- n
+	}
+	close(q)
+	wg.Wait()
+
+	for n, f := range first {
+		el[n] = el[f]
+	}
+	return el
+}
+
+// downloadFile downloads the image to a partial file, resuming a previous download
+// if there is one, then verifies it and moves it to the final path
+func (d *Downloader) downloadFile(j DownloadJob) error {
+	h := sha1.Sum([]byte(j.Path + "\n" + j.URL))
+	pp := filepath.Join(downloadPath, fmt.Sprintf("%x.part", h))
+	sp := pp + ".json"
+
+	c := d.Client
+	if c == nil {
+		c = GetHTTPClient()
+	}
+	var err error
+	for n := 0; n <= c.Retries; n++ {
+		if err = d.fetch(c, j, pp, sp); err == nil {
+			break
+		}
+		if he, ok := err.(*HTTPError); ok && he.StatusCode < 500 {
+			// The server won't have the file next time either
+			break
+		}
+	}
+	if err != nil {
+		d.LogError("Error downloading ", j.URL, ". ", err.Error())
+		return err
+	}
+	os.Remove(sp)
+
+	// Make sure the file is a valid image before it is used
+	img, err := openImage(pp)
+	if err != nil {
+		os.Remove(pp)
+		return fmt.Errorf("The file downloaded from %s is not a valid image. %s", j.URL, err.Error())
+	}
+	if j.Width > 0 && j.Height > 0 {
+		tp := filepath.Join(downloadPath, fmt.Sprintf("%x%s", h, filepath.Ext(j.Path)))
+		img = coverImage(img, j.Width, j.Height)
+		if err = imaging.Save(img, tp); err != nil {
+			os.Remove(tp)
+			return err
+		}
+		os.Remove(pp)
+		pp = tp
+	}
+	if err = os.Rename(pp, j.Path); err != nil {
+		os.Remove(pp)
+		return err
+	}
+	d.LogInfo("Downloaded ", j.URL, " to ", j.Path)
+	return nil
+}
+
+// fetch downloads the file to the partial file, resuming from the end of the partial file
+func (d *Downloader) fetch(c *HTTPClient, j DownloadJob, pp string, sp string) error {
+	st := downloadState{URL: j.URL}
+	f, err := os.OpenFile(pp, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	start := fi.Size()
+	if start > 0 {
+		if b, err := ioutil.ReadFile(sp); err == nil {
+			json.Unmarshal(b, &st)
+		}
+	}
+
+	req, err := http.NewRequest("GET", st.URL, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range j.Header {
+		req.Header[k] = v
+	}
+	if start > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+		if st.ETag != "" {
+			// Get the whole file again if it has changed
+			req.Header.Set("If-Range", st.ETag)
+		}
+	}
+	if j.Sign != nil {
+		j.Sign(req)
+	}
+	res, err := c.Do(req)
+	if res != nil {
+		defer res.Body.Close()
+		res.Close = true
+	}
+	if err != nil {
+		return err
+	}
+
+	total := int64(-1)
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", start)) {
+			return fmt.Errorf("Unexpected range %s from %s", res.Header.Get("Content-Range"), j.URL)
+		}
+		if i := strings.LastIndex(res.Header.Get("Content-Range"), "/"); i >= 0 {
+			if t, err := strconv.ParseInt(res.Header.Get("Content-Range")[i+1:], 10, 64); err == nil {
+				total = t
+			}
+		}
+	case http.StatusOK:
+		// The server sent the whole file
+		start = 0
+		if res.ContentLength >= 0 {
+			total = res.ContentLength
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if start > 0 {
+			// The partial file is already complete
+			return nil
+		}
+		fallthrough
+	default:
+		return &HTTPError{URL: req.URL.Redacted(), StatusCode: res.StatusCode, Status: res.Status}
+	}
+	if err = f.Truncate(start); err != nil {
+		return err
+	}
+	if _, err = f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Save where the file came from, so the download can be resumed if it fails part way
+	st = downloadState{URL: res.Request.URL.String(), ETag: res.Header.Get("ETag")}
+	if b, err := json.Marshal(st); err == nil {
+		ioutil.WriteFile(sp, b, 0666)
+	}
+
+	pw := &downloadProgress{d: d, j: j, done: start, total: total}
+	_, err = io.Copy(f, io.TeeReader(res.Body, pw))
+	if err == nil && total >= 0 && pw.done != total {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// downloadProgress reports the progress of a download as the data is written
+type downloadProgress struct {
+	d     *Downloader
+	j     DownloadJob
+	done  int64
+	total int64
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if p.d.Progress != nil {
+		p.d.Progress(p.j, p.done, p.total)
+	}
+	return len(b), nil
+}
+
+// LogInfo is used to log information messages for this controller.
+func (d *Downloader) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Downloader: [Inf] ", a)
+	} else {
+		fmt.Println("Downloader: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (d *Downloader) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Downloader: [Err] ", a)
+	} else {
+		fmt.Println("Downloader: [Err] ", a)
+	}
+}
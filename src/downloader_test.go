@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+func getTestPNG(t *testing.T) []byte {
+	b := bytes.Buffer{}
+	if err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestDownloaderDownloadsConcurrently(t *testing.T) {
+	img := getTestPNG(t)
+	m := sync.Mutex{}
+	active, max := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		active++
+		if active > max {
+			max = active
+		}
+		m.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		m.Lock()
+		active--
+		m.Unlock()
+		w.Write(img)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	jl := []DownloadJob{}
+	for n := 0; n < 6; n++ {
+		jl = append(jl, DownloadJob{URL: fmt.Sprintf("%s/%d.png", srv.URL, n), Path: filepath.Join(dir, fmt.Sprintf("%d.png", n)), Width: 20, Height: 20})
+	}
+	done := map[string]int64{}
+	d := Downloader{Workers: 2, Client: NewHTTPClient(Config{HTTPTimeout: 5, HTTPRetries: -1})}
+	d.Progress = func(j DownloadJob, n int64, total int64) {
+		m.Lock()
+		defer m.Unlock()
+		if total != int64(len(img)) {
+			t.Error("Unexpected total", total)
+		}
+		done[j.Path] = n
+	}
+	for n, err := range d.Download(jl) {
+		if err != nil {
+			t.Error("Download", n, "failed.", err)
+		}
+	}
+	if max != 2 {
+		t.Error("Expected 2 downloads at the same time, got", max)
+	}
+	for _, j := range jl {
+		if done[j.Path] != int64(len(img)) {
+			t.Error("Progress not reported for", j.Path, done[j.Path])
+		}
+		i, err := imaging.Open(j.Path)
+		if err != nil {
+			t.Error(err)
+		} else if i.Bounds().Dx() != 27 || i.Bounds().Dy() != 20 {
+			t.Error("Image not resized", i.Bounds())
+		}
+	}
+}
+
+func TestDownloaderResumesPartialDownload(t *testing.T) {
+	img := getTestPNG(t)
+	half := len(img) / 2
+	ranges := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved.png" {
+			http.Redirect(w, r, "/img.png", http.StatusFound)
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") == "" {
+			// Drop the connection part way through the file
+			w.Header().Set("Content-Length", fmt.Sprint(len(img)))
+			w.Write(img[:half])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if r.Header.Get("Range") != fmt.Sprintf("bytes=%d-", half) || r.Header.Get("If-Range") != `"v1"` {
+			t.Error("Unexpected range request", r.Header)
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(img)-1, len(img)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(img[half:])
+	}))
+	defer srv.Close()
+
+	fp := filepath.Join(t.TempDir(), "img.png")
+	d := Downloader{Workers: 1, Client: NewHTTPClient(Config{HTTPTimeout: 5, HTTPRetries: -1})}
+	j := DownloadJob{URL: srv.URL + "/moved.png", Path: fp}
+	if el := d.Download([]DownloadJob{j}); el[0] == nil {
+		t.Fatal("Expected the first download to fail")
+	}
+	if _, err := os.Stat(fp); !os.IsNotExist(err) {
+		t.Error("Partial download written to the image path")
+	}
+	if el := d.Download([]DownloadJob{j}); el[0] != nil {
+		t.Fatal("Download not resumed.", el[0])
+	}
+	b, err := ioutil.ReadFile(fp)
+	if err != nil || !bytes.Equal(b, img) {
+		t.Error("Resumed file does not match", err)
+	}
+	if len(ranges) != 2 || ranges[1] == "" {
+		t.Error("Unexpected requests", ranges)
+	}
+}
+
+func TestDownloaderRejectsCorruptImage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>Not an image</html>"))
+	}))
+	defer srv.Close()
+
+	fp := filepath.Join(t.TempDir(), "bad.jpg")
+	d := Downloader{Workers: 1, Client: NewHTTPClient(Config{HTTPTimeout: 5, HTTPRetries: -1})}
+	if el := d.Download([]DownloadJob{{URL: srv.URL, Path: fp}}); el[0] == nil {
+		t.Error("Expected the corrupt image to be rejected")
+	}
+	if _, err := os.Stat(fp); !os.IsNotExist(err) {
+		t.Error("Corrupt image written to the image path")
+	}
+}
+
+func TestDownloaderSendsHeaders(t *testing.T) {
+	img := getTestPNG(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		w.Write(img)
+	}))
+	defer srv.Close()
+
+	fp := filepath.Join(t.TempDir(), "auth.png")
+	d := Downloader{Workers: 1, Client: NewHTTPClient(Config{HTTPTimeout: 5, HTTPRetries: -1})}
+	j := DownloadJob{URL: srv.URL, Path: fp, Header: http.Header{"X-Api-Key": {"secret"}}}
+	if el := d.Download([]DownloadJob{j}); el[0] != nil {
+		t.Error("Download failed.", el[0])
+	}
+	if _, err := os.Stat(fp); err != nil {
+		t.Error("Image not written to the image path", err)
+	}
+}
+
+func TestDownloaderDownloadsEachFileOnce(t *testing.T) {
+	img := getTestPNG(t)
+	calls := 0
+	m := sync.Mutex{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		calls++
+		m.Unlock()
+		if r.URL.Path == "/bad.png" {
+			http.Error(w, "Not Found", 404)
+			return
+		}
+		w.Write(img)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	jl := []DownloadJob{
+		{URL: srv.URL + "/a.png", Path: filepath.Join(dir, "a.png")},
+		{URL: srv.URL + "/bad.png", Path: filepath.Join(dir, "bad.png")},
+		{URL: srv.URL + "/a.png", Path: filepath.Join(dir, "a.png")},
+		{URL: srv.URL + "/bad.png", Path: filepath.Join(dir, "bad.png")},
+	}
+	d := Downloader{Workers: 4, Client: NewHTTPClient(Config{HTTPTimeout: 5, HTTPRetries: -1})}
+	el := d.Download(jl)
+	if el[0] != nil || el[2] != nil || el[1] == nil || el[3] == nil {
+		t.Error("Unexpected errors", el)
+	}
+	if calls != 2 {
+		t.Error("Expected each file to be downloaded once, got", calls, "requests")
+	}
+}
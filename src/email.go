@@ -0,0 +1,580 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+const emailCaptionFile = "emailcaptions.json"
+
+var imapLiteralRegex = regexp.MustCompile(`\{(\d+)\}\r\n$`)
+var emailReplyRegex = regexp.MustCompile(`(?i)^((re|fwd?|aw|wg)\s*:\s*)+`)
+var emailCommentRegex = regexp.MustCompile(`\([^)]*\)`)
+
+// Email is an image provider that polls an IMAP mailbox for photos emailed
+// to the frame by a set of allowed senders. The attachments are stored
+// in the FileFolder store and the subject is used as the caption.
+type Email struct {
+	Config Config
+}
+
+// SetConfig sets the configuration for this provider
+func (p *Email) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *Email) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Checking mailbox for new images.")
+
+	// The images already in the store are still shown if the mailbox can't be reached
+	if err := p.poll(); err != nil {
+		p.LogError("Error checking mailbox. ", err.Error())
+	}
+
+	f := FileFolder{Config: p.Config}
+	l, err := f.GetImages()
+	if err != nil {
+		return l, err
+	}
+
+	// Add the captions of the emailed images
+	cl := p.readCaptions()
+	nl := map[string]string{}
+	for n, i := range l {
+		if c, ok := cl[i.Name]; ok {
+			l[n].Caption = c
+			nl[i.Name] = c
+		}
+	}
+	if len(nl) != len(cl) {
+		p.writeCaptions(nl)
+	}
+
+	return l, nil
+}
+
+// poll downloads the images attached to messages from the allowed senders
+// and moves the messages to the processed folder
+func (p *Email) poll() error {
+	if p.Config.EmailServer == "" {
+		return fmt.Errorf("The IMAP server has not been configured")
+	}
+	if len(p.Config.EmailSenders) == 0 {
+		return fmt.Errorf("No allowed email senders have been configured")
+	}
+
+	c, err := dialIMAP(p.Config.EmailServer)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if _, err = c.Cmd("LOGIN %s %s", imapQuote(p.Config.EmailUser), imapQuote(p.Config.EmailPassword)); err != nil {
+		return err
+	}
+	move := false
+	if rl, err := c.Cmd("CAPABILITY"); err == nil {
+		for _, r := range rl {
+			if strings.HasPrefix(r.Text, "* CAPABILITY ") && strings.Contains(r.Text+" ", " MOVE ") {
+				move = true
+			}
+		}
+	}
+	if _, err = c.Cmd("SELECT %s", imapQuote(p.Config.EmailMailbox)); err != nil {
+		return err
+	}
+
+	// Find the messages from the allowed senders
+	ul := []string{}
+	found := map[string]bool{}
+	for _, s := range p.Config.EmailSenders {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		rl, err := c.Cmd("UID SEARCH FROM %s", imapQuote(s))
+		if err != nil {
+			return err
+		}
+		for _, r := range rl {
+			if !strings.HasPrefix(r.Text, "* SEARCH") {
+				continue
+			}
+			for _, u := range strings.Fields(r.Text)[2:] {
+				if !found[u] {
+					found[u] = true
+					ul = append(ul, u)
+				}
+			}
+		}
+	}
+	if len(ul) == 0 {
+		return nil
+	}
+
+	path := "./img/filefolder"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return err
+		}
+	}
+	// Make sure the folder for the processed messages exists, this fails if it already does
+	c.Cmd("CREATE %s", imapQuote(p.Config.EmailFolder))
+
+	cl := p.readCaptions()
+	expunge := false
+	for _, u := range ul {
+		// Check the sender before getting the whole message
+		m, err := p.fetch(c, u, "HEADER")
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			continue
+		}
+		from, err := mail.ParseAddress(m.Header.Get("From"))
+		if err != nil || !p.isAllowed(from.Address) {
+			// The search matches on part of the address, so this is someone else
+			continue
+		}
+		if !p.isVerified(m.Header, from.Address) {
+			p.LogInfo("Skipping message ", u, " from ", from.Address, ", the sender could not be verified")
+			continue
+		}
+		if m, err = p.fetch(c, u, ""); err != nil {
+			return err
+		}
+		if m == nil {
+			continue
+		}
+		caption := p.getCaption(m.Header.Get("Subject"))
+		p.LogInfo("Processing message '", caption, "' from ", from.Address)
+
+		al, err := p.getAttachments(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+		if err != nil {
+			p.LogError("Error reading attachments from message ", u, ". ", err.Error())
+			continue
+		}
+		for _, a := range al {
+			fn := p.getImageName(a.Name, a.Data)
+			fp := filepath.Join(path, fn)
+			p.LogInfo("Saving attachment ", a.Name, " as ", fn)
+			if err := p.saveImage(fp, a.Data); err != nil {
+				if _, err := os.Stat(fp); err == nil {
+					os.Remove(fp)
+				}
+				continue
+			}
+			if caption != "" {
+				cl[fn] = caption
+			}
+		}
+
+		// Move the message out of the mailbox so it isn't processed again
+		if move {
+			_, err = c.Cmd("UID MOVE %s %s", u, imapQuote(p.Config.EmailFolder))
+		} else {
+			_, err = c.Cmd("UID COPY %s %s", u, imapQuote(p.Config.EmailFolder))
+			if err == nil {
+				_, err = c.Cmd("UID STORE %s +FLAGS.SILENT (\\Deleted)", u)
+				expunge = true
+			}
+		}
+		if err != nil {
+			p.LogError("Error moving message ", u, ". ", err.Error())
+		}
+	}
+	p.writeCaptions(cl)
+	if expunge {
+		c.Cmd("EXPUNGE")
+	}
+	c.Cmd("LOGOUT")
+
+	return nil
+}
+
+// fetch gets the section of the message with the UID without marking it as read,
+// returning nil if the message can't be read
+func (p *Email) fetch(c *imapClient, u string, section string) (*mail.Message, error) {
+	rl, err := c.Cmd("UID FETCH %s (UID BODY.PEEK[%s])", u, section)
+	if err != nil {
+		return nil, err
+	}
+	var msg []byte
+	for _, r := range rl {
+		if strings.Contains(r.Text, " FETCH ") && len(r.Literals) != 0 {
+			msg = r.Literals[0]
+		}
+	}
+	if msg == nil {
+		return nil, nil
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		p.LogError("Error reading message ", u, ". ", err.Error())
+		return nil, nil
+	}
+	return m, nil
+}
+
+// emailAttachment holds an image attached to a message
+type emailAttachment struct {
+	Name string
+	Data []byte
+}
+
+// getAttachments returns the image attachments in the body of a message part
+func (p *Email) getAttachments(ct string, cte string, body io.Reader) ([]emailAttachment, error) {
+	mt, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil, nil
+	}
+	if strings.HasPrefix(mt, "multipart/") {
+		al := []emailAttachment{}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return al, err
+			}
+			pl, err := p.getAttachments(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return al, err
+			}
+			al = append(al, pl...)
+		}
+		return al, nil
+	}
+
+	switch mt {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, nil
+	}
+	n := params["name"]
+	if pr, ok := body.(*multipart.Part); ok && pr.FileName() != "" {
+		n = pr.FileName()
+	}
+	if strings.EqualFold(strings.TrimSpace(cte), "base64") {
+		body = base64.NewDecoder(base64.StdEncoding, &emailBase64Reader{r: body})
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if n == "" {
+		ext, _ := mime.ExtensionsByType(mt)
+		n = "image"
+		if len(ext) != 0 {
+			n = n + ext[0]
+		}
+	}
+	return []emailAttachment{{Name: n, Data: b}}, nil
+}
+
+// emailBase64Reader strips the line breaks from base64 encoded content
+type emailBase64Reader struct {
+	r io.Reader
+}
+
+func (e *emailBase64Reader) Read(b []byte) (int, error) {
+	n, err := e.r.Read(b)
+	c := 0
+	for _, x := range b[:n] {
+		if x != '\r' && x != '\n' && x != ' ' && x != '\t' {
+			b[c] = x
+			c++
+		}
+	}
+	return c, err
+}
+
+// saveImage writes the attachment to the store once it is known to be an image.
+// The original file is kept, so that the capture details in it are not lost.
+func (p *Email) saveImage(fp string, data []byte) error {
+	if _, err := imaging.Decode(bytes.NewReader(data)); err != nil {
+		p.LogError("Error decoding image ", fp, ". ", err.Error())
+		return err
+	}
+	err := ioutil.WriteFile(fp, data, 0666)
+	if err != nil {
+		p.LogError("Error saving image file ", fp, ". ", err.Error())
+	}
+	return err
+}
+
+// isAllowed checks if the address is in the list of allowed senders.
+// An entry starting with @ allows the whole domain.
+func (p *Email) isAllowed(addr string) bool {
+	addr = strings.ToLower(addr)
+	for _, s := range p.Config.EmailSenders {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if addr == s || (strings.HasPrefix(s, "@") && strings.HasSuffix(addr, s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVerified checks that the sender address of the message was not forged.
+// The results of the checks done by the receiving server in the Authentication-Results header
+// are used, where a DMARC, DKIM or SPF pass must be for the domain of the sender.
+// Only the topmost header is used, as that is the one added by the receiving server.
+// If the server does not add the header the message is rejected, unless EmailTrustSender is set,
+// in which case the envelope sender in the Return-Path must be allowed.  The sender can set the
+// Return-Path, so this is only safe if the server rejects forged senders itself.
+func (p *Email) isVerified(h mail.Header, from string) bool {
+	ar := h["Authentication-Results"]
+	if len(ar) == 0 {
+		if !p.Config.EmailTrustSender {
+			return false
+		}
+		rp, err := mail.ParseAddress(h.Get("Return-Path"))
+		return err == nil && p.isAllowed(rp.Address)
+	}
+
+	dom := emailDomain(from)
+	for _, r := range strings.Split(emailCommentRegex.ReplaceAllString(ar[0], ""), ";")[1:] {
+		f := strings.Fields(r)
+		if len(f) == 0 {
+			continue
+		}
+		props := map[string]string{}
+		for _, s := range f[1:] {
+			if kv := strings.SplitN(s, "=", 2); len(kv) == 2 {
+				props[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+			}
+		}
+		switch strings.ToLower(f[0]) {
+		case "dmarc=pass":
+			if emailDomain(props["header.from"]) == dom {
+				return true
+			}
+		case "dkim=pass":
+			d := emailDomain(props["header.d"])
+			if d == "" {
+				d = emailDomain(props["header.i"])
+			}
+			if d != "" && (d == dom || strings.HasSuffix(dom, "."+d)) {
+				return true
+			}
+		case "spf=pass":
+			if emailDomain(props["smtp.mailfrom"]) == dom {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// emailDomain returns the domain of the email address, or the value itself if it is a domain
+func emailDomain(s string) string {
+	if n := strings.LastIndex(s, "@"); n != -1 {
+		s = s[n+1:]
+	}
+	return strings.ToLower(strings.Trim(s, "<> "))
+}
+
+// getCaption returns the caption from the subject of the message
+func (p *Email) getCaption(s string) string {
+	d := mime.WordDecoder{}
+	if ds, err := d.DecodeHeader(s); err == nil {
+		s = ds
+	}
+	s = emailReplyRegex.ReplaceAllString(strings.TrimSpace(s), "")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// getImageName returns a unique file name in the store for the attachment
+func (p *Email) getImageName(n string, data []byte) string {
+	ext := strings.ToLower(path.Ext(n))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif":
+	default:
+		ext = ".jpg"
+	}
+	return fmt.Sprintf("email_%x%s", sha1.Sum(data), ext)
+}
+
+func (p *Email) readCaptions() map[string]string {
+	cl := map[string]string{}
+	if b, err := ioutil.ReadFile(emailCaptionFile); err == nil {
+		json.Unmarshal(b, &cl)
+	}
+	return cl
+}
+
+func (p *Email) writeCaptions(cl map[string]string) {
+	if b, err := json.Marshal(cl); err == nil {
+		ioutil.WriteFile(emailCaptionFile, b, 0666)
+	}
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *Email) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Email: [Inf] ", a)
+	} else {
+		fmt.Println("Email: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *Email) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Email: [Err] ", a)
+	} else {
+		fmt.Println("Email: [Err] ", a)
+	}
+}
+
+// imapResponse is an untagged response from the IMAP server,
+// along with any literal strings that were sent with it
+type imapResponse struct {
+	Text     string
+	Literals [][]byte
+}
+
+// imapClient is a minimal IMAP4rev1 client with just the commands the Email provider uses
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// dialIMAP connects to the IMAP server at the url (imap:// or imaps://)
+func dialIMAP(s string) (*imapClient, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	d := &net.Dialer{Timeout: 30 * time.Second}
+	switch u.Scheme {
+	case "imaps":
+		h := u.Host
+		if u.Port() == "" {
+			h = net.JoinHostPort(u.Hostname(), "993")
+		}
+		conn, err = tls.DialWithDialer(d, "tcp", h, &tls.Config{ServerName: u.Hostname()})
+	case "imap":
+		h := u.Host
+		if u.Port() == "" {
+			h = net.JoinHostPort(u.Hostname(), "143")
+		}
+		conn, err = d.Dial("tcp", h)
+	default:
+		return nil, fmt.Errorf("IMAP server url '%s' must start with imap:// or imaps://", s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Minute))
+
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	g, err := c.readResponse()
+	if err == nil && !strings.HasPrefix(g.Text, "* OK") && !strings.HasPrefix(g.Text, "* PREAUTH") {
+		err = fmt.Errorf("Unexpected IMAP greeting. %s", g.Text)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Cmd sends the command to the server and returns the untagged responses
+func (c *imapClient) Cmd(format string, v ...interface{}) ([]imapResponse, error) {
+	c.tag++
+	t := fmt.Sprintf("A%03d", c.tag)
+	cmd := fmt.Sprintf(format, v...)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", t, cmd); err != nil {
+		return nil, err
+	}
+	rl := []imapResponse{}
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return rl, err
+		}
+		if strings.HasPrefix(r.Text, t+" ") {
+			st := strings.TrimPrefix(r.Text, t+" ")
+			if strings.HasPrefix(st, "OK") {
+				return rl, nil
+			}
+			return rl, fmt.Errorf("IMAP %s failed. %s", strings.Fields(cmd)[0], st)
+		}
+		if strings.HasPrefix(r.Text, "+") {
+			return rl, fmt.Errorf("Unexpected IMAP continuation for %s", strings.Fields(cmd)[0])
+		}
+		rl = append(rl, r)
+	}
+}
+
+// readResponse reads a response line from the server, including any literals
+func (c *imapClient) readResponse() (imapResponse, error) {
+	r := imapResponse{}
+	for {
+		l, err := c.r.ReadString('\n')
+		if err != nil {
+			return r, err
+		}
+		m := imapLiteralRegex.FindStringSubmatch(l)
+		if m == nil {
+			r.Text = r.Text + strings.TrimRight(l, "\r\n")
+			return r, nil
+		}
+		r.Text = r.Text + strings.TrimSuffix(l, m[0])
+		n, _ := strconv.Atoi(m[1])
+		b := make([]byte, n)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return r, err
+		}
+		r.Literals = append(r.Literals, b)
+	}
+}
+
+// Close closes the connection to the server
+func (c *imapClient) Close() error {
+	return c.conn.Close()
+}
+
+// imapQuote returns the string as an IMAP quoted string
+func imapQuote(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return "\"" + s + "\""
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeIMAP is an in-process stand-in for an IMAP server with a single mailbox
+type fakeIMAP struct {
+	t       *testing.T
+	move    bool
+	mu      sync.Mutex
+	inbox   map[int]string
+	deleted map[int]bool
+	moved   map[string][]int
+	bodies  map[int]int // Number of times the whole message was fetched
+}
+
+func (f *fakeIMAP) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+// imapArgs splits the arguments of a command, removing the quotes
+func imapArgs(s string) []string {
+	a := []string{}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			v := ""
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+				v += string(s[i])
+			}
+			a = append(a, v)
+			s = s[i+1:]
+			continue
+		}
+		i := strings.Index(s, " ")
+		if i < 0 {
+			i = len(s)
+		}
+		a = append(a, s[:i])
+		s = s[i:]
+	}
+	return a
+}
+
+func (f *fakeIMAP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK IMAP4rev1 Service Ready\r\n")
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		a := imapArgs(strings.TrimRight(l, "\r\n"))
+		tag, cmd := a[0], strings.ToUpper(a[1])
+		if cmd == "UID" {
+			cmd = cmd + " " + strings.ToUpper(a[2])
+			a = a[1:]
+		}
+		f.mu.Lock()
+		switch cmd {
+		case "LOGIN":
+			if a[2] != "gran@frame.example" || a[3] != "p@ss \"word\"" {
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] Invalid credentials\r\n", tag)
+				f.mu.Unlock()
+				continue
+			}
+		case "CAPABILITY":
+			if f.move {
+				fmt.Fprint(conn, "* CAPABILITY IMAP4rev1 MOVE\r\n")
+			} else {
+				fmt.Fprint(conn, "* CAPABILITY IMAP4rev1\r\n")
+			}
+		case "SELECT":
+			fmt.Fprintf(conn, "* %d EXISTS\r\n", len(f.inbox))
+		case "UID SEARCH":
+			ul := []string{}
+			for u := 1; u <= 10; u++ {
+				if m, ok := f.inbox[u]; ok && !f.deleted[u] && strings.Contains(strings.ToLower(strings.SplitN(strings.SplitN(m, "From: ", 2)[1], "\r\n", 2)[0]), strings.ToLower(a[3])) {
+					ul = append(ul, strconv.Itoa(u))
+				}
+			}
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(ul, " "))
+		case "UID FETCH":
+			u, _ := strconv.Atoi(a[2])
+			if m, ok := f.inbox[u]; ok {
+				if strings.Contains(a[4], "[HEADER]") {
+					h := strings.SplitN(m, "\r\n\r\n", 2)[0] + "\r\n\r\n"
+					fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[HEADER] {%d}\r\n%s)\r\n", u, u, len(h), h)
+				} else {
+					f.bodies[u]++
+					fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", u, u, len(m), m)
+				}
+			}
+		case "CREATE":
+			if _, ok := f.moved[a[2]]; ok {
+				fmt.Fprintf(conn, "%s NO [ALREADYEXISTS] Mailbox already exists\r\n", tag)
+				f.mu.Unlock()
+				continue
+			}
+			f.moved[a[2]] = []int{}
+		case "UID MOVE", "UID COPY":
+			if cmd == "UID MOVE" && !f.move {
+				fmt.Fprintf(conn, "%s BAD Unknown command\r\n", tag)
+				f.mu.Unlock()
+				continue
+			}
+			if _, ok := f.moved[a[3]]; !ok {
+				fmt.Fprintf(conn, "%s NO [TRYCREATE] No such mailbox\r\n", tag)
+				f.mu.Unlock()
+				continue
+			}
+			u, _ := strconv.Atoi(a[2])
+			f.moved[a[3]] = append(f.moved[a[3]], u)
+			if cmd == "UID MOVE" {
+				delete(f.inbox, u)
+			}
+		case "UID STORE":
+			u, _ := strconv.Atoi(a[2])
+			f.deleted[u] = true
+		case "EXPUNGE":
+			for u := range f.deleted {
+				delete(f.inbox, u)
+			}
+		case "LOGOUT":
+			fmt.Fprint(conn, "* BYE\r\n")
+		default:
+			f.t.Error("Unexpected IMAP command", l)
+		}
+		f.mu.Unlock()
+		fmt.Fprintf(conn, "%s OK %s completed\r\n", tag, cmd)
+	}
+}
+
+// newEmailMessage builds a message with a text part and a jpeg attachment of the given size,
+// with the headers added by the receiving server
+func newEmailMessage(from string, subject string, w int, headers string) string {
+	b := bytes.Buffer{}
+	jpeg.Encode(&b, image.NewRGBA(image.Rect(0, 0, w, w)), nil)
+	enc := base64.StdEncoding.EncodeToString(b.Bytes())
+	lines := []string{}
+	for len(enc) > 76 {
+		lines = append(lines, enc[:76])
+		enc = enc[76:]
+	}
+	lines = append(lines, enc)
+	return strings.Replace(headers+`From: `+from+`
+To: Photo Frame <gran@frame.example>
+Subject: `+subject+`
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="XXbnd"
+
+--XXbnd
+Content-Type: text/plain; charset="utf-8"
+
+Look at this!
+--XXbnd
+Content-Type: image/jpeg; name="IMG_0001.JPG"
+Content-Disposition: attachment; filename="IMG_0001.JPG"
+Content-Transfer-Encoding: base64
+
+`+strings.Join(lines, "\n")+`
+--XXbnd--
+`, "\n", "\r\n", -1)
+}
+
+func TestCanGetEmailImages(t *testing.T) {
+	clean := func() {
+		fl, _ := filepath.Glob("./img/filefolder/email_*")
+		for _, f := range fl {
+			os.Remove(f)
+		}
+		os.Remove(emailCaptionFile)
+	}
+	clean()
+	defer clean()
+
+	// The envelope sender is only trusted when the server adds no Authentication-Results header if it is configured
+	for _, tc := range []struct{ move, trust bool }{{true, false}, {false, true}} {
+		f := &fakeIMAP{
+			t:    t,
+			move: tc.move,
+			inbox: map[int]string{
+				1: newEmailMessage("Granny <Granny@Example.com>", "=?UTF-8?Q?Fwd:_Picnic_at_the_park_=E2=98=80?=", 20,
+					"Authentication-Results: mx.frame.example; spf=pass (sender permitted) smtp.mailfrom=bounce.example;\n dkim=pass header.d=example.com; dmarc=pass header.from=example.com\n"),
+				2: newEmailMessage("Stranger <someone@spam.example>", "Buy now", 21, ""),
+				3: newEmailMessage("Not Granny <granny@example.com.spam.example>", "Hello", 22, ""),
+				4: newEmailMessage("Grandpa <grandpa@family.example>", "Re: Fishing", 23, "Return-Path: <grandpa@family.example>\n"),
+				// Forged senders
+				5: newEmailMessage("Granny <granny@example.com>", "Hi", 24,
+					"Authentication-Results: mx.frame.example; spf=pass smtp.mailfrom=spam.example; dmarc=fail header.from=example.com\n"+
+						"Authentication-Results: spam.example; dmarc=pass header.from=example.com\n"),
+				6: newEmailMessage("Grandpa <grandpa@family.example>", "Hi", 25, "Return-Path: <bulk@spam.example>\n"),
+			},
+			deleted: map[int]bool{},
+			moved:   map[string][]int{},
+			bodies:  map[int]int{},
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go f.serve(l)
+
+		c := Config{
+			Provider:      14,
+			EmailServer:   "imap://" + l.Addr().String(),
+			EmailUser:     "gran@frame.example",
+			EmailPassword: "p@ss \"word\"",
+			EmailSenders:  []string{"granny@example.com", "@family.example"},
+		}
+		c.EmailTrustSender = tc.trust
+		c.SetDefaults()
+
+		i := Email{Config: c}
+		il, err := i.GetImages()
+		l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []int{1}
+		if tc.trust {
+			want = append(want, 4)
+		}
+		if fmt.Sprint(f.moved["Photoframe"]) != fmt.Sprint(want) {
+			t.Error("Unexpected messages moved", f.moved)
+		}
+		if len(f.inbox) != 6-len(want) || f.inbox[2] == "" || f.inbox[3] == "" || f.inbox[5] == "" || f.inbox[6] == "" {
+			t.Error("Messages from other senders should be left in the inbox", len(f.inbox))
+		}
+		// Only the messages from verified senders are fetched in full
+		if len(f.bodies) != len(want) || f.bodies[1] != 1 {
+			t.Error("Unexpected messages fetched", f.bodies)
+		}
+
+		cl := map[string]string{}
+		for _, d := range il {
+			if strings.HasPrefix(d.Name, "email_") {
+				cl[d.Name] = d.Caption
+				// The original file is kept
+				if b, err := ioutil.ReadFile(d.ImagePath); err != nil || fmt.Sprintf("email_%x.jpg", sha1.Sum(b)) != d.Name {
+					t.Error("The attachment was not saved as sent", d.Name, err)
+				}
+			}
+		}
+		if len(cl) != len(want) {
+			t.Fatal(len(cl), "emailed images returned, expected", len(want))
+		}
+		found := map[string]bool{}
+		for _, c := range cl {
+			found[c] = true
+		}
+		if !found["Picnic at the park ☀"] || found["Fishing"] != tc.trust {
+			t.Error("Unexpected captions", cl)
+		}
+	}
+}
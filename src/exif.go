@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/disintegration/imaging"
+)
+
+// EXIF tags read from the image files
+const (
+	exifTagDescription = 0x010E
+	exifTagMake        = 0x010F
+	exifTagModel       = 0x0110
+	exifTagOrientation = 0x0112
+	exifTagDateTime    = 0x0132
+	exifTagExifIFD     = 0x8769
+	exifTagGPSIFD      = 0x8825
+	exifTagDateTaken   = 0x9003
+	exifTagXPTitle     = 0x9C9B
+	exifTagXPComment   = 0x9C9C
+	exifTagGPSLatRef   = 0x0001
+	exifTagGPSLat      = 0x0002
+	exifTagGPSLongRef  = 0x0003
+	exifTagGPSLong     = 0x0004
+)
+
+// ImageMetadata holds the details extracted from the EXIF data of an image file
+type ImageMetadata struct {
+	Taken       time.Time `json:"taken"`       // Time the photo was taken, in the camera's time zone
+	Camera      string    `json:"camera"`      // Make and model of the camera
+	HasGPS      bool      `json:"hasGps"`      // Indicates if the location the photo was taken was recorded
+	Latitude    float64   `json:"latitude"`    // Latitude the photo was taken at, negative in the south
+	Longitude   float64   `json:"longitude"`   // Longitude the photo was taken at, negative in the west
+	Title       string    `json:"title"`       // Title of the photo
+	Description string    `json:"description"` // Description of the photo
+	Orientation int       `json:"orientation"` // EXIF orientation, 1 if the image is upright
+}
+
+// exifEntry holds the raw value of an IFD entry
+type exifEntry struct {
+	Type  uint16
+	Count uint32
+	Data  []byte
+}
+
+// exifSizes holds the size in bytes of each EXIF data type
+var exifSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8}
+
+// openImage opens the image file, rotating and flipping it as specified by the EXIF orientation
+func openImage(path string) (image.Image, error) {
+	return imaging.Open(path, imaging.AutoOrientation(true))
+}
+
+// ReadMetadata reads the EXIF metadata from the JPEG image file
+func ReadMetadata(path string) (ImageMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	defer f.Close()
+	b, err := findExif(bufio.NewReader(f))
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	return parseExif(b)
+}
+
+// GetCaption returns a caption for the image from the title or description and the date it was taken
+func (m ImageMetadata) GetCaption(loc Locale) string {
+	s := []string{}
+	if m.Title != "" {
+		s = append(s, m.Title)
+	} else if m.Description != "" {
+		s = append(s, m.Description)
+	}
+	if !m.Taken.IsZero() {
+		s = append(s, loc.FormatDate(m.Taken))
+	}
+	return strings.Join(s, " - ")
+}
+
+// findExif returns the TIFF data from the APP1 segment of the JPEG
+func findExif(r io.Reader) ([]byte, error) {
+	h := make([]byte, 2)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	if h[0] != 0xFF || h[1] != 0xD8 {
+		return nil, fmt.Errorf("Not a JPEG file")
+	}
+	for {
+		h = make([]byte, 4)
+		if _, err := io.ReadFull(r, h); err != nil {
+			return nil, err
+		}
+		if h[0] != 0xFF || h[1] == 0xDA || h[1] == 0xD9 {
+			// The image data has started, so there is no EXIF data
+			return nil, fmt.Errorf("No EXIF data found")
+		}
+		n := int(binary.BigEndian.Uint16(h[2:])) - 2
+		if n < 0 {
+			return nil, fmt.Errorf("Invalid JPEG segment")
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if h[1] == 0xE1 && bytes.HasPrefix(b, []byte("Exif\x00\x00")) {
+			return b[6:], nil
+		}
+	}
+}
+
+// parseExif extracts the metadata from the TIFF data
+func parseExif(b []byte) (ImageMetadata, error) {
+	m := ImageMetadata{Orientation: 1}
+	if len(b) < 8 {
+		return m, fmt.Errorf("Invalid EXIF header")
+	}
+	var bo binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return m, fmt.Errorf("Invalid EXIF byte order")
+	}
+	if bo.Uint16(b[2:]) != 42 {
+		return m, fmt.Errorf("Invalid EXIF header")
+	}
+
+	ifd0 := readIFD(b, bo, bo.Uint32(b[4:]))
+	exif := map[uint16]exifEntry{}
+	if e, ok := ifd0[exifTagExifIFD]; ok {
+		exif = readIFD(b, bo, exifUint(e, bo, 0))
+	}
+	gps := map[uint16]exifEntry{}
+	if e, ok := ifd0[exifTagGPSIFD]; ok {
+		gps = readIFD(b, bo, exifUint(e, bo, 0))
+	}
+
+	if e, ok := ifd0[exifTagOrientation]; ok {
+		if o := int(exifUint(e, bo, 0)); o >= 1 && o <= 8 {
+			m.Orientation = o
+		}
+	}
+	mk := exifString(ifd0[exifTagMake])
+	md := exifString(ifd0[exifTagModel])
+	if strings.HasPrefix(strings.ToLower(md), strings.ToLower(mk)) {
+		mk = ""
+	}
+	m.Camera = strings.TrimSpace(mk + " " + md)
+	m.Title = exifUTF16(ifd0[exifTagXPTitle])
+	m.Description = exifString(ifd0[exifTagDescription])
+	if m.Description == "" {
+		m.Description = exifUTF16(ifd0[exifTagXPComment])
+	}
+
+	t := exifString(exif[exifTagDateTaken])
+	if t == "" {
+		t = exifString(ifd0[exifTagDateTime])
+	}
+	if d, err := time.ParseInLocation("2006:01:02 15:04:05", t, time.Local); err == nil {
+		m.Taken = d
+	}
+
+	lat, lok := exifDegrees(gps[exifTagGPSLat], bo)
+	lng, gok := exifDegrees(gps[exifTagGPSLong], bo)
+	if lok && gok {
+		if strings.ToUpper(exifString(gps[exifTagGPSLatRef])) == "S" {
+			lat = -lat
+		}
+		if strings.ToUpper(exifString(gps[exifTagGPSLongRef])) == "W" {
+			lng = -lng
+		}
+		m.HasGPS = true
+		m.Latitude = lat
+		m.Longitude = lng
+	}
+
+	return m, nil
+}
+
+// readIFD reads the entries of the IFD at the offset. Entries that are out of range are skipped.
+func readIFD(b []byte, bo binary.ByteOrder, off uint32) map[uint16]exifEntry {
+	el := map[uint16]exifEntry{}
+	if off == 0 || uint64(off)+2 > uint64(len(b)) {
+		return el
+	}
+	n := uint32(bo.Uint16(b[off:]))
+	for i := uint32(0); i < n; i++ {
+		p := uint64(off) + 2 + uint64(i)*12
+		if p+12 > uint64(len(b)) {
+			break
+		}
+		e := exifEntry{Type: bo.Uint16(b[p+2:]), Count: bo.Uint32(b[p+4:])}
+		sz, ok := exifSizes[e.Type]
+		if !ok {
+			continue
+		}
+		l := uint64(sz) * uint64(e.Count)
+		if l <= 4 {
+			e.Data = b[p+8 : p+8+l]
+		} else {
+			vo := uint64(bo.Uint32(b[p+8:]))
+			if vo+l > uint64(len(b)) {
+				continue
+			}
+			e.Data = b[vo : vo+l]
+		}
+		el[bo.Uint16(b[p:])] = e
+	}
+	return el
+}
+
+// exifUint returns the nth value of a SHORT or LONG entry
+func exifUint(e exifEntry, bo binary.ByteOrder, n int) uint32 {
+	switch e.Type {
+	case 3:
+		if len(e.Data) >= 2*(n+1) {
+			return uint32(bo.Uint16(e.Data[2*n:]))
+		}
+	case 4:
+		if len(e.Data) >= 4*(n+1) {
+			return bo.Uint32(e.Data[4*n:])
+		}
+	}
+	return 0
+}
+
+// exifString returns the value of an ASCII entry
+func exifString(e exifEntry) string {
+	if e.Type != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.Data), "\x00"))
+}
+
+// exifUTF16 returns the value of a Windows XP tag, which is stored as UTF-16LE bytes
+func exifUTF16(e exifEntry) string {
+	if e.Type != 1 {
+		return ""
+	}
+	u := []uint16{}
+	for i := 0; i+1 < len(e.Data); i += 2 {
+		c := binary.LittleEndian.Uint16(e.Data[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return strings.TrimSpace(string(utf16.Decode(u)))
+}
+
+// exifDegrees returns the value of a GPS entry of 3 rationals (degrees, minutes and seconds)
+func exifDegrees(e exifEntry, bo binary.ByteOrder) (float64, bool) {
+	if e.Type != 5 || len(e.Data) < 24 {
+		return 0, false
+	}
+	v := 0.0
+	for i, d := range []float64{1, 60, 3600} {
+		n := bo.Uint32(e.Data[8*i:])
+		dn := bo.Uint32(e.Data[8*i+4:])
+		if dn == 0 {
+			if n != 0 {
+				return 0, false
+			}
+			continue
+		}
+		v += float64(n) / float64(dn) / d
+	}
+	if math.IsNaN(v) || v > 180 {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+type testExifTag struct {
+	Tag   uint16
+	Type  uint16
+	Count uint32
+	Data  []byte
+}
+
+// buildTestIFD returns the IFD, followed by the values that don't fit in the entries
+func buildTestIFD(off uint32, tl []testExifTag) []byte {
+	bo := binary.BigEndian
+	b := make([]byte, 2+12*len(tl)+4)
+	bo.PutUint16(b, uint16(len(tl)))
+	data := []byte{}
+	for n, t := range tl {
+		p := 2 + 12*n
+		bo.PutUint16(b[p:], t.Tag)
+		bo.PutUint16(b[p+2:], t.Type)
+		bo.PutUint32(b[p+4:], t.Count)
+		if len(t.Data) <= 4 {
+			copy(b[p+8:], t.Data)
+		} else {
+			bo.PutUint32(b[p+8:], off+uint32(len(b)+len(data)))
+			data = append(data, t.Data...)
+		}
+	}
+	return append(b, data...)
+}
+
+func testExifLong(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func testExifRationals(v ...uint32) []byte {
+	b := []byte{}
+	for _, n := range v {
+		b = append(b, testExifLong(n)...)
+	}
+	return b
+}
+
+func testExifUTF16(s string) []byte {
+	b := []byte{}
+	for _, c := range append(utf16.Encode([]rune(s)), 0) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+// buildTestExif returns the TIFF data for a photo taken in Cape Town, rotated with the orientation
+func buildTestExif(orientation uint16) []byte {
+	ifd0 := func(exif uint32, gps uint32) []testExifTag {
+		return []testExifTag{
+			{exifTagMake, 2, 6, []byte("Canon\x00")},
+			{exifTagModel, 2, 13, []byte("Canon EOS 5D\x00")},
+			{exifTagOrientation, 3, 1, []byte{byte(orientation >> 8), byte(orientation)}},
+			{exifTagExifIFD, 4, 1, testExifLong(exif)},
+			{exifTagGPSIFD, 4, 1, testExifLong(gps)},
+			{exifTagXPTitle, 1, 12, testExifUTF16("Beach")},
+		}
+	}
+	exif := []testExifTag{
+		{exifTagDateTaken, 2, 20, []byte("2023:12:25 10:30:00\x00")},
+	}
+	gps := []testExifTag{
+		{exifTagGPSLatRef, 2, 2, []byte("S\x00")},
+		{exifTagGPSLat, 5, 3, testExifRationals(33, 1, 55, 1, 3600, 100)},
+		{exifTagGPSLongRef, 2, 2, []byte("E\x00")},
+		{exifTagGPSLong, 5, 3, testExifRationals(18, 1, 25, 1, 0, 1)},
+	}
+
+	b := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	eo := uint32(8 + len(buildTestIFD(8, ifd0(0, 0))))
+	eb := buildTestIFD(eo, exif)
+	gpsOff := eo + uint32(len(eb))
+	b = append(b, buildTestIFD(8, ifd0(eo, gpsOff))...)
+	b = append(b, eb...)
+	return append(b, buildTestIFD(gpsOff, gps)...)
+}
+
+// writeTestJPEG writes a JPEG of the size with the EXIF data in an APP1 segment
+func writeTestJPEG(t *testing.T, fp string, w int, h int, exif []byte) {
+	j := bytes.Buffer{}
+	if err := jpeg.Encode(&j, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	seg := append([]byte("Exif\x00\x00"), exif...)
+	b := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte((len(seg) + 2) >> 8), byte(len(seg) + 2)}
+	b = append(append(b, seg...), j.Bytes()[2:]...)
+	if err := ioutil.WriteFile(fp, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanReadMetadata(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "photo.jpg")
+	writeTestJPEG(t, fp, 40, 20, buildTestExif(6))
+
+	m, err := ReadMetadata(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Orientation != 6 {
+		t.Error("Unexpected orientation", m.Orientation)
+	}
+	if m.Camera != "Canon EOS 5D" {
+		t.Error("Unexpected camera", m.Camera)
+	}
+	if m.Title != "Beach" {
+		t.Error("Unexpected title", m.Title)
+	}
+	if !m.Taken.Equal(time.Date(2023, 12, 25, 10, 30, 0, 0, time.Local)) {
+		t.Error("Unexpected taken time", m.Taken)
+	}
+	if !m.HasGPS || m.Latitude > -33.926 || m.Latitude < -33.927 || m.Longitude < 18.416 || m.Longitude > 18.417 {
+		t.Error("Unexpected location", m.HasGPS, m.Latitude, m.Longitude)
+	}
+	if m.GetCaption(GetLocale("en")) != "Beach - 25 December 2023" {
+		t.Error("Unexpected caption", m.GetCaption(GetLocale("en")))
+	}
+}
+
+func TestCanOpenImageWithOrientation(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "photo.jpg")
+	writeTestJPEG(t, fp, 40, 20, buildTestExif(6))
+
+	img, err := openImage(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 20 || img.Bounds().Dy() != 40 {
+		t.Error("Image not rotated", img.Bounds())
+	}
+
+	l := addMetadata([]DisplayImage{{ImagePath: fp}, {ImagePath: fp, Caption: "Emailed"}}, GetLocale("en"))
+	if l[0].Meta == nil || l[0].Caption != "Beach - 25 December 2023" {
+		t.Error("Metadata not added", l[0])
+	}
+	if l[1].Caption != "Emailed" {
+		t.Error("Provider caption replaced", l[1].Caption)
+	}
+}
+
+func TestCanSkipImagesWithoutMetadata(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "plain.jpg")
+	j := bytes.Buffer{}
+	jpeg.Encode(&j, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil)
+	ioutil.WriteFile(fp, j.Bytes(), 0666)
+
+	if _, err := ReadMetadata(fp); err == nil {
+		t.Error("Expected an error for an image without EXIF data")
+	}
+	if _, err := parseExif([]byte("MM\x00\x2a\xff\xff\xff\xff")); err != nil {
+		t.Error("Bad IFD offset not ignored.", err)
+	}
+	if l := addMetadata([]DisplayImage{{ImagePath: fp}}, GetLocale("en")); l[0].Meta != nil {
+		t.Error("Unexpected metadata", l[0].Meta)
+	}
+}
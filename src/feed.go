@@ -0,0 +1,434 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const feedCacheFile = "feedcache.json"
+
+type feedMedia struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+	Width  int    `xml:"width,attr"`
+}
+
+type feedEntry struct {
+	Title  string `xml:"title"`
+	Author struct {
+		Text string `xml:",chardata"`
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Creator    string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Credit     string `xml:"http://search.yahoo.com/mrss/ credit"`
+	Enclosures []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+	Contents   []feedMedia `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []feedMedia `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Groups     []struct {
+		Contents   []feedMedia `xml:"http://search.yahoo.com/mrss/ content"`
+		Thumbnails []feedMedia `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	} `xml:"http://search.yahoo.com/mrss/ group"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Summary     string `xml:"summary"`
+	Content     string `xml:"http://www.w3.org/2005/Atom content"`
+}
+
+type feedData struct {
+	Items   []feedEntry `xml:"channel>item"`
+	Entries []feedEntry `xml:"entry"`
+}
+
+// feedImage holds the details about an image found in a feed
+type feedImage struct {
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+}
+
+// feedCache holds the last response received for a feed url
+type feedCache struct {
+	ETag         string      `json:"etag"`
+	LastModified string      `json:"lastModified"`
+	Images       []feedImage `json:"images"`
+}
+
+var feedImgRegex = regexp.MustCompile(`(?i)<img[^>]+src\s*=\s*["']([^"']+)["']`)
+
+// Feed is an image provider that selects images from
+// RSS, Atom and Media RSS feeds
+type Feed struct {
+	Config Config
+}
+
+// SetConfig sets the configuration for this provider
+func (p *Feed) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *Feed) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Downloading images from feeds.")
+
+	l := []DisplayImage{}
+	il, err := p.getFeedImages()
+	if err == nil {
+		l, err = p.downloadImages(il)
+	}
+
+	if err != nil {
+		p.LogError("Error getting Images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastfeed.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// getFeedImages reads the configured feeds and returns the images
+// taken in turn from each feed
+func (p *Feed) getFeedImages() ([]feedImage, error) {
+	if len(p.Config.FeedUrls) == 0 {
+		return nil, fmt.Errorf("No feed urls have been configured")
+	}
+
+	cache := map[string]feedCache{}
+	if b, err := ioutil.ReadFile(feedCacheFile); err == nil {
+		json.Unmarshal(b, &cache)
+	}
+
+	fl := [][]feedImage{}
+	var lastErr error
+	for _, u := range p.Config.FeedUrls {
+		fc, err := p.getFeed(u, cache[u])
+		if err != nil {
+			p.LogError("Error reading feed ", u, ". ", err.Error())
+			lastErr = err
+			continue
+		}
+		cache[u] = fc
+		fl = append(fl, fc.Images)
+	}
+	if len(fl) == 0 {
+		return nil, lastErr
+	}
+	if b, err := json.Marshal(cache); err == nil {
+		ioutil.WriteFile(feedCacheFile, b, 0666)
+	}
+
+	il := []feedImage{}
+	found := map[string]bool{}
+	for n := 0; len(il) < p.Config.ImgCount; n++ {
+		more := false
+		for _, f := range fl {
+			if n >= len(f) {
+				continue
+			}
+			more = true
+			if !found[f[n].URL] && len(il) < p.Config.ImgCount {
+				found[f[n].URL] = true
+				il = append(il, f[n])
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return il, nil
+}
+
+// getFeed reads the feed at the specified url. If the feed has not changed
+// since it was last read, the cached images are returned.
+func (p *Feed) getFeed(u string, fc feedCache) (feedCache, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return fc, err
+	}
+	if len(fc.Images) != 0 {
+		if fc.ETag != "" {
+			req.Header.Set("If-None-Match", fc.ETag)
+		}
+		if fc.LastModified != "" {
+			req.Header.Set("If-Modified-Since", fc.LastModified)
+		}
+	}
+	resp, err := GetHTTPClient().Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+		resp.Close = true
+	}
+	if err != nil {
+		return fc, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		p.LogInfo("Feed ", u, " has not changed.")
+		return fc, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fc, fmt.Errorf("Feed returned %s", resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fc, err
+	}
+	il, err := p.parseFeed(u, b)
+	if err != nil {
+		return fc, err
+	}
+	return feedCache{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Images:       il,
+	}, nil
+}
+
+// parseFeed returns the images found in the RSS or Atom feed
+func (p *Feed) parseFeed(u string, b []byte) ([]feedImage, error) {
+	fd := feedData{}
+	if err := xml.Unmarshal(b, &fd); err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(u)
+
+	il := []feedImage{}
+	for _, e := range append(fd.Items, fd.Entries...) {
+		iu := p.getEntryImage(e)
+		if iu == "" {
+			continue
+		}
+		if r, err := url.Parse(strings.TrimSpace(html.UnescapeString(iu))); err == nil && base != nil {
+			iu = base.ResolveReference(r).String()
+		}
+		il = append(il, feedImage{
+			URL:    iu,
+			Title:  p.cleanText(e.Title),
+			Author: p.getEntryAuthor(e),
+		})
+	}
+	return il, nil
+}
+
+// getEntryImage returns the url of the image for the entry.
+// Enclosures are preferred, then Media RSS content and thumbnails,
+// then the first image in the description.
+func (p *Feed) getEntryImage(e feedEntry) string {
+	for _, c := range e.Enclosures {
+		if strings.HasPrefix(c.Type, "image/") {
+			return c.URL
+		}
+	}
+	for _, l := range e.Links {
+		if l.Rel == "enclosure" && strings.HasPrefix(l.Type, "image/") {
+			return l.Href
+		}
+	}
+
+	contents := e.Contents
+	thumbs := e.Thumbnails
+	for _, g := range e.Groups {
+		contents = append(contents, g.Contents...)
+		thumbs = append(thumbs, g.Thumbnails...)
+	}
+	best := feedMedia{}
+	for _, c := range contents {
+		if c.Medium == "image" || strings.HasPrefix(c.Type, "image/") || (c.Medium == "" && c.Type == "" && p.isImageURL(c.URL)) {
+			if best.URL == "" || c.Width > best.Width {
+				best = c
+			}
+		}
+	}
+	if best.URL == "" {
+		for _, c := range thumbs {
+			if best.URL == "" || c.Width > best.Width {
+				best = c
+			}
+		}
+	}
+	if best.URL != "" {
+		return best.URL
+	}
+
+	for _, s := range []string{e.Encoded, e.Content, e.Description, e.Summary} {
+		if m := feedImgRegex.FindStringSubmatch(s); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// getEntryAuthor returns the author of the entry
+func (p *Feed) getEntryAuthor(e feedEntry) string {
+	for _, s := range []string{e.Creator, e.Author.Name, e.Author.Text, e.Credit} {
+		if s = p.cleanText(s); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func (p *Feed) downloadImages(il []feedImage) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/feed"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	xRes, yRes := p.Config.GetResolution()
+
+	r := GetRatings()
+	dl := []DownloadJob{}
+	fl := []DisplayImage{}
+	for _, i := range il {
+		fn := p.getImageName(i.URL)
+		fp := filepath.Join(path, fn)
+		if r.IsBanned(fp) {
+			p.LogInfo("Skipping banned image '", fn, "'")
+			continue
+		}
+		if _, err := os.Stat(fp); os.IsNotExist(err) {
+			p.LogInfo("Downloading ", i.URL)
+			dl = append(dl, DownloadJob{URL: i.URL, Path: fp, Width: xRes, Height: yRes})
+		}
+		cr := i.Title
+		if i.Author != "" {
+			if cr == "" {
+				cr = i.Author
+			} else {
+				cr = fmt.Sprintf("%s - %s", cr, i.Author)
+			}
+		}
+		fl = append(fl, DisplayImage{
+			Name:      fn,
+			Copyright: cr,
+			ImagePath: fp,
+		})
+	}
+
+	// Download the missing images
+	failed := map[string]bool{}
+	for n, err := range NewDownloader(p.Config).Download(dl) {
+		if err != nil {
+			p.LogError("Error getting image file from url ", dl[n].URL, ". ", err.Error())
+			failed[dl[n].Path] = true
+		}
+	}
+	for _, i := range fl {
+		// Add the image to the list to return
+		if !failed[i.ImagePath] {
+			l = append(l, i)
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			}
+		}
+	}
+	if err == nil {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastfeed.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// getImageName returns a file name that is unique for the image url
+func (p *Feed) getImageName(u string) string {
+	h := sha1.Sum([]byte(u))
+	ext := ".jpg"
+	if pu, err := url.Parse(u); err == nil {
+		switch e := strings.ToLower(path.Ext(pu.Path)); e {
+		case ".jpeg", ".png", ".gif":
+			ext = e
+		}
+	}
+	return hex.EncodeToString(h[:8]) + ext
+}
+
+func (p *Feed) isImageURL(u string) bool {
+	if pu, err := url.Parse(u); err == nil {
+		switch strings.ToLower(path.Ext(pu.Path)) {
+		case ".jpg", ".jpeg", ".png", ".gif":
+			return true
+		}
+	}
+	return false
+}
+
+// cleanText removes any markup and extra white space from the text
+func (p *Feed) cleanText(s string) string {
+	s = html.UnescapeString(htmlTagRegex.ReplaceAllString(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *Feed) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Feed: [Inf] ", a)
+	} else {
+		fmt.Println("Feed: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *Feed) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Feed: [Err] ", a)
+	} else {
+		fmt.Println("Feed: [Err] ", a)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCanGetFeedImages(t *testing.T) {
+	os.RemoveAll("./img/feed")
+	os.Remove(feedCacheFile)
+
+	feedReads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/feed."):
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			feedReads++
+			b, err := ioutil.ReadFile("testdata" + r.URL.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(b)
+		case strings.HasSuffix(r.URL.Path, ".png"):
+			png.Encode(w, image.NewRGBA(image.Rect(0, 0, 100, 100)))
+		default:
+			jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 100, 100)), nil)
+		}
+	}))
+	defer srv.Close()
+
+	c := Config{
+		Provider: 8,
+		ImgCount: 10,
+		FeedUrls: []string{srv.URL + "/feed.rss", srv.URL + "/feed.atom"},
+	}
+	c.SetDefaults()
+
+	i := Feed{Config: c}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 5 {
+		t.Fatal(len(l), "images returned, expected 5.")
+	}
+	// The images are taken in turn from each feed
+	exp := []string{
+		"Sunset & Sea - Jane Doe",
+		"Harbour - Ann Other",
+		"Mountains - John Smith",
+		"Lighthouse - Ann Other",
+		"Forest",
+	}
+	for n, e := range exp {
+		if l[n].Copyright != e {
+			t.Error("Image", n, "has copyright", l[n].Copyright, "expected", e)
+		}
+	}
+
+	// Read the feeds again, they should not be downloaded as they have not changed
+	l, err = i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 5 {
+		t.Error(len(l), "cached images returned, expected 5.")
+	}
+	if feedReads != 2 {
+		t.Error("Feeds were read", feedReads, "times, expected 2.")
+	}
+}
+
+func TestCanGetFeedEntryImage(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/feed.rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := Feed{}
+	il, err := i.parseFeed("http://example.com/feed.rss", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(il) != 3 {
+		t.Fatal(len(il), "images found, expected 3.")
+	}
+	if il[1].URL != "http://example.com/images/mountains_large.jpg" {
+		t.Error("Largest media content not used.", il[1].URL)
+	}
+	if il[2].URL != "http://example.com/images/forest.png" {
+		t.Error("Description image not used.", il[2].URL)
+	}
+}
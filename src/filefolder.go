@@ -35,8 +35,12 @@ func (p *FileFolder) GetImages() ([]DisplayImage, error) {
 	// Read the files in this folder
 	fi, err := ioutil.ReadDir(path)
 	if err == nil {
+		r := GetRatings()
 		for _, f := range fi {
 			fp := filepath.Join(path, f.Name())
+			if r.IsBanned(fp) {
+				continue
+			}
 			l = append(l, DisplayImage{
 				Name:      f.Name(),
 				ImagePath: fp,
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Modes used to fit an image to the display when the aspect ratios don't match
+const (
+	FitCrop      = 0 // Fill the display, cropping the sides around the centre
+	FitSmart     = 1 // Fill the display, cropping the sides with the least detail
+	FitLetterbox = 2 // Show the whole image over a blurred and darkened copy of it
+)
+
+// fitImage resizes the image to the width and height using the fit mode
+func fitImage(img image.Image, w int, h int, mode int) *image.NRGBA {
+	switch mode {
+	case FitSmart:
+		return smartCrop(img, w, h)
+	case FitLetterbox:
+		return letterbox(img, w, h)
+	default:
+		return imaging.Fill(img, w, h, imaging.Center, imaging.Lanczos)
+	}
+}
+
+// coverParam returns the query parameter, w or h, and the value to request an image of the width
+// and height from an image CDN with, so that it covers the width and height of the display
+// without being cropped. The width is used if the size of the image is not known.
+func coverParam(iw int, ih int, w int, h int) (string, int) {
+	if iw > 0 && ih > 0 && iw*h > ih*w {
+		// The image is wider than the display, so the height must fill it
+		return "h", h
+	}
+	return "w", w
+}
+
+// coverImage scales the image, keeping the aspect ratio, to the smallest size that covers
+// the width and height. Nothing is cropped, so the image can still be fitted to the display later.
+func coverImage(img image.Image, w int, h int) *image.NRGBA {
+	iw, ih := img.Bounds().Dx(), img.Bounds().Dy()
+	if iw == 0 || ih == 0 {
+		return imaging.Clone(img)
+	}
+	s := math.Max(float64(w)/float64(iw), float64(h)/float64(ih))
+	nw := int(math.Max(math.Round(float64(iw)*s), float64(w)))
+	nh := int(math.Max(math.Round(float64(ih)*s), float64(h)))
+	if nw == iw && nh == ih {
+		return imaging.Clone(img)
+	}
+	return imaging.Resize(img, nw, nh, imaging.Lanczos)
+}
+
+// smartCrop covers the width and height with the image, then crops the sides
+// keeping the window with the most edges, which is where the subject usually is
+func smartCrop(img image.Image, w int, h int) *image.NRGBA {
+	c := coverImage(img, w, h)
+	cw, ch := c.Bounds().Dx(), c.Bounds().Dy()
+	if cw == w && ch == h {
+		return c
+	}
+
+	// Measure the edges on a small grayscale copy
+	s := math.Min(1, 160/math.Max(float64(cw), float64(ch)))
+	sw := int(math.Max(1, math.Round(float64(cw)*s)))
+	sh := int(math.Max(1, math.Round(float64(ch)*s)))
+	g := imaging.Grayscale(imaging.Resize(c, sw, sh, imaging.Box))
+	horiz := cw-w > ch-h
+	n, win := sh, int(math.Round(float64(h)*float64(sh)/float64(ch)))
+	if horiz {
+		n, win = sw, int(math.Round(float64(w)*float64(sw)/float64(cw)))
+	}
+	e := make([]float64, n)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			p := g.Pix[y*g.Stride+x*4]
+			d := 0.0
+			if x+1 < sw {
+				d += math.Abs(float64(p) - float64(g.Pix[y*g.Stride+(x+1)*4]))
+			}
+			if y+1 < sh {
+				d += math.Abs(float64(p) - float64(g.Pix[(y+1)*g.Stride+x*4]))
+			}
+			if horiz {
+				e[x] += d
+			} else {
+				e[y] += d
+			}
+		}
+	}
+
+	// Slide the window along the long side, favouring the centre slightly when the detail is even
+	best, off := -1.0, 0
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		sum += e[i]
+		if i >= win {
+			sum -= e[i-win]
+		}
+		if i >= win-1 {
+			start := i - win + 1
+			mid := float64(n-win) / 2
+			bias := 1.0
+			if mid > 0 {
+				bias = 1 - 0.1*math.Abs(float64(start)-mid)/mid
+			}
+			if sum*bias > best {
+				best, off = sum*bias, start
+			}
+		}
+	}
+
+	x, y := (cw-w)/2, (ch-h)/2
+	if horiz {
+		x = int(math.Min(float64(cw-w), math.Round(float64(off)*float64(cw)/float64(sw))))
+	} else {
+		y = int(math.Min(float64(ch-h), math.Round(float64(off)*float64(ch)/float64(sh))))
+	}
+	return imaging.Crop(c, image.Rect(x, y, x+w, y+h))
+}
+
+// letterbox scales the whole image to fit the width and height and centres it
+// over a blurred, darkened copy of the image that fills the display
+func letterbox(img image.Image, w int, h int) *image.NRGBA {
+	// Blur a small copy, as it is much quicker and looks the same once scaled up
+	bg := imaging.Fill(img, int(math.Max(1, float64(w/8))), int(math.Max(1, float64(h/8))), imaging.Center, imaging.Linear)
+	bg = imaging.Blur(bg, 3)
+	bg = imaging.Resize(bg, w, h, imaging.Linear)
+	bg = imaging.AdjustBrightness(bg, -40)
+
+	iw, ih := img.Bounds().Dx(), img.Bounds().Dy()
+	if iw == 0 || ih == 0 {
+		return bg
+	}
+	s := math.Min(float64(w)/float64(iw), float64(h)/float64(ih))
+	fg := imaging.Resize(img, int(math.Max(1, math.Round(float64(iw)*s))), int(math.Max(1, math.Round(float64(ih)*s))), imaging.Lanczos)
+	return imaging.PasteCenter(bg, fg)
+}
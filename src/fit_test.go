@@ -0,0 +1,89 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// coversFrame checks that the image was scaled to the smallest size that covers the frame
+func coversFrame(b image.Rectangle, w int, h int) bool {
+	return (b.Dx() == w && b.Dy() >= h) || (b.Dy() == h && b.Dx() >= w)
+}
+
+func TestCanFitImages(t *testing.T) {
+	img := imaging.New(400, 1000, color.White)
+	for _, m := range []int{FitCrop, FitSmart, FitLetterbox} {
+		f := fitImage(img, 800, 480, m)
+		if f.Bounds().Dx() != 800 || f.Bounds().Dy() != 480 {
+			t.Error("Fit mode", m, "returned", f.Bounds())
+		}
+	}
+	if c := coverImage(imaging.New(1000, 500, color.White), 800, 480); !coversFrame(c.Bounds(), 800, 480) || c.Bounds().Dx() != 960 {
+		t.Error("Unexpected cover size", c.Bounds())
+	}
+}
+
+func TestCanSmartCropToDetail(t *testing.T) {
+	// A plain portrait image with a checkerboard near the top
+	img := imaging.New(400, 1000, color.White)
+	for y := 100; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			if (x/10+y/10)%2 == 0 {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	f := smartCrop(img, 400, 200)
+	dark := 0
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			if r, _, _, _ := f.At(x, y).RGBA(); r < 0x8000 {
+				dark++
+			}
+		}
+	}
+	if dark < 400*200/3 {
+		t.Error("Smart crop missed the detail,", dark, "dark pixels")
+	}
+}
+
+func TestCanLetterboxImages(t *testing.T) {
+	img := imaging.New(400, 800, color.NRGBA{200, 200, 200, 255})
+	f := letterbox(img, 800, 480)
+	if c := f.NRGBAAt(400, 240); c.R != 200 {
+		t.Error("Image not centred", c)
+	}
+	if c := f.NRGBAAt(10, 240); c.R >= 200 || c.R < 50 {
+		t.Error("Background not darkened", c)
+	}
+	if f.Bounds() != image.Rect(0, 0, 800, 480) {
+		t.Error("Unexpected size", f.Bounds())
+	}
+}
+
+func TestCanGetFitModeForProvider(t *testing.T) {
+	c := Config{Provider: 4, FitMode: FitSmart, ProviderFitModes: map[int]int{4: FitLetterbox}}
+	if c.GetFitMode() != FitLetterbox {
+		t.Error("Provider fit mode not used", c.GetFitMode())
+	}
+	c.Provider = 0
+	if c.GetFitMode() != FitSmart {
+		t.Error("Default fit mode not used", c.GetFitMode())
+	}
+}
+
+func TestCanGetCoverParam(t *testing.T) {
+	for _, c := range []struct {
+		W, H int
+		Key  string
+		V    int
+	}{{6000, 3000, "h", 480}, {3000, 4000, "w", 800}, {1600, 960, "w", 800}, {0, 0, "w", 800}} {
+		if k, v := coverParam(c.W, c.H, 800, 480); k != c.Key || v != c.V {
+			t.Error(c.W, "x", c.H, "expected", c.Key, c.V, "got", k, v)
+		}
+	}
+}
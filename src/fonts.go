@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// fontPath is the folder holding the font files that are referred to by name
+const fontPath = "./html/assets/font"
+
+// defaultFontFamily is the font used when none has been configured
+const defaultFontFamily = "Roboto-Black"
+
+// defaultFallbackFonts are the system fonts used for the characters missing from the font,
+// such as non-Latin scripts and emoji. Only the ones that are installed are used.
+var defaultFallbackFonts = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf",
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/noto/NotoSans-Bold.ttf",
+	"/usr/share/fonts/truetype/noto/NotoSansSymbols2-Regular.ttf",
+	"/usr/share/fonts/truetype/ancient-scripts/Symbola_hint.ttf",
+	"/usr/share/fonts/truetype/unifont/unifont.ttf",
+}
+
+// fontKey identifies a face in the font cache
+type fontKey struct {
+	Family    string
+	Size      float64
+	Fallbacks string
+}
+
+// FontCache holds the parsed fonts and the faces created from them,
+// so that each font file is only read and parsed once
+type FontCache struct {
+	lock  sync.Mutex
+	fonts map[string]*truetype.Font
+	faces map[fontKey]font.Face
+}
+
+var fontCache = &FontCache{fonts: map[string]*truetype.Font{}, faces: map[fontKey]font.Face{}}
+
+// GetFontCache returns the font cache shared by the display
+func GetFontCache() *FontCache {
+	return fontCache
+}
+
+// GetFace returns the face for the font family at the size in points.
+// The family is either the name of a font in the font folder or the path to a TrueType file.
+// The characters missing from the font are drawn with the first fallback font that has them.
+func (c *FontCache) GetFace(family string, size float64, fallbacks []string) (font.Face, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if family == "" {
+		family = defaultFontFamily
+	}
+	k := fontKey{Family: family, Size: size, Fallbacks: strings.Join(fallbacks, "|")}
+	if f, ok := c.faces[k]; ok {
+		return f, nil
+	}
+
+	f, err := c.getFont(getFontFile(family))
+	if err != nil {
+		return nil, err
+	}
+	ff := &fallbackFace{}
+	ff.add(f, size)
+	for _, fb := range fallbacks {
+		if fb == getFontFile(family) {
+			continue
+		}
+		if f, err := c.getFont(getFontFile(fb)); err == nil {
+			ff.add(f, size)
+		}
+	}
+	var face font.Face = ff
+	if len(ff.faces) == 1 {
+		face = ff.faces[0]
+	}
+	c.faces[k] = face
+	return face, nil
+}
+
+// getFont returns the parsed font from the file
+func (c *FontCache) getFont(fp string) (*truetype.Font, error) {
+	if f, ok := c.fonts[fp]; ok {
+		if f == nil {
+			return nil, fmt.Errorf("Font %s could not be loaded", fp)
+		}
+		return f, nil
+	}
+	b, err := ioutil.ReadFile(fp)
+	if err == nil {
+		var f *truetype.Font
+		if f, err = truetype.Parse(b); err == nil {
+			c.fonts[fp] = f
+			return f, nil
+		}
+	}
+	// Remember the failure so that the file is not read again
+	c.fonts[fp] = nil
+	return nil, err
+}
+
+// getFontFile returns the path to the font file for the family
+func getFontFile(family string) string {
+	if strings.ContainsAny(family, `/\`) || strings.HasSuffix(strings.ToLower(family), ".ttf") {
+		return family
+	}
+	return filepath.Join(fontPath, family+".ttf")
+}
+
+// getFallbackFonts returns the configured fallback fonts, or the default fonts that are installed
+func getFallbackFonts(c Config) []string {
+	if len(c.FallbackFonts) != 0 {
+		return c.FallbackFonts
+	}
+	fl := []string{}
+	for _, f := range defaultFallbackFonts {
+		if _, err := os.Stat(f); err == nil {
+			fl = append(fl, f)
+		}
+	}
+	return fl
+}
+
+// fallbackFace draws each character with the first font that has a glyph for it
+type fallbackFace struct {
+	fonts []*truetype.Font
+	faces []font.Face
+}
+
+func (f *fallbackFace) add(tf *truetype.Font, size float64) {
+	f.fonts = append(f.fonts, tf)
+	f.faces = append(f.faces, truetype.NewFace(tf, &truetype.Options{Size: size}))
+}
+
+// pick returns the face for the character, the first face if no font has it
+func (f *fallbackFace) pick(r rune) font.Face {
+	for n, tf := range f.fonts {
+		if tf.Index(r) != 0 {
+			return f.faces[n]
+		}
+	}
+	return f.faces[0]
+}
+
+func (f *fallbackFace) Close() error {
+	return nil
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.pick(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.pick(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.pick(r).GlyphAdvance(r)
+}
+
+func (f *fallbackFace) Kern(r0 rune, r1 rune) fixed.Int26_6 {
+	if a := f.pick(r0); a == f.pick(r1) {
+		return a.Kern(r0, r1)
+	}
+	return 0
+}
+
+func (f *fallbackFace) Metrics() font.Metrics {
+	return f.faces[0].Metrics()
+}
+
+// measureText returns the width of the text in pixels
+func measureText(face font.Face, s string) float64 {
+	return float64(font.MeasureString(face, s)) / 64
+}
+
+// ellipsize shortens the text to fit the width, ending it with an ellipsis if it was shortened
+func ellipsize(face font.Face, s string, max float64) string {
+	if measureText(face, s) <= max {
+		return s
+	}
+	e := "…"
+	if _, ok := face.GlyphAdvance('…'); !ok {
+		e = "..."
+	}
+	r := []rune(s)
+	for n := len(r) - 1; n > 0; n-- {
+		t := strings.TrimSpace(string(r[:n])) + e
+		if measureText(face, t) <= max {
+			return t
+		}
+	}
+	return ""
+}
+
+// wrapText splits the text into lines that fit the width, breaking on spaces where possible.
+// The last line is ellipsized if the text needs more than the maximum number of lines.
+func wrapText(face font.Face, s string, max float64, lines int) []string {
+	ll := []string{}
+	line := ""
+	words := strings.Fields(s)
+	for n := 0; n < len(words); n++ {
+		w := words[n]
+		t := strings.TrimSpace(line + " " + w)
+		if measureText(face, t) <= max {
+			line = t
+			continue
+		}
+		if line == "" {
+			// The word is too long for a line on its own, so break it
+			r := []rune(w)
+			c := 1
+			for c < len(r) && measureText(face, string(r[:c+1])) <= max {
+				c++
+			}
+			line = string(r[:c])
+			if c < len(r) {
+				words = append(words[:n+1], words[n:]...)
+				words[n+1] = string(r[c:])
+			}
+		} else {
+			n--
+		}
+		if len(ll) == lines-1 {
+			// Put the rest of the text on the last line
+			line = strings.TrimSpace(line + " " + strings.Join(words[n+1:], " "))
+			return append(ll, ellipsize(face, line, max))
+		}
+		ll = append(ll, line)
+		line = ""
+	}
+	if line != "" {
+		ll = append(ll, line)
+	}
+	return ll
+}
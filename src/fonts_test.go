@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+)
+
+func TestCanCacheFontFaces(t *testing.T) {
+	c := &FontCache{fonts: map[string]*truetype.Font{}, faces: map[fontKey]font.Face{}}
+	f1, err := c.GetFace("", 20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, _ := c.GetFace(defaultFontFamily, 20, nil)
+	f3, _ := c.GetFace(defaultFontFamily, 14, nil)
+	if f1 != f2 || f1 == f3 {
+		t.Error("Faces not cached by family and size")
+	}
+	if len(c.fonts) != 1 {
+		t.Error("Font file parsed", len(c.fonts), "times")
+	}
+	if _, err := c.GetFace("Missing", 20, nil); err == nil {
+		t.Error("Expected an error for a missing font")
+	}
+}
+
+func TestCanUseFallbackFonts(t *testing.T) {
+	fb := "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	if _, err := os.Stat(fb); err != nil {
+		t.Skip("DejaVu fonts are not installed.")
+	}
+	c := &FontCache{fonts: map[string]*truetype.Font{}, faces: map[fontKey]font.Face{}}
+	face, err := c.GetFace(defaultFontFamily, 20, []string{fb})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff, ok := face.(*fallbackFace)
+	if !ok {
+		t.Fatal("Fallback face not created")
+	}
+	// Roboto has Latin letters but no chess pieces
+	if ff.pick('A') != ff.faces[0] {
+		t.Error("Latin letter not drawn with the font")
+	}
+	if ff.pick('♞') != ff.faces[1] {
+		t.Error("Missing character not drawn with the fallback font")
+	}
+	if w := measureText(face, "♞"); w <= 0 {
+		t.Error("Fallback character not measured", w)
+	}
+}
+
+func TestCanEllipsizeText(t *testing.T) {
+	face, err := GetFontCache().GetFace(defaultFontFamily, 20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := "Ça va très bien, naïve café"
+	if ellipsize(face, s, 1000) != s {
+		t.Error("Short text changed")
+	}
+	e := ellipsize(face, s, 120)
+	if !utf8.ValidString(e) || !strings.HasSuffix(e, "…") || measureText(face, e) > 120 {
+		t.Error("Unexpected ellipsized text", e)
+	}
+}
+
+func TestCanWrapText(t *testing.T) {
+	face, err := GetFontCache().GetFace(defaultFontFamily, 20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll := wrapText(face, "Dinner with the neighbours at the new restaurant", 190, 2)
+	if len(ll) != 2 || !strings.HasSuffix(ll[1], "…") {
+		t.Error("Unexpected lines", ll)
+	}
+	for _, l := range ll {
+		if measureText(face, l) > 190 {
+			t.Error("Line too long", l)
+		}
+	}
+	ll = wrapText(face, "Supercalifragilisticexpialidocious", 190, 3)
+	if len(ll) < 2 || strings.Join(ll, "") != "Supercalifragilisticexpialidocious" {
+		t.Error("Long word not broken", ll)
+	}
+	if ll := wrapText(face, "Lunch", 190, 2); len(ll) != 1 || ll[0] != "Lunch" {
+		t.Error("Unexpected lines", ll)
+	}
+}
@@ -3,14 +3,18 @@ module github.com/brumawen/photoframe
 go 1.20
 
 require (
-	github.com/brumawen/gopi-finder/src v0.0.0-20230310120639-ddc0e2f898b7 // indirect
-	github.com/disintegration/imaging v1.6.2 // indirect
-	github.com/fogleman/gg v1.3.0 // indirect
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	github.com/gorilla/mux v1.8.0 // indirect
-	github.com/kardianos/service v1.2.2 // indirect
-	github.com/onatm/clockwerk v0.0.0-20190910145222-354c9bd6cf28 // indirect
+	github.com/brumawen/gopi-finder/src v0.0.0-20230310120639-ddc0e2f898b7
+	github.com/disintegration/imaging v1.6.2
+	github.com/fogleman/gg v1.3.0
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/gorilla/mux v1.8.0
+	github.com/kardianos/service v1.2.2
+	github.com/onatm/clockwerk v0.0.0-20190910145222-354c9bd6cf28
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
+	golang.org/x/net v0.30.0
+)
+
+require (
 	github.com/satori/go.uuid v1.2.0 // indirect
-	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
-	golang.org/x/sys v0.0.0-20201015000850-e3ed0017c211 // indirect
+	golang.org/x/sys v0.26.0 // indirect
 )
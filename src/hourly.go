@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// defaultHourlyUrl is the Open-Meteo forecast API, used for the hourly forecast when the weather service does not provide one
+const defaultHourlyUrl = "https://api.open-meteo.com/v1/forecast"
+
+// precipColour is the colour of the precipitation probability bars
+var precipColour = color.NRGBA{64, 160, 255, 160}
+
+// HourlyForecast holds the forecast for an hour
+type HourlyForecast struct {
+	Time        time.Time `json:"time"`
+	Temp        float32   `json:"temp"`
+	PrecipProb  float32   `json:"precipProb"` // Probability of precipitation in percent
+	WeatherIcon int       `json:"weatherIcon"`
+}
+
+// openMeteoForecast holds the hourly forecast returned by Open-Meteo
+type openMeteoForecast struct {
+	UTCOffset int `json:"utc_offset_seconds"`
+	Hourly    struct {
+		Time   []string   `json:"time"`
+		Temp   []*float32 `json:"temperature_2m"`
+		Precip []*float32 `json:"precipitation_probability"`
+	} `json:"hourly"`
+}
+
+// GetHourlyForecast returns the forecast for the coming hours at the configured location from Open-Meteo
+func GetHourlyForecast(c Config) ([]HourlyForecast, error) {
+	q := url.Values{}
+	q.Set("latitude", fmt.Sprintf("%f", c.Latitude))
+	q.Set("longitude", fmt.Sprintf("%f", c.Longitude))
+	q.Set("hourly", "temperature_2m,precipitation_probability")
+	q.Set("forecast_days", "2")
+	q.Set("timezone", "auto")
+	// Keep the temperatures in the same unit as the rest of the forecast
+	if c.GetWeatherUnits().Temp == TempFahrenheit {
+		q.Set("temperature_unit", "fahrenheit")
+	}
+	u := c.HourlyUrl
+	if u == "" {
+		u = defaultHourlyUrl
+	}
+
+	b, err := GetHTTPClient().Get(u + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	om := openMeteoForecast{}
+	if err := json.Unmarshal(b, &om); err != nil {
+		return nil, err
+	}
+
+	loc := time.FixedZone("", om.UTCOffset)
+	now := time.Now().Truncate(time.Hour)
+	hl := []HourlyForecast{}
+	for n, s := range om.Hourly.Time {
+		t, err := time.ParseInLocation("2006-01-02T15:04", s, loc)
+		if err != nil || t.Before(now) || n >= len(om.Hourly.Temp) || om.Hourly.Temp[n] == nil {
+			continue
+		}
+		h := HourlyForecast{Time: t, Temp: *om.Hourly.Temp[n]}
+		if n < len(om.Hourly.Precip) && om.Hourly.Precip[n] != nil {
+			h.PrecipProb = *om.Hourly.Precip[n]
+		}
+		hl = append(hl, h)
+		if len(hl) == c.GetHourlyHours() {
+			break
+		}
+	}
+	return hl, nil
+}
+
+// drawHourlyChart draws the temperature line and precipitation probability bars of the coming hours
+// across the number of blocks, with the hours marked along the bottom
+func (d *Display) drawHourlyChart(dc canvas, w Weather, xq int, yq int, blocks int) {
+	dc.Widget = "hourly"
+	hl := w.Hourly
+	if n := d.Srv.Config.GetHourlyHours(); len(hl) > n {
+		hl = hl[:n]
+	}
+	if len(hl) < 2 {
+		return
+	}
+	loc := d.Srv.Config.GetLocale()
+	u := d.Srv.Config.GetUnits()
+	wu := d.Srv.Config.GetWeatherUnits()
+
+	left := float64(xq*d.xBlock + 20)
+	right := float64((xq+blocks)*d.xBlock - 20)
+	top := float64(yq*d.yBlock + 24)
+	bottom := float64((yq+1)*d.yBlock - 50)
+	step := (right - left) / float64(len(hl))
+
+	// Pick the colours from the image behind the chart
+	img := dc.Base
+	if img == nil {
+		img = dc.Image()
+	}
+	r := image.Rect(int(left)-10, int(top)-20, int(right)+10, int(bottom)+20)
+	ts := chooseTextStyle(img, r, d.Srv.Config.GetOverlayStyle(dc.Widget), nil, d.Srv.Config.ScrimOpacity)
+	if ts.Style == OverlayScrim || ts.Style == OverlayPanel {
+		dc.SetColor(ts.Back)
+		dc.DrawRoundedRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), 5)
+		dc.Fill()
+	}
+
+	// Draw the precipitation probability bars
+	dc.SetColor(precipColour)
+	for n, h := range hl {
+		p := math.Max(0, math.Min(100, float64(h.PrecipProb)))
+		bh := (bottom - top) * p / 100
+		dc.DrawRectangle(left+float64(n)*step+2, bottom-bh, step-4, bh)
+	}
+	dc.Fill()
+
+	// Scale the temperatures to the height of the chart
+	tl := make([]float64, len(hl))
+	tmin, tmax := math.MaxFloat64, -math.MaxFloat64
+	for n, h := range hl {
+		tl[n] = convertTemp(float64(h.Temp), wu.Temp, u.Temp)
+		tmin = math.Min(tmin, tl[n])
+		tmax = math.Max(tmax, tl[n])
+	}
+	if tmax-tmin < 1 {
+		tmin = tmin - 0.5
+		tmax = tmax + 0.5
+	}
+	x := func(n int) float64 { return left + (float64(n)+0.5)*step }
+	y := func(t float64) float64 { return bottom - (t-tmin)/(tmax-tmin)*(bottom-top) }
+
+	// Draw the temperature line, over a wider line in the back colour so it stands out
+	for _, s := range []struct {
+		Colour color.Color
+		Width  float64
+	}{{ts.Back, 5}, {ts.Text, 2.5}} {
+		dc.SetColor(s.Colour)
+		dc.SetLineWidth(s.Width)
+		dc.SetLineCap(gg.LineCapRound)
+		dc.SetLineJoin(gg.LineJoinRound)
+		dc.MoveTo(x(0), y(tl[0]))
+		for n := 1; n < len(tl); n++ {
+			dc.LineTo(x(n), y(tl[n]))
+		}
+		dc.Stroke()
+	}
+
+	// Label the highest and lowest temperatures above their points
+	face := d.setFont(dc, 14)
+	mx, mn := 0, 0
+	for n := range tl {
+		if tl[n] > tl[mx] {
+			mx = n
+		}
+		if tl[n] < tl[mn] {
+			mn = n
+		}
+	}
+	for _, n := range []int{mx, mn} {
+		s := fmt.Sprintf("%.0f%s", tl[n], unitLabel(u.Temp))
+		tw := measureText(face, s)
+		tx := math.Max(left, math.Min(right-tw, x(n)-tw/2))
+		d.drawStyledString(dc, s, nil, int(tx), int(y(tl[n])-22))
+	}
+
+	// Draw the hour ticks, labelling every few hours so the labels do not overlap
+	every := int(math.Ceil(float64(len(hl)) / 8))
+	dc.SetColor(ts.Text)
+	dc.SetLineWidth(1)
+	for n := range hl {
+		dc.DrawLine(x(n), bottom, x(n), bottom+4)
+	}
+	dc.Stroke()
+	face = d.setFont(dc, 12)
+	for n, h := range hl {
+		if n%every != 0 {
+			continue
+		}
+		s := loc.FormatHour(h.Time)
+		d.drawStyledString(dc, s, nil, int(x(n)-measureText(face, s)/2), int(bottom)+6)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// openMeteoResponse returns an Open-Meteo hourly forecast starting a few hours ago
+func openMeteoResponse(hours int) []byte {
+	om := map[string]interface{}{}
+	om["utc_offset_seconds"] = 7200
+	tz := time.FixedZone("", 7200)
+	start := time.Now().In(tz).Truncate(time.Hour).Add(-3 * time.Hour)
+	tl := []string{}
+	temp := []interface{}{}
+	precip := []interface{}{}
+	for n := 0; n < hours; n++ {
+		tl = append(tl, start.Add(time.Duration(n)*time.Hour).Format("2006-01-02T15:04"))
+		temp = append(temp, 10+n)
+		precip = append(precip, n*5)
+	}
+	// Open-Meteo returns null for the hours it has no value for
+	precip[5] = nil
+	om["hourly"] = map[string]interface{}{
+		"time":                      tl,
+		"temperature_2m":            temp,
+		"precipitation_probability": precip,
+	}
+	b, _ := json.Marshal(om)
+	return b
+}
+
+func TestCanGetHourlyForecast(t *testing.T) {
+	var q map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q = r.URL.Query()
+		w.Write(openMeteoResponse(48))
+	}))
+	defer srv.Close()
+
+	c := Config{HourlyUrl: srv.URL, Latitude: -33.9, Longitude: 18.4, HourlyHours: 18, WeatherTempUnit: "F"}
+	hl, err := GetHourlyForecast(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q["latitude"][0] != "-33.900000" || q["temperature_unit"][0] != "fahrenheit" {
+		t.Error("Unexpected query", q)
+	}
+	if len(hl) != 18 {
+		t.Fatal("Expected 18 hours, got", len(hl))
+	}
+	// The past hours are skipped
+	if hl[0].Time.Before(time.Now().Truncate(time.Hour)) || hl[0].Temp != 13 {
+		t.Error("Unexpected first hour", hl[0])
+	}
+	if hl[1].PrecipProb != 20 || hl[2].PrecipProb != 0 {
+		t.Error("Unexpected precipitation", hl[1], hl[2])
+	}
+}
+
+func TestCanGetHourlyHours(t *testing.T) {
+	for v, want := range map[int]int{0: 24, 6: 12, 18: 18, 48: 24} {
+		c := Config{HourlyHours: v}
+		if h := c.GetHourlyHours(); h != want {
+			t.Error(v, "expected", want, "got", h)
+		}
+	}
+}
+
+func TestCanDrawHourlyChart(t *testing.T) {
+	c := Config{}
+	d := Display{Srv: &Server{Config: &c}, xBlock: 200, yBlock: 160}
+	img := image.NewRGBA(image.Rect(0, 0, 800, 480))
+	dc := canvas{Context: gg.NewContextForImage(img), Base: img}
+
+	w := Weather{}
+	start := time.Now().Truncate(time.Hour)
+	for n := 0; n < 24; n++ {
+		w.Hourly = append(w.Hourly, HourlyForecast{Time: start.Add(time.Duration(n) * time.Hour), Temp: float32(15 + n%8), PrecipProb: 100})
+	}
+	d.drawHourlyChart(dc, w, 0, 2, 3)
+
+	out := dc.Image()
+	// The bars are drawn in the bottom row, and nothing above it
+	if r, _, b, _ := out.At(310, 425).RGBA(); b <= r {
+		t.Error("Expected a precipitation bar at 310,425, got", out.At(310, 425))
+	}
+	if out.At(300, 200) != (color.RGBA{0, 0, 0, 0}) {
+		t.Error("Expected nothing drawn above the chart, got", out.At(300, 200))
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// httpUserAgent identifies the application to the services it calls
+const httpUserAgent = "photoframe/1.0 (https://github.com/brumawen/photoframe)"
+
+var httpClient *HTTPClient
+var httpClientMu sync.Mutex
+
+// HTTPClient is the HTTP layer used for all the network calls made by the
+// image providers and data clients.  It adds timeouts, retries with an
+// exponential backoff, status code checks and response size limits.
+type HTTPClient struct {
+	Client    *http.Client        // Client used to send the requests
+	UserAgent string              // User-Agent header sent with each request
+	Retries   int                 // Number of times a failed request is retried
+	Backoff   time.Duration       // Delay before the first retry, doubled for each retry after that
+	MaxWait   time.Duration       // Longest delay before a retry, even if the server asks for longer
+	MaxBytes  int64               // Maximum size of a response body
+	wait      func(time.Duration) // Waits between retries, defaults to time.Sleep
+}
+
+// HTTPError is returned when a server responds with an unexpected status code
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.URL, e.Status)
+}
+
+// NewHTTPClient creates an HTTP client using the timeout, retry, size and proxy settings in the configuration
+func NewHTTPClient(c Config) *HTTPClient {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if c.HTTPProxy != "" {
+		if u, err := url.Parse(c.HTTPProxy); err == nil {
+			t.Proxy = http.ProxyURL(u)
+		}
+	}
+	h := &HTTPClient{
+		Client: &http.Client{
+			Transport: t,
+			Timeout:   time.Duration(c.HTTPTimeout) * time.Second,
+		},
+		UserAgent: httpUserAgent,
+		Retries:   c.HTTPRetries,
+		Backoff:   time.Second,
+		MaxWait:   2 * time.Minute,
+		MaxBytes:  int64(c.HTTPMaxMB) * 1024 * 1024,
+	}
+	if h.Client.Timeout <= 0 {
+		h.Client.Timeout = 30 * time.Second
+	}
+	if h.Retries < 0 {
+		h.Retries = 0
+	}
+	return h
+}
+
+// GetHTTPClient returns the shared HTTP client
+func GetHTTPClient() *HTTPClient {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	if httpClient == nil {
+		httpClient = NewHTTPClient(Config{HTTPTimeout: 30, HTTPRetries: 2, HTTPMaxMB: 50})
+	}
+	return httpClient
+}
+
+// SetHTTPClient replaces the shared HTTP client, e.g. when the configuration has been loaded
+func SetHTTPClient(h *HTTPClient) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	httpClient = h
+}
+
+// Do sends the request, retrying when the server can't be reached, returns a server error or
+// asks for fewer requests (429 Too Many Requests).
+// The response is returned whatever the status code, and the caller must close the body.
+func (h *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" && h.UserAgent != "" {
+		req.Header.Set("User-Agent", h.UserAgent)
+	}
+	// A request with a body can only be sent again if the body can be rewound
+	retries := h.Retries
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		retries = 0
+	}
+
+	d := h.Backoff
+	for n := 0; ; n++ {
+		if n > 0 && req.GetBody != nil {
+			b, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = b
+		}
+		res, err := h.Client.Do(req)
+		if err == nil && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+			if h.MaxBytes > 0 {
+				res.Body = &limitedBody{ReadCloser: res.Body, n: h.MaxBytes}
+			}
+			return res, nil
+		}
+		if n >= retries {
+			return res, err
+		}
+		w := d
+		if err == nil {
+			// Wait at least as long as the server asks, so that the next request isn't turned away too
+			if ra := retryAfter(res.Header.Get("Retry-After")); ra > w {
+				w = ra
+			}
+			io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+		if h.MaxWait > 0 && w > h.MaxWait {
+			w = h.MaxWait
+		}
+		h.getWait()(w)
+		d = d * 2
+	}
+}
+
+// Get gets the contents of the url, returning an error if the status is not 200 OK
+func (h *HTTPClient) Get(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return h.Fetch(req)
+}
+
+// Fetch sends the request and returns the body of the response, returning an error if the status is not 200 OK
+func (h *HTTPClient) Fetch(req *http.Request) ([]byte, error) {
+	res, err := h.Do(req)
+	if res != nil {
+		defer res.Body.Close()
+		res.Close = true
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, &HTTPError{URL: req.URL.Redacted(), StatusCode: res.StatusCode, Status: res.Status}
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// retryAfter returns the delay asked for in a Retry-After header, either in seconds or as a date
+func retryAfter(s string) time.Duration {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func (h *HTTPClient) getWait() func(time.Duration) {
+	if h.wait != nil {
+		return h.wait
+	}
+	return time.Sleep
+}
+
+// limitedBody returns an error if more than n bytes are read from the response body
+type limitedBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (l *limitedBody) Read(b []byte) (int, error) {
+	if l.n <= 0 {
+		// Check if there is anything past the limit
+		var x [1]byte
+		if c, _ := l.ReadCloser.Read(x[:]); c > 0 {
+			return 0, fmt.Errorf("The response is larger than the maximum size allowed")
+		}
+		return 0, io.EOF
+	}
+	if int64(len(b)) > l.n {
+		b = b[:l.n]
+	}
+	c, err := l.ReadCloser.Read(b)
+	l.n -= int64(c)
+	return c, err
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHTTPClient(waits *[]time.Duration) *HTTPClient {
+	h := NewHTTPClient(Config{HTTPTimeout: 5, HTTPRetries: 3, HTTPMaxMB: 1})
+	h.wait = func(d time.Duration) {
+		*waits = append(*waits, d)
+	}
+	return h
+}
+
+func TestHTTPClientRetriesWithBackoff(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("User-Agent") != httpUserAgent {
+			t.Error("Unexpected User-Agent", r.Header.Get("User-Agent"))
+		}
+		if b, _ := ioutil.ReadAll(r.Body); string(b) != "body" {
+			t.Error("Request body not sent on attempt", calls)
+		}
+		if calls < 3 {
+			http.Error(w, "Unavailable", 503)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	waits := []time.Duration{}
+	h := newTestHTTPClient(&waits)
+	req, _ := http.NewRequest("POST", srv.URL, bytes.NewBufferString("body"))
+	b, err := h.Fetch(req)
+	if err != nil || string(b) != "ok" {
+		t.Error("Unexpected response", string(b), err)
+	}
+	if calls != 3 || len(waits) != 2 || waits[0] != time.Second || waits[1] != 2*time.Second {
+		t.Error("Unexpected retries", calls, waits)
+	}
+}
+
+func TestHTTPClientWaitsForRetryAfter(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "Unavailable", 503)
+		case 2:
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, "Unavailable", 503)
+		case 3:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "Unavailable", 503)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	waits := []time.Duration{}
+	h := newTestHTTPClient(&waits)
+	b, err := h.Get(srv.URL)
+	if err != nil || string(b) != "ok" {
+		t.Error("Unexpected response", string(b), err)
+	}
+	// The longer of the server's delay and the backoff is used, up to the maximum
+	if len(waits) != 3 || waits[0] != 30*time.Second || waits[1] != 2*time.Minute || waits[2] != 4*time.Second {
+		t.Error("Unexpected waits", waits)
+	}
+}
+
+func TestHTTPClientRetriesWhenRateLimited(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/later" {
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, "Too Many Requests", 429)
+			return
+		}
+		if calls == 1 {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Too Many Requests", 429)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	waits := []time.Duration{}
+	h := newTestHTTPClient(&waits)
+	b, err := h.Get(srv.URL)
+	if err != nil || string(b) != "ok" {
+		t.Error("Unexpected response", string(b), err)
+	}
+	if calls != 2 || len(waits) != 1 || waits[0] != 5*time.Second {
+		t.Error("Unexpected retries", calls, waits)
+	}
+
+	// The server's delay is only waited for up to the maximum, and the 429 is returned once the retries run out
+	calls = 0
+	waits = waits[:0]
+	_, err = h.Get(srv.URL + "/later")
+	if he, ok := err.(*HTTPError); !ok || he.StatusCode != 429 {
+		t.Error("Expected a 429 error, got", err)
+	}
+	if calls != 4 || len(waits) != 3 || waits[0] != 2*time.Minute {
+		t.Error("Unexpected retries", calls, waits)
+	}
+}
+
+func TestHTTPClientReturnsStatusErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/fail" {
+			http.Error(w, "Broken", 500)
+			return
+		}
+		http.Error(w, "Not Found", 404)
+	}))
+	defer srv.Close()
+
+	waits := []time.Duration{}
+	h := newTestHTTPClient(&waits)
+	_, err := h.Get(srv.URL + "/missing")
+	if he, ok := err.(*HTTPError); !ok || he.StatusCode != 404 {
+		t.Error("Expected a 404 error, got", err)
+	}
+	if calls != 1 {
+		t.Error("Client errors should not be retried, called", calls, "times")
+	}
+
+	calls = 0
+	_, err = h.Get(srv.URL + "/fail")
+	if he, ok := err.(*HTTPError); !ok || he.StatusCode != 500 {
+		t.Error("Expected a 500 error, got", err)
+	}
+	if calls != 4 || len(waits) != 3 {
+		t.Error("Server errors should be retried 3 times, called", calls, "times")
+	}
+}
+
+func TestHTTPClientLimitsResponseSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 1024 * 1024
+		if r.URL.Path == "/big" {
+			n++
+		}
+		w.Write(bytes.Repeat([]byte("x"), n))
+	}))
+	defer srv.Close()
+
+	waits := []time.Duration{}
+	h := newTestHTTPClient(&waits)
+	if b, err := h.Get(srv.URL + "/small"); err != nil || len(b) != 1024*1024 {
+		t.Error("Unexpected response", len(b), err)
+	}
+	if _, err := h.Get(srv.URL + "/big"); err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Error("Expected a size error, got", err)
+	}
+}
+
+func TestHTTPClientUsesProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "photos.example" {
+			t.Error("Unexpected proxy request", r.URL.String())
+		}
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+
+	h := NewHTTPClient(Config{HTTPProxy: proxy.URL})
+	if b, err := h.Get("http://photos.example/image.jpg"); err != nil || string(b) != "proxied" {
+		t.Error("Request not sent through the proxy", string(b), err)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ImageController handles the Web Methods for marking images as favourites,
+// banning them and rating them.
+type ImageController struct {
+	Srv *Server
+}
+
+// ImagePageData holds the data used to write to the gallery page.
+type ImagePageData struct {
+	Title  string
+	Locale string
+	Images []ImageRating
+}
+
+// AddController adds the controller routes to the router
+func (c *ImageController) AddController(router *mux.Router, s *Server) {
+	c.Srv = s
+	router.Path("/gallery.html").Handler(Logger(c, http.HandlerFunc(c.handleGalleryWebPage)))
+	router.Path("/current.html").Handler(Logger(c, http.HandlerFunc(c.handleCurrentWebPage)))
+	router.Methods("GET").Path("/image/list").Name("ListImages").
+		Handler(Logger(c, http.HandlerFunc(c.handleListImages)))
+	router.Methods("GET").Path("/image/current").Name("CurrentImages").
+		Handler(Logger(c, http.HandlerFunc(c.handleCurrentImages)))
+	router.Methods("GET").Path("/image/file").Name("GetImageFile").
+		Handler(Logger(c, http.HandlerFunc(c.handleGetImageFile)))
+	router.Methods("POST").Path("/image/favourite").Name("SetFavourite").
+		Handler(Logger(c, http.HandlerFunc(c.handleSetFavourite)))
+	router.Methods("POST").Path("/image/ban").Name("SetBanned").
+		Handler(Logger(c, http.HandlerFunc(c.handleSetBanned)))
+	router.Methods("POST").Path("/image/rate").Name("SetRating").
+		Handler(Logger(c, http.HandlerFunc(c.handleSetRating)))
+}
+
+func (c *ImageController) handleGalleryWebPage(w http.ResponseWriter, r *http.Request) {
+	c.writeWebPage(w, "Gallery", c.getGalleryImages())
+}
+
+func (c *ImageController) handleCurrentWebPage(w http.ResponseWriter, r *http.Request) {
+	c.writeWebPage(w, "Currently on Frame", c.getCurrentImages())
+}
+
+func (c *ImageController) handleListImages(w http.ResponseWriter, r *http.Request) {
+	c.writeJSON(w, c.getGalleryImages())
+}
+
+func (c *ImageController) handleCurrentImages(w http.ResponseWriter, r *http.Request) {
+	c.writeJSON(w, c.getCurrentImages())
+}
+
+func (c *ImageController) handleGetImageFile(w http.ResponseWriter, r *http.Request) {
+	p, err := c.getImagePath(r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	http.ServeFile(w, r, p)
+}
+
+func (c *ImageController) handleSetFavourite(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p, err := c.getImagePath(r.Form.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	fav := r.Form.Get("value") != "off"
+
+	c.LogInfo("Setting favourite to ", fav, " for image ", p)
+	ir, err := UpdateRating(c.findImage(p), func(ir *ImageRating) {
+		ir.Favourite = fav
+		if fav {
+			ir.Banned = false
+		}
+	})
+	if err != nil {
+		http.Error(w, "Error saving image rating. "+err.Error(), 500)
+		return
+	}
+	c.writeJSON(w, ir)
+}
+
+func (c *ImageController) handleSetBanned(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p, err := c.getImagePath(r.Form.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	ban := r.Form.Get("value") != "off"
+
+	c.LogInfo("Setting banned to ", ban, " for image ", p)
+	ir, err := UpdateRating(c.findImage(p), func(ir *ImageRating) {
+		ir.Banned = ban
+		if ban {
+			ir.Favourite = false
+		}
+	})
+	if err != nil {
+		http.Error(w, "Error saving image rating. "+err.Error(), 500)
+		return
+	}
+
+	if ban {
+		// Remove the image from the provider cache and the frame list.
+		// The images in the file folder are the only copy, so they are only hidden by the rating.
+		if _, err := os.Stat(p); err == nil && !isOriginalImage(p) {
+			c.LogInfo("Removing banned image ", p)
+			if err := os.Remove(p); err != nil {
+				c.LogError("Error removing banned image ", p, ". ", err.Error())
+			}
+		}
+		c.Srv.Display.RemoveImage(ir.ImagePath)
+	}
+	c.writeJSON(w, ir)
+}
+
+func (c *ImageController) handleSetRating(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p, err := c.getImagePath(r.Form.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	v, err := strconv.Atoi(r.Form.Get("rating"))
+	if err != nil || v < 0 || v > 5 {
+		http.Error(w, "Rating must be between 0 and 5", 500)
+		return
+	}
+
+	c.LogInfo("Setting rating to ", v, " for image ", p)
+	ir, err := UpdateRating(c.findImage(p), func(ir *ImageRating) {
+		ir.Rating = v
+	})
+	if err != nil {
+		http.Error(w, "Error saving image rating. "+err.Error(), 500)
+		return
+	}
+	c.writeJSON(w, ir)
+}
+
+// getGalleryImages returns all the images in the provider folders
+// as well as the images that have been banned
+func (c *ImageController) getGalleryImages() []ImageRating {
+	rl := []ImageRating{}
+	rt := GetRatings()
+	found := map[string]bool{}
+
+	fi, _ := ioutil.ReadDir("./img")
+	for _, d := range fi {
+		if !d.IsDir() || d.Name() == "display" {
+			continue
+		}
+		path := filepath.Join("./img", d.Name())
+		ii, err := ioutil.ReadDir(path)
+		if err != nil {
+			c.LogError("Error reading folder ", path, ". ", err.Error())
+			continue
+		}
+		for _, f := range ii {
+			if f.IsDir() {
+				continue
+			}
+			i := c.findImage(filepath.Join(path, f.Name()))
+			ir := rt.Get(i.ImagePath)
+			ir.Name = i.Name
+			if i.Copyright != "" {
+				ir.Copyright = i.Copyright
+			}
+			found[ir.ImagePath] = true
+			rl = append(rl, ir)
+		}
+	}
+	for _, ir := range rt {
+		if ir.Banned && !found[ir.ImagePath] {
+			// The file of a banned image has been removed, so there is nothing to show
+			ir.Missing = true
+			rl = append(rl, ir)
+		}
+	}
+	return rl
+}
+
+// getCurrentImages returns the provider images that are currently on the frame
+func (c *ImageController) getCurrentImages() []ImageRating {
+	rl := []ImageRating{}
+	rt := GetRatings()
+	found := map[string]bool{}
+	for _, i := range c.Srv.Display.GetImages() {
+		ir := rt.Get(i.ImagePath)
+		if found[ir.ImagePath] {
+			continue
+		}
+		found[ir.ImagePath] = true
+		ir.Name = i.Name
+		ir.Copyright = i.Copyright
+		rl = append(rl, ir)
+	}
+	return rl
+}
+
+// findImage returns the details of the image at the specified path
+func (c *ImageController) findImage(p string) DisplayImage {
+	k := ratingKey(p)
+	for _, i := range c.Srv.Display.GetImages() {
+		if ratingKey(i.ImagePath) == k {
+			return i
+		}
+	}
+	return DisplayImage{Name: filepath.Base(k), ImagePath: k}
+}
+
+// getImagePath validates that the path refers to an image in one of the provider folders
+func (c *ImageController) getImagePath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("The image path must be specified")
+	}
+	k := ratingKey(p)
+	if !strings.HasPrefix(k, "img/") || strings.HasPrefix(k, "img/display/") || strings.Contains(k, "..") {
+		return "", fmt.Errorf("Invalid image path '%s'", p)
+	}
+	return k, nil
+}
+
+// isOriginalImage returns true for the images in the file folder,
+// which are the only copy of the image and cannot be downloaded again
+func isOriginalImage(p string) bool {
+	return strings.HasPrefix(ratingKey(p), "img/filefolder/")
+}
+
+func (c *ImageController) writeWebPage(w http.ResponseWriter, title string, l []ImageRating) {
+	loc := c.Srv.Config.GetLocale()
+	t := template.Must(template.New("gallery.html").Funcs(template.FuncMap{"t": loc.T}).ParseFiles("./html/gallery.html"))
+
+	v := ImagePageData{
+		Title:  title,
+		Locale: loc.Code,
+		Images: l,
+	}
+
+	err := t.Execute(w, v)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func (c *ImageController) writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Error serializing images. "+err.Error(), 500)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	w.Write(b)
+}
+
+// LogInfo is used to log information messages for this controller.
+func (c *ImageController) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	logger.Info("ImageController: [Inf] ", a)
+}
+
+// LogError is used to log error messages for this controller.
+func (c *ImageController) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	logger.Error("ImageController: [Err] ", a)
+}
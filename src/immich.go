@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type immichAsset struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	OriginalFileName string    `json:"originalFileName"`
+	FileCreatedAt    time.Time `json:"fileCreatedAt"`
+	ExifInfo         struct {
+		City             string     `json:"city"`
+		State            string     `json:"state"`
+		Country          string     `json:"country"`
+		DateTimeOriginal *time.Time `json:"dateTimeOriginal"`
+	} `json:"exifInfo"`
+	People []struct {
+		Name string `json:"name"`
+	} `json:"people"`
+}
+
+type immichAlbum struct {
+	AlbumName string        `json:"albumName"`
+	Assets    []immichAsset `json:"assets"`
+}
+
+// Immich is an image provider that selects images from
+// a self-hosted Immich server
+type Immich struct {
+	Config Config
+}
+
+// SetConfig sets the configuration for this provider
+func (p *Immich) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *Immich) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Downloading images from Immich.")
+
+	l := []DisplayImage{}
+	al, err := p.getAssets()
+	if err == nil {
+		l, err = p.downloadImages(al)
+	}
+
+	if err != nil {
+		p.LogError("Error getting images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastimmich.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// getAssets gets the list of image assets from the Immich API for the configured mode
+func (p *Immich) getAssets() ([]immichAsset, error) {
+	if p.Config.GalleryUrl == "" {
+		return nil, fmt.Errorf("The Immich url has not been configured")
+	}
+	if p.Config.GalleryKey == "" {
+		return nil, fmt.Errorf("The Immich API key has not been configured")
+	}
+
+	q := strings.TrimSpace(p.Config.GalleryQuery)
+	al := []immichAsset{}
+	switch p.Config.GalleryMode {
+	case 0, 2: // Random from the library or of a person
+		s := map[string]interface{}{
+			"size":       p.Config.ImgCount,
+			"type":       "IMAGE",
+			"withExif":   true,
+			"withPeople": true,
+		}
+		if p.Config.GalleryMode == 2 {
+			if q == "" {
+				return nil, fmt.Errorf("No Immich person has been configured")
+			}
+			s["personIds"] = []string{q}
+		}
+		b, err := p.callAPI("POST", "/api/search/random", s)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(b, &al)
+		if err != nil {
+			return nil, err
+		}
+	case 1: // Album
+		if q == "" {
+			return nil, fmt.Errorf("No Immich album has been configured")
+		}
+		b, err := p.callAPI("GET", "/api/albums/"+url.PathEscape(q), nil)
+		if err != nil {
+			return nil, err
+		}
+		a := immichAlbum{}
+		err = json.Unmarshal(b, &a)
+		if err != nil {
+			return nil, err
+		}
+		// Select a random set of images from the album
+		for _, i := range a.Assets {
+			if i.Type == "IMAGE" {
+				al = append(al, i)
+			}
+		}
+		rand.Shuffle(len(al), func(i, j int) {
+			al[i], al[j] = al[j], al[i]
+		})
+		if len(al) > p.Config.ImgCount {
+			al = al[:p.Config.ImgCount]
+		}
+	default:
+		return nil, fmt.Errorf("Gallery mode '%d' is invalid", p.Config.GalleryMode)
+	}
+	return al, nil
+}
+
+func (p *Immich) downloadImages(al []immichAsset) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/immich"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	xRes, yRes := p.Config.GetResolution()
+
+	r := GetRatings()
+	il := []DisplayImage{}
+	dl := []DownloadJob{}
+	for _, i := range al {
+		if i.Type != "" && i.Type != "IMAGE" {
+			continue
+		}
+		fn := i.ID + ".jpg"
+		fp := filepath.Join(path, fn)
+		if r.IsBanned(fp) {
+			p.LogInfo("Skipping banned image '", fn, "'")
+			continue
+		}
+		if _, err := os.Stat(fp); os.IsNotExist(err) {
+			p.LogInfo("Downloading ", i.OriginalFileName)
+			dl = append(dl, p.getDownloadJob(fp, i.ID, xRes, yRes))
+		}
+		il = append(il, DisplayImage{
+			Name:      fn,
+			Caption:   p.getCaption(i),
+			ImagePath: fp,
+		})
+	}
+
+	// Download the missing images
+	failed := map[string]bool{}
+	for n, err := range NewDownloader(p.Config).Download(dl) {
+		if err != nil {
+			p.LogError("Error getting preview ", dl[n].URL, ". ", err.Error())
+			failed[dl[n].Path] = true
+		}
+	}
+	for _, i := range il {
+		if !failed[i.ImagePath] {
+			l = append(l, i)
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			}
+		}
+	}
+	if err == nil {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastimmich.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// getDownloadJob returns the job that downloads the preview sized version of the asset, resized to the frame
+func (p *Immich) getDownloadJob(fp string, id string, xRes int, yRes int) DownloadJob {
+	return DownloadJob{
+		URL:    strings.TrimSuffix(p.Config.GalleryUrl, "/") + "/api/assets/" + url.PathEscape(id) + "/thumbnail?size=preview",
+		Path:   fp,
+		Width:  xRes,
+		Height: yRes,
+		Header: http.Header{"X-Api-Key": {p.Config.GalleryKey}},
+	}
+}
+
+// callAPI calls the Immich API and returns the body of the response
+func (p *Immich) callAPI(method string, path string, body interface{}) ([]byte, error) {
+	var rb *bytes.Buffer
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rb = bytes.NewBuffer(b)
+	} else {
+		rb = &bytes.Buffer{}
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(p.Config.GalleryUrl, "/")+path, rb)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", p.Config.GalleryKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return GetHTTPClient().Fetch(req)
+}
+
+// getCaption returns the caption for the asset from the people, place and date it was taken
+func (p *Immich) getCaption(i immichAsset) string {
+	pl := []string{}
+	for _, n := range i.People {
+		if n.Name != "" {
+			pl = append(pl, n.Name)
+		}
+	}
+	t := i.FileCreatedAt
+	if i.ExifInfo.DateTimeOriginal != nil {
+		t = *i.ExifInfo.DateTimeOriginal
+	}
+	return getGalleryCaption(pl, []string{i.ExifInfo.City, i.ExifInfo.State, i.ExifInfo.Country}, t, p.Config.GetLocale())
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *Immich) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Immich: [Inf] ", a)
+	} else {
+		fmt.Println("Immich: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *Immich) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Immich: [Err] ", a)
+	} else {
+		fmt.Println("Immich: [Err] ", a)
+	}
+}
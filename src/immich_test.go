@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func newImmichTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/search/random", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Error("Expected a POST to search, got", r.Method)
+		}
+		s := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&s)
+		if s["type"] != "IMAGE" || s["withPeople"] != true {
+			t.Error("Unexpected search", s)
+		}
+		if p, ok := s["personIds"]; ok {
+			if len(p.([]interface{})) != 1 || p.([]interface{})[0] != "person1" {
+				t.Error("Unexpected person", p)
+			}
+		}
+		b, _ := ioutil.ReadFile("./testdata/immich_random.json")
+		w.Write(b)
+	})
+	mux.HandleFunc("/api/albums/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/albums/a1b2c3d4-e5f6-4711-8899-aabbccddeeff" {
+			http.Error(w, "Not found", 404)
+			return
+		}
+		b, _ := ioutil.ReadFile("./testdata/immich_album.json")
+		w.Write(b)
+	})
+	mux.HandleFunc("/api/assets/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/thumbnail") || r.URL.Query().Get("size") != "preview" {
+			t.Error("Expected a preview thumbnail, got", r.URL.String())
+		}
+		jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 1440, 1080)), nil)
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "testkey" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestCanGetImmichImages(t *testing.T) {
+	os.RemoveAll("./img/immich")
+
+	srv := newImmichTestServer(t)
+	defer srv.Close()
+
+	c := Config{
+		Provider:   11,
+		ImgCount:   5,
+		GalleryUrl: srv.URL + "/",
+		GalleryKey: "testkey",
+	}
+	c.SetDefaults()
+
+	i := Immich{Config: c}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 2 {
+		t.Fatal(len(l), "images returned, expected 2.")
+	}
+	if l[0].Caption != "Alice, Bob - Cape Town, Western Cape, South Africa - 12 March 2023" {
+		t.Error("Unexpected caption", l[0].Caption)
+	}
+	if l[1].Caption != "24 December 2022" {
+		t.Error("Unexpected caption", l[1].Caption)
+	}
+	img, err := imaging.Open(l[0].ImagePath)
+	if err != nil {
+		t.Error(err)
+	} else if !coversFrame(img.Bounds(), 800, 480) {
+		t.Error("Image not resized to cover the frame", img.Bounds())
+	}
+
+	// Person
+	i.Config.GalleryMode = 2
+	i.Config.GalleryQuery = "person1"
+	if l, err = i.GetImages(); err != nil || len(l) != 2 {
+		t.Error("Unexpected person result", len(l), err)
+	}
+
+	// Album, the video should be skipped
+	i.Config.GalleryMode = 1
+	i.Config.GalleryQuery = "a1b2c3d4-e5f6-4711-8899-aabbccddeeff"
+	l, err = i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 2 {
+		t.Fatal(len(l), "images returned, expected 2.")
+	}
+	for _, d := range l {
+		if d.Name == "deadbeef-2222-4333-8444-555566667777.jpg" {
+			t.Error("Video returned from album")
+		}
+		if d.Name == "c0ffee00-1111-4222-8333-444455556666.jpg" && d.Caption != "Alice - Knysna, Western Cape, South Africa - 1 April 2023" {
+			t.Error("Unexpected caption", d.Caption)
+		}
+	}
+
+	// Bad key
+	i.Config.GalleryKey = "badkey"
+	if _, err = i.GetImages(); err == nil {
+		t.Error("Expected an error with an invalid API key")
+	}
+}
@@ -4,24 +4,49 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
+	"math/rand"
+	"net/url"
 	"os"
 	"path/filepath"
-
-	"github.com/disintegration/imaging"
+	"sort"
+	"strings"
+	"time"
 )
 
+const bingArchiveFile = "bingarchive.json"
+
 type bingdata struct {
 	Images []struct {
+		StartDate string `json:"startdate"`
 		URL       string `json:"url"`
 		Urlbase   string `json:"urlbase"`
 		Copyright string `json:"copyright"`
+		Hash      string `json:"hsh"`
 	}
 }
 
+// bingArchiveImage holds the details about an image in the local archive of Bing images
+type bingArchiveImage struct {
+	Name      string `json:"name"`
+	Copyright string `json:"copyright"`
+	Date      string `json:"date"`
+	Hash      string `json:"hash"`
+	Market    string `json:"market"`
+}
+
+// bingSizes lists the image sizes that Bing makes available, smallest first
+var bingSizes = []struct {
+	Width  int
+	Height int
+}{
+	{320, 240}, {240, 320}, {400, 240}, {480, 800}, {640, 480}, {800, 480}, {800, 600},
+	{768, 1280}, {1024, 768}, {1280, 768}, {1366, 768}, {1080, 1920}, {1920, 1080},
+}
+
 // IodBing is used to retrieve the Bing images of the day
 type IodBing struct {
 	Config Config
+	apiURL string // URL of the Bing web site, defaults to https://www.bing.com
 }
 
 // SetConfig sets the configuration for this provider
@@ -34,23 +59,15 @@ func (b *IodBing) GetImages() ([]DisplayImage, error) {
 	b.LogInfo("Getting latest list of images from Bing.")
 
 	l := []DisplayImage{}
-	// Get the data from the Bing web site
-	resp, err := http.Get(fmt.Sprintf("http://www.bing.com/HPImageArchive.aspx?format=js&idx=0&n=%d&mkt=za", b.Config.ImgCount))
-	if resp != nil {
-		defer resp.Body.Close()
-		resp.Close = true
-	}
+	al, err := b.getArchive()
 	if err == nil {
-		j, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			bd := bingdata{}
-			err = json.Unmarshal(j, &bd)
-			if err == nil {
-				l, err = b.downloadImages(&bd)
-			}
+		l = b.selectImages(al)
+		if bs, err := json.Marshal(l); err == nil {
+			ioutil.WriteFile("lastiodbing.json", bs, 0666)
 		}
 	}
 	if err != nil {
+		b.LogError("Error getting images. ", err.Error())
 		// Check to see if we already have the last response cached
 		fn := "lastiodbing.json"
 		if _, err := os.Stat(fn); !os.IsNotExist(err) {
@@ -65,10 +82,68 @@ func (b *IodBing) GetImages() ([]DisplayImage, error) {
 	return l, err
 }
 
-func (b *IodBing) downloadImages(bd *bingdata) ([]DisplayImage, error) {
-	b.LogInfo("Downloading images from Bing.")
+// getArchive adds the latest images for each market to the local archive
+// and returns the images in the archive, newest first
+func (b *IodBing) getArchive() ([]bingArchiveImage, error) {
+	al := []bingArchiveImage{}
+	if bs, err := ioutil.ReadFile(bingArchiveFile); err == nil {
+		json.Unmarshal(bs, &al)
+	}
 
-	l := []DisplayImage{}
+	var lastErr error
+	got := false
+	for _, m := range b.Config.BingMarkets {
+		// The API returns at most 8 images and idx goes back at most 7 days,
+		// so the two pages overlap and the images already seen are skipped
+		seen := map[string]bool{}
+		for _, idx := range []int{0, 7} {
+			bd, err := b.getBingData(idx, m)
+			if err != nil {
+				b.LogError("Error getting images for market ", m, ". ", err.Error())
+				lastErr = err
+				break
+			}
+			got = true
+			il := bd.Images[:0]
+			for _, i := range bd.Images {
+				if !seen[i.Urlbase] {
+					seen[i.Urlbase] = true
+					il = append(il, i)
+				}
+			}
+			bd.Images = il
+			nl, err := b.downloadImages(&bd, m, al)
+			if err != nil {
+				return al, err
+			}
+			al = append(al, nl...)
+		}
+	}
+	if !got {
+		return al, lastErr
+	}
+
+	al = b.cleanArchive(al)
+	if bs, err := json.Marshal(al); err == nil {
+		ioutil.WriteFile(bingArchiveFile, bs, 0666)
+	}
+	return al, nil
+}
+
+func (b *IodBing) getBingData(idx int, mkt string) (bingdata, error) {
+	bd := bingdata{}
+	u := fmt.Sprintf("%s/HPImageArchive.aspx?format=js&idx=%d&n=8&mkt=%s", b.getAPIURL(), idx, url.QueryEscape(mkt))
+	j, err := GetHTTPClient().Get(u)
+	if err != nil {
+		return bd, err
+	}
+	err = json.Unmarshal(j, &bd)
+	return bd, err
+}
+
+// downloadImages downloads the images that are not yet in the archive
+func (b *IodBing) downloadImages(bd *bingdata, mkt string, al []bingArchiveImage) ([]bingArchiveImage, error) {
+	l := []bingArchiveImage{}
 	path := "./img/bing"
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// path does not exist, create it
@@ -80,57 +155,95 @@ func (b *IodBing) downloadImages(bd *bingdata) ([]DisplayImage, error) {
 	}
 
 	xRes, yRes := b.Config.GetResolution()
-	res := ""
-	switch b.Config.Resolution {
-	case 0: // 800x480
-		res = "_800x600"
-	}
+	res := b.getSizeSuffix(xRes, yRes)
+	min := time.Now().AddDate(0, 0, -b.Config.BingArchiveDays).Format("20060102")
 
+	r := GetRatings()
+	nl := []bingArchiveImage{}
+	dl := []DownloadJob{}
 	for _, i := range bd.Images {
-		// Check to see if the file already exists
+		if i.StartDate != "" && i.StartDate < min {
+			continue
+		}
+		// The same image is shown in several markets, so only keep it once
+		if len([]rune(i.Urlbase)) <= 7 {
+			b.LogError("Skipping image with invalid url '", i.Urlbase, "'")
+			continue
+		}
 		fs := string([]rune(i.Urlbase)[7:])
 		fn := filepath.Base(fs) + ".jpg"
+		if b.inArchive(append(al, nl...), fn, i.Hash) {
+			continue
+		}
 		fp := filepath.Join(path, fn)
-		load := true
+		if r.IsBanned(fp) {
+			b.LogInfo("Skipping banned image '", fn, "'")
+			continue
+		}
 		b.LogInfo("Checking if file '", fp, "' exits (", i.Urlbase, ")")
 		_, err := os.Stat(fp)
 		if os.IsNotExist(err) {
 			// File does not exist, so download it
 			b.LogInfo("Downloading ", fp)
-			url := "https://bing.com" + i.Urlbase + res + ".jpg"
-			err = b.downloadImage(fp, fn, url, xRes, yRes)
-			if err != nil {
-				b.LogError("Failed with ", err.Error())
-				load = false
-			}
-
+			dl = append(dl, DownloadJob{URL: b.getAPIURL() + i.Urlbase + res + ".jpg", Path: fp, Width: xRes, Height: yRes})
 		} else if err != nil {
 			b.LogError("Failed with ", err.Error())
-			load = false
-		}
-		if load {
-			// Add the image to the list to return
-			l = append(l, DisplayImage{
-				Name:      fn,
-				Copyright: i.Copyright,
-				ImagePath: fp,
-			})
-		} else {
-			// There was an issue processing the image,
-			// remove the file from the disk if anything was written
-			if _, err := os.Stat(fp); err == nil {
-				b.LogInfo("Removing file ", fp)
-				os.Remove(fp)
-			}
+			continue
 		}
+		nl = append(nl, bingArchiveImage{
+			Name:      fn,
+			Copyright: i.Copyright,
+			Date:      i.StartDate,
+			Hash:      i.Hash,
+			Market:    mkt,
+		})
 	}
 
+	// Download the missing images
+	failed := map[string]bool{}
+	for n, err := range NewDownloader(b.Config).Download(dl) {
+		if err != nil {
+			b.LogError("Failed with ", err.Error())
+			failed[filepath.Base(dl[n].Path)] = true
+		}
+	}
+	for _, i := range nl {
+		// Add the image to the archive
+		if !failed[i.Name] {
+			l = append(l, i)
+		}
+	}
+
+	return l, nil
+}
+
+// cleanArchive removes the images older than the number of days to keep,
+// along with any other file in the image folder
+func (b *IodBing) cleanArchive(al []bingArchiveImage) []bingArchiveImage {
+	path := "./img/bing"
+	min := time.Now().AddDate(0, 0, -b.Config.BingArchiveDays).Format("20060102")
+	r := GetRatings()
+
+	l := []bingArchiveImage{}
+	for _, i := range al {
+		if _, err := os.Stat(filepath.Join(path, i.Name)); err != nil {
+			continue
+		}
+		if i.Date < min && !r.IsFavourite(filepath.Join(path, i.Name)) {
+			continue
+		}
+		l = append(l, i)
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Date > l[j].Date
+	})
+
 	// Remove any other file in this folder
 	fi, err := ioutil.ReadDir(path)
 	if err == nil {
 		for _, f := range fi {
-			// Check if this file is in the list
-			remove := true
+			// Check if this file is in the archive or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
 			for _, i := range l {
 				if i.Name == f.Name() {
 					remove = false
@@ -146,50 +259,74 @@ func (b *IodBing) downloadImages(bd *bingdata) ([]DisplayImage, error) {
 			}
 		}
 	}
-	if err == nil {
-		b, err := json.Marshal(l)
-		if err != nil {
-			return nil, err
-		}
-		ioutil.WriteFile("lastiodbing.json", b, 0666)
-	}
 
-	return l, err
+	return l
 }
 
-func (b *IodBing) downloadImage(fp string, fn string, url string, xRes int, yRes int) error {
-	res, err := http.Get(url)
-	if res != nil {
-		defer res.Body.Close()
-		res.Close = true
+// selectImages returns the images to display, the latest images of the day
+// followed by a random selection of older images from the archive
+func (b *IodBing) selectImages(al []bingArchiveImage) []DisplayImage {
+	l := []DisplayImage{}
+	if len(al) == 0 {
+		return l
 	}
-	if err != nil {
-		b.LogError("Error getting image file from url [", url, "]. ", err.Error())
-		return err
+	path := "./img/bing"
+	r := GetRatings()
+	old := []bingArchiveImage{}
+	for _, i := range al {
+		if r.IsBanned(filepath.Join(path, i.Name)) {
+			continue
+		}
+		if i.Date == al[0].Date {
+			l = append(l, b.getDisplayImage(i))
+		} else {
+			old = append(old, i)
+		}
 	}
-	fd, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		b.LogError("Error reading image file from response body. [", url, "]. ", err.Error())
-		return err
+	rand.Shuffle(len(old), func(i, j int) {
+		old[i], old[j] = old[j], old[i]
+	})
+	for _, i := range old {
+		l = append(l, b.getDisplayImage(i))
 	}
-	b.LogInfo("Downloading file to ", fp)
-	err = ioutil.WriteFile(fp, fd, 0666)
-	if err != nil {
-		b.LogError("Error writing image file ", fp, ". ", err.Error())
-		return err
+	if len(l) > b.Config.ImgCount {
+		l = l[:b.Config.ImgCount]
 	}
-	// Resize the image
-	img, err := imaging.Open(fp)
-	if err != nil {
-		b.LogError("Error opening image for resizing. " + err.Error())
-	} else {
-		img = imaging.Fill(img, xRes, yRes, imaging.Center, imaging.Lanczos)
-		err = imaging.Save(img, fp)
-		if err != nil {
-			b.LogError("Error saving resized image file ", fp, ".", err.Error())
+	return l
+}
+
+func (b *IodBing) getDisplayImage(i bingArchiveImage) DisplayImage {
+	return DisplayImage{
+		Name:      i.Name,
+		Copyright: i.Copyright,
+		ImagePath: filepath.Join("./img/bing", i.Name),
+	}
+}
+
+func (b *IodBing) inArchive(al []bingArchiveImage, fn string, hash string) bool {
+	for _, i := range al {
+		if i.Name == fn || (hash != "" && i.Hash == hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSizeSuffix returns the suffix of the smallest Bing image that covers the frame
+func (b *IodBing) getSizeSuffix(xRes int, yRes int) string {
+	for _, s := range bingSizes {
+		if s.Width >= xRes && s.Height >= yRes {
+			return fmt.Sprintf("_%dx%d", s.Width, s.Height)
 		}
 	}
-	return err
+	return "_UHD"
+}
+
+func (b *IodBing) getAPIURL() string {
+	if b.apiURL != "" {
+		return strings.TrimSuffix(b.apiURL, "/")
+	}
+	return "https://www.bing.com"
 }
 
 // LogInfo is used to log information messages for this controller.
@@ -1,18 +1,75 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
+// newBingTestServer returns a stand-in for the Bing image archive with 15 days of images.
+// The images for even days are the same in every market.
+func newBingTestServer(t *testing.T, downloads *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/HPImageArchive.aspx", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		idx, _ := strconv.Atoi(q.Get("idx"))
+		if idx > 7 {
+			t.Error("Bing doesn't go back more than 7 days, idx", idx, "requested")
+			idx = 7
+		}
+		mkt := strings.ToUpper(strings.Split(q.Get("mkt"), "-")[1])
+		il := []string{}
+		for d := idx; d < idx+8; d++ {
+			h := fmt.Sprintf("h%d", d)
+			if d%2 == 1 {
+				h = h + mkt
+			}
+			il = append(il, fmt.Sprintf(`{"startdate":"%s","urlbase":"/th?id=OHR.Day%d_%s%d","copyright":"Day %d (%s)","hsh":"%s"}`,
+				time.Now().AddDate(0, 0, -d).Format("20060102"), d, mkt, d, d, mkt, h))
+		}
+		if idx == 0 {
+			// A new market may return an image without a url
+			il = append(il, fmt.Sprintf(`{"startdate":"%s","urlbase":"","hsh":"empty"}`, time.Now().Format("20060102")))
+		}
+		fmt.Fprintf(w, `{"images":[%s]}`, strings.Join(il, ","))
+	})
+	mux.HandleFunc("/th", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Query().Get("id"), "_800x480.jpg") {
+			t.Error("Image not requested at the display resolution", r.URL.RawQuery)
+		}
+		*downloads++
+		jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 800, 480)), nil)
+	})
+	return httptest.NewServer(mux)
+}
+
 func TestCanGetBingImages(t *testing.T) {
+	os.RemoveAll("./img/bing")
+	os.Remove(bingArchiveFile)
+	defer os.Remove(bingArchiveFile)
+
+	downloads := 0
+	srv := newBingTestServer(t, &downloads)
+	defer srv.Close()
+
 	c := Config{
-		Provider:   0,
-		ImgCount:   8,
-		Resolution: 0,
+		Provider:    0,
+		ImgCount:    8,
+		Resolution:  0,
+		BingMarkets: []string{"en-US", "en-GB"},
 	}
 	c.SetDefaults()
 
-	i := IodBing{Config: c}
+	i := IodBing{Config: c, apiURL: srv.URL}
 	l, err := i.GetImages()
 	if err != nil {
 		t.Error(err)
@@ -21,4 +78,46 @@ func TestCanGetBingImages(t *testing.T) {
 	if len(l) != 8 {
 		t.Error("Only", len(l), "images returned, expected 8.")
 	}
+	if l[0].Name != "OHR.Day0_US0.jpg" || l[0].Copyright != "Day 0 (US)" {
+		t.Error("Expected the latest image first, got", l[0])
+	}
+
+	// 15 days from the first market and the 7 odd days that differ in the second
+	al := []bingArchiveImage{}
+	b, _ := ioutil.ReadFile(bingArchiveFile)
+	json.Unmarshal(b, &al)
+	if len(al) != 22 || downloads != 22 {
+		t.Error(len(al), "images archived and", downloads, "downloaded, expected 22.")
+	}
+
+	// Only the new images are downloaded, and the old ones are removed from the archive
+	i.Config.BingArchiveDays = 5
+	if _, err = i.GetImages(); err != nil {
+		t.Error(err)
+	}
+	b, _ = ioutil.ReadFile(bingArchiveFile)
+	al = []bingArchiveImage{}
+	json.Unmarshal(b, &al)
+	fl, _ := ioutil.ReadDir("./img/bing")
+	if downloads != 22 || len(al) != 9 || len(fl) != 9 {
+		t.Error("Unexpected archive", downloads, len(al), len(fl))
+	}
+}
+
+func TestCanGetBingSizeSuffix(t *testing.T) {
+	b := IodBing{}
+	for _, s := range []struct {
+		x, y int
+		exp  string
+	}{
+		{800, 480, "_800x480"},
+		{1024, 600, "_1024x768"},
+		{480, 800, "_480x800"},
+		{1920, 1080, "_1920x1080"},
+		{2560, 1440, "_UHD"},
+	} {
+		if r := b.getSizeSuffix(s.x, s.y); r != s.exp {
+			t.Error("Expected", s.exp, "for", s.x, s.y, "got", r)
+		}
+	}
 }
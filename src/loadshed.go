@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"time"
 )
 
@@ -25,16 +24,9 @@ type Loadshed struct {
 // GetLoadshedInfo returns the current load shedding forecasts
 func GetLoadshedInfo(c Config) (Loadshed, error) {
 	m := Loadshed{}
-	resp, err := http.Get(fmt.Sprintf("%s/forecast/get", c.LoadshedUrl))
-	if resp != nil {
-		defer resp.Body.Close()
-		resp.Close = true
-	}
+	b, err := GetHTTPClient().Get(fmt.Sprintf("%s/forecast/get", c.LoadshedUrl))
 	if err == nil {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			err = json.Unmarshal(b, &m)
-		}
+		err = json.Unmarshal(b, &m)
 	}
 
 	if err == nil {
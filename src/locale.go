@@ -0,0 +1,526 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultLocale is the locale used when none has been configured or the configured one is unknown
+const defaultLocale = "en"
+
+// Locale holds the translations and the date and time formats for a language
+type Locale struct {
+	Code            string            // Code of the language, e.g. af
+	Name            string            // Name of the language in the language itself
+	Days            [7]string         // Names of the days of the week, starting on Sunday
+	ShortDays       [7]string         // Abbreviated names of the days of the week, starting on Sunday
+	Months          [12]string        // Names of the months
+	DateFormat      string            // Layout of a date, using the English month name which is replaced
+	TimeFormat      string            // Layout of a time of day, e.g. sunrise
+	ClockFormat     string            // Layout of the clock drawn on the display
+	HourFormat      string            // Layout of an hour on the hourly forecast chart
+	CompassPoints   [16]string        // Abbreviated points of the compass, clockwise from north
+	BirthdayFormats []string          // Formats of the calendar birthday event summaries, %s is the name
+	Labels          map[string]string // Translations of the labels, keyed by the English label
+}
+
+// GetLocale returns the locale for the language code, e.g. de or de-DE.
+// The English locale is returned if the language is not supported.
+func GetLocale(code string) Locale {
+	code = strings.ToLower(strings.Replace(strings.TrimSpace(code), "_", "-", -1))
+	if l, ok := locales[code]; ok {
+		return l
+	}
+	if i := strings.Index(code, "-"); i > 0 {
+		if l, ok := locales[code[:i]]; ok {
+			return l
+		}
+	}
+	return locales[defaultLocale]
+}
+
+// hasLocale returns whether there is a catalogue for the language code
+func hasLocale(code string) bool {
+	_, ok := locales[code]
+	return ok
+}
+
+// GetLocales returns the supported locales ordered by code
+func GetLocales() []Locale {
+	l := []Locale{}
+	for _, v := range locales {
+		l = append(l, v)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Code < l[j].Code })
+	return l
+}
+
+// T returns the translation of the label, or the label itself if it has not been translated
+func (l Locale) T(s string) string {
+	if t, ok := l.Labels[s]; ok {
+		return t
+	}
+	// Labels from the other services are not always capitalised the same way
+	for k, t := range l.Labels {
+		if strings.EqualFold(k, s) {
+			return t
+		}
+	}
+	return s
+}
+
+// DayName returns the name of the day of the week
+func (l Locale) DayName(t time.Time) string {
+	return l.Days[t.Weekday()]
+}
+
+// ShortDayName returns the abbreviated name of the day of the week
+func (l Locale) ShortDayName(t time.Time) string {
+	return l.ShortDays[t.Weekday()]
+}
+
+// ShortDayOf returns the abbreviated name of a day given its English name, e.g. Mon or Monday.
+// The first three letters are returned if the name is not an English day name.
+func (l Locale) ShortDayOf(name string) string {
+	for n, d := range locales[defaultLocale].Days {
+		if len(name) >= 3 && strings.EqualFold(name[:3], d[:3]) {
+			return l.ShortDays[n]
+		}
+	}
+	r := []rune(name)
+	if len(r) > 3 {
+		r = r[:3]
+	}
+	return string(r)
+}
+
+// MonthName returns the name of the month
+func (l Locale) MonthName(t time.Time) string {
+	return l.Months[t.Month()-1]
+}
+
+// FormatDate returns the date written out in full, e.g. 2 January 2006
+func (l Locale) FormatDate(t time.Time) string {
+	return strings.Replace(t.Format(l.DateFormat), t.Month().String(), l.MonthName(t), 1)
+}
+
+// FormatTime returns the time of day, e.g. the sunrise time
+func (l Locale) FormatTime(t time.Time) string {
+	return t.Format(l.TimeFormat)
+}
+
+// FormatClock returns the time as it is shown on the clock of the display
+func (l Locale) FormatClock(t time.Time) string {
+	return t.Format(l.ClockFormat)
+}
+
+// CompassPoint returns the point of the 16-point compass nearest to the direction in degrees
+func (l Locale) CompassPoint(deg float32) string {
+	d := math.Mod(float64(deg), 360)
+	if d < 0 {
+		d = d + 360
+	}
+	n := int(math.Floor(d/compassInterval+0.5)) % len(l.CompassPoints)
+	return l.CompassPoints[n]
+}
+
+// FormatHour returns the hour as it is marked on the hourly forecast chart
+func (l Locale) FormatHour(t time.Time) string {
+	return t.Format(l.HourFormat)
+}
+
+// StripBirthday returns the name from a birthday calendar event summary.
+// Summaries written in English are also recognised, as the calendar may not use the same language.
+func (l Locale) StripBirthday(s string) string {
+	fl := l.BirthdayFormats
+	if l.Code != defaultLocale {
+		fl = append(append([]string{}, fl...), locales[defaultLocale].BirthdayFormats...)
+	}
+	for _, f := range fl {
+		p := strings.SplitN(f, "%s", 2)
+		if len(p) != 2 {
+			continue
+		}
+		if p[0] != "" && strings.HasPrefix(s, p[0]) {
+			s = strings.TrimPrefix(s, p[0])
+		}
+		if p[1] != "" {
+			s = strings.Replace(s, p[1], "", -1)
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// locales holds the catalogue of each supported language, keyed by the language code
+var locales = map[string]Locale{
+	"en": {
+		Code:            "en",
+		Name:            "English",
+		Days:            [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		ShortDays:       [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		Months:          [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		DateFormat:      "2 January 2006",
+		TimeFormat:      "3:04PM",
+		ClockFormat:     "15:04",
+		HourFormat:      "3PM",
+		CompassPoints:   [16]string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"},
+		BirthdayFormats: []string{"%s's birthday"},
+		Labels:          map[string]string{},
+	},
+	"af": {
+		Code:            "af",
+		Name:            "Afrikaans",
+		Days:            [7]string{"Sondag", "Maandag", "Dinsdag", "Woensdag", "Donderdag", "Vrydag", "Saterdag"},
+		ShortDays:       [7]string{"So", "Ma", "Di", "Wo", "Do", "Vr", "Sa"},
+		Months:          [12]string{"Januarie", "Februarie", "Maart", "April", "Mei", "Junie", "Julie", "Augustus", "September", "Oktober", "November", "Desember"},
+		DateFormat:      "2 January 2006",
+		TimeFormat:      "15:04",
+		ClockFormat:     "15:04",
+		HourFormat:      "15:00",
+		CompassPoints:   [16]string{"N", "NNO", "NO", "ONO", "O", "OSO", "SO", "SSO", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"},
+		BirthdayFormats: []string{"%s se verjaarsdag"},
+		Labels: map[string]string{
+			// Configuration page
+			"Configure Display":                     "Stel Vertoning In",
+			"Gallery":                               "Galery",
+			"Currently on Frame":                    "Tans op Raam",
+			"Display":                               "Vertoning",
+			"Screen Resolution":                     "Skermresolusie",
+			"Language":                              "Taal",
+			"Images":                                "Beelde",
+			"Image Provider":                        "Beeldverskaffer",
+			"Bing Image of the Day":                 "Bing Beeld van die Dag",
+			"Lorem Picsum Random Image":             "Lorem Picsum Lukrake Beeld",
+			"Pexels Curated Image":                  "Pexels Gekeurde Beeld",
+			"National Geographic Photo of the Day":  "National Geographic Foto van die Dag",
+			"File Folder":                           "Lêergids",
+			"NASA Astronomy Picture of the Day":     "NASA Sterrekundebeeld van die Dag",
+			"Wikimedia Commons Picture of the Day":  "Wikimedia Commons Beeld van die Dag",
+			"RSS / Atom Image Feed":                 "RSS / Atom Beeldvoer",
+			"WebDAV / Nextcloud Folder":             "WebDAV / Nextcloud Gids",
+			"S3 Compatible Bucket":                  "S3-versoenbare Emmer",
+			"External Command":                      "Eksterne Opdrag",
+			"Email (IMAP Mailbox)":                  "E-pos (IMAP-posbus)",
+			"Fit to Display":                        "Pas op Skerm",
+			"Crop to Centre":                        "Sny na Middel",
+			"Smart Crop":                            "Slim Sny",
+			"Letterbox over Blurred Image":          "Pas in oor Vervaagde Beeld",
+			"Number of Images":                      "Aantal Beelde",
+			"Pair Portrait Images":                  "Koppel Portretbeelde",
+			"Off":                                   "Af",
+			"On":                                    "Aan",
+			"Display Data":                          "Vertoon Data",
+			"Current Weather and Forecast":          "Huidige Weer en Voorspelling",
+			"Hourly Forecast Chart":                 "Uurlikse Voorspellingsgrafiek",
+			"Severe Weather Alerts":                 "Erge Weerwaarskuwings",
+			"Calendar Events":                       "Kalendergebeure",
+			"Save Changes":                          "Stoor Veranderinge",
+			"Rebuild Display":                       "Herbou Vertoning",
+			"Refresh Display":                       "Verfris Vertoning",
+			"Update was successful.":                "Opdatering was suksesvol.",
+			"Rebuilding display...":                 "Vertoning word herbou...",
+			"Display rebuild successful.":           "Vertoning suksesvol herbou.",
+			"Refreshing display...":                 "Vertoning word verfris...",
+			"Display refresh successful.":           "Vertoning suksesvol verfris.",
+			"The Resolution must be specified":      "Die Resolusie moet gespesifiseer word",
+			"Invalid Resolution value":              "Ongeldige Resolusie",
+			"The Image Provider must be selected":   "Die Beeldverskaffer moet gekies word",
+			"Invalid Image Provider value":          "Ongeldige Beeldverskaffer",
+			"The Image Count must be provided":      "Die Aantal Beelde moet verskaf word",
+			"Image Count must be greater than zero": "Die Aantal Beelde moet groter as nul wees",
+			"Invalid Fit Mode value":                "Ongeldige Passing",
+			"Invalid Language value":                "Ongeldige Taal",
+			"Units":                                 "Eenhede",
+			"Temperature":                           "Temperatuur",
+			"Wind Speed":                            "Windspoed",
+			"Air Pressure":                          "Lugdruk",
+			"Invalid Unit value":                    "Ongeldige Eenheid",
+			// Gallery page
+			"Configuration":                "Konfigurasie",
+			"There are no images to show.": "Daar is geen beelde om te wys nie.",
+			"Image removed":                "Beeld verwyder",
+			"Not rated":                    "Nie gegradeer nie",
+			"1 star":                       "1 ster",
+			"2 stars":                      "2 sterre",
+			"3 stars":                      "3 sterre",
+			"4 stars":                      "4 sterre",
+			"5 stars":                      "5 sterre",
+			"Favourite":                    "Gunsteling",
+			"Ban":                          "Verban",
+			"Unban":                        "Ontban",
+			"Ban this image? It will not be shown on the frame again.": "Verban hierdie beeld? Dit sal nie weer op die raam gewys word nie.",
+			// Alert validity
+			"from":  "vanaf",
+			"until": "tot",
+			// Moon phases
+			"New Moon":        "Nuwemaan",
+			"Waxing Crescent": "Groeiende Sekel",
+			"First Quarter":   "Eerste Kwartier",
+			"Waxing Gibbous":  "Groeiende Bolmaan",
+			"Full Moon":       "Volmaan",
+			"Waning Gibbous":  "Afnemende Bolmaan",
+			"Last Quarter":    "Laaste Kwartier",
+			"Third Quarter":   "Laaste Kwartier",
+			"Waning Crescent": "Afnemende Sekel",
+			// Weather descriptions
+			"Clear":         "Helder",
+			"Sunny":         "Sonnig",
+			"Mostly Sunny":  "Meestal Sonnig",
+			"Partly Cloudy": "Gedeeltelik Bewolk",
+			"Mostly Cloudy": "Meestal Bewolk",
+			"Cloudy":        "Bewolk",
+			"Overcast":      "Oortrokke",
+			"Fog":           "Mis",
+			"Mist":          "Newel",
+			"Haze":          "Waas",
+			"Drizzle":       "Motreën",
+			"Light Rain":    "Ligte Reën",
+			"Rain":          "Reën",
+			"Heavy Rain":    "Swaar Reën",
+			"Showers":       "Buie",
+			"Thunderstorm":  "Donderstorm",
+			"Snow":          "Sneeu",
+			"Sleet":         "Ysreën",
+			"Hail":          "Hael",
+			"Windy":         "Winderig",
+		},
+	},
+	"de": {
+		Code:            "de",
+		Name:            "Deutsch",
+		Days:            [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		ShortDays:       [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		Months:          [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		DateFormat:      "2. January 2006",
+		TimeFormat:      "15:04",
+		ClockFormat:     "15:04",
+		HourFormat:      "15:00",
+		CompassPoints:   [16]string{"N", "NNO", "NO", "ONO", "O", "OSO", "SO", "SSO", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"},
+		BirthdayFormats: []string{"Geburtstag von %s", "%s hat Geburtstag"},
+		Labels: map[string]string{
+			// Configuration page
+			"Configure Display":                     "Anzeige konfigurieren",
+			"Gallery":                               "Galerie",
+			"Currently on Frame":                    "Derzeit im Rahmen",
+			"Display":                               "Anzeige",
+			"Screen Resolution":                     "Bildschirmauflösung",
+			"Language":                              "Sprache",
+			"Images":                                "Bilder",
+			"Image Provider":                        "Bildquelle",
+			"Bing Image of the Day":                 "Bing Bild des Tages",
+			"Lorem Picsum Random Image":             "Lorem Picsum Zufallsbild",
+			"Pexels Curated Image":                  "Pexels kuratiertes Bild",
+			"National Geographic Photo of the Day":  "National Geographic Foto des Tages",
+			"File Folder":                           "Dateiordner",
+			"NASA Astronomy Picture of the Day":     "NASA Astronomiebild des Tages",
+			"Wikimedia Commons Picture of the Day":  "Wikimedia Commons Bild des Tages",
+			"RSS / Atom Image Feed":                 "RSS- / Atom-Bilderfeed",
+			"WebDAV / Nextcloud Folder":             "WebDAV- / Nextcloud-Ordner",
+			"S3 Compatible Bucket":                  "S3-kompatibler Bucket",
+			"External Command":                      "Externer Befehl",
+			"Email (IMAP Mailbox)":                  "E-Mail (IMAP-Postfach)",
+			"Fit to Display":                        "An Anzeige anpassen",
+			"Crop to Centre":                        "Mittig zuschneiden",
+			"Smart Crop":                            "Intelligent zuschneiden",
+			"Letterbox over Blurred Image":          "Einpassen über unscharfem Bild",
+			"Number of Images":                      "Anzahl der Bilder",
+			"Pair Portrait Images":                  "Hochformatbilder paaren",
+			"Off":                                   "Aus",
+			"On":                                    "An",
+			"Display Data":                          "Angezeigte Daten",
+			"Current Weather and Forecast":          "Aktuelles Wetter und Vorhersage",
+			"Hourly Forecast Chart":                 "Stündliches Vorhersagediagramm",
+			"Severe Weather Alerts":                 "Unwetterwarnungen",
+			"Calendar Events":                       "Kalendertermine",
+			"Save Changes":                          "Änderungen speichern",
+			"Rebuild Display":                       "Anzeige neu erstellen",
+			"Refresh Display":                       "Anzeige aktualisieren",
+			"Update was successful.":                "Aktualisierung erfolgreich.",
+			"Rebuilding display...":                 "Anzeige wird neu erstellt...",
+			"Display rebuild successful.":           "Anzeige erfolgreich neu erstellt.",
+			"Refreshing display...":                 "Anzeige wird aktualisiert...",
+			"Display refresh successful.":           "Anzeige erfolgreich aktualisiert.",
+			"The Resolution must be specified":      "Die Auflösung muss angegeben werden",
+			"Invalid Resolution value":              "Ungültige Auflösung",
+			"The Image Provider must be selected":   "Die Bildquelle muss ausgewählt werden",
+			"Invalid Image Provider value":          "Ungültige Bildquelle",
+			"The Image Count must be provided":      "Die Anzahl der Bilder muss angegeben werden",
+			"Image Count must be greater than zero": "Die Anzahl der Bilder muss größer als null sein",
+			"Invalid Fit Mode value":                "Ungültige Anpassung",
+			"Invalid Language value":                "Ungültige Sprache",
+			"Units":                                 "Einheiten",
+			"Temperature":                           "Temperatur",
+			"Wind Speed":                            "Windgeschwindigkeit",
+			"Air Pressure":                          "Luftdruck",
+			"Invalid Unit value":                    "Ungültige Einheit",
+			// Gallery page
+			"Configuration":                "Konfiguration",
+			"There are no images to show.": "Es gibt keine Bilder zum Anzeigen.",
+			"Image removed":                "Bild entfernt",
+			"Not rated":                    "Nicht bewertet",
+			"1 star":                       "1 Stern",
+			"2 stars":                      "2 Sterne",
+			"3 stars":                      "3 Sterne",
+			"4 stars":                      "4 Sterne",
+			"5 stars":                      "5 Sterne",
+			"Favourite":                    "Favorit",
+			"Ban":                          "Sperren",
+			"Unban":                        "Entsperren",
+			"Ban this image? It will not be shown on the frame again.": "Dieses Bild sperren? Es wird nicht mehr im Rahmen angezeigt.",
+			// Alert validity
+			"from":  "ab",
+			"until": "bis",
+			// Moon phases
+			"New Moon":        "Neumond",
+			"Waxing Crescent": "Zunehmende Sichel",
+			"First Quarter":   "Erstes Viertel",
+			"Waxing Gibbous":  "Zunehmender Mond",
+			"Full Moon":       "Vollmond",
+			"Waning Gibbous":  "Abnehmender Mond",
+			"Last Quarter":    "Letztes Viertel",
+			"Third Quarter":   "Letztes Viertel",
+			"Waning Crescent": "Abnehmende Sichel",
+			// Weather descriptions
+			"Clear":         "Klar",
+			"Sunny":         "Sonnig",
+			"Mostly Sunny":  "Überwiegend sonnig",
+			"Partly Cloudy": "Teilweise bewölkt",
+			"Mostly Cloudy": "Überwiegend bewölkt",
+			"Cloudy":        "Bewölkt",
+			"Overcast":      "Bedeckt",
+			"Fog":           "Nebel",
+			"Mist":          "Dunst",
+			"Haze":          "Diesig",
+			"Drizzle":       "Nieselregen",
+			"Light Rain":    "Leichter Regen",
+			"Rain":          "Regen",
+			"Heavy Rain":    "Starker Regen",
+			"Showers":       "Schauer",
+			"Thunderstorm":  "Gewitter",
+			"Snow":          "Schnee",
+			"Sleet":         "Schneeregen",
+			"Hail":          "Hagel",
+			"Windy":         "Windig",
+		},
+	},
+	"fr": {
+		Code:            "fr",
+		Name:            "Français",
+		Days:            [7]string{"Dimanche", "Lundi", "Mardi", "Mercredi", "Jeudi", "Vendredi", "Samedi"},
+		ShortDays:       [7]string{"Dim", "Lun", "Mar", "Mer", "Jeu", "Ven", "Sam"},
+		Months:          [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		DateFormat:      "2 January 2006",
+		TimeFormat:      "15:04",
+		ClockFormat:     "15:04",
+		HourFormat:      "15h",
+		CompassPoints:   [16]string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSO", "SO", "OSO", "O", "ONO", "NO", "NNO"},
+		BirthdayFormats: []string{"Anniversaire de %s"},
+		Labels: map[string]string{
+			// Configuration page
+			"Configure Display":                     "Configurer l'affichage",
+			"Gallery":                               "Galerie",
+			"Currently on Frame":                    "Actuellement sur le cadre",
+			"Display":                               "Affichage",
+			"Screen Resolution":                     "Résolution de l'écran",
+			"Language":                              "Langue",
+			"Images":                                "Images",
+			"Image Provider":                        "Source des images",
+			"Bing Image of the Day":                 "Image du jour Bing",
+			"Lorem Picsum Random Image":             "Image aléatoire Lorem Picsum",
+			"Pexels Curated Image":                  "Image sélectionnée Pexels",
+			"National Geographic Photo of the Day":  "Photo du jour National Geographic",
+			"File Folder":                           "Dossier de fichiers",
+			"NASA Astronomy Picture of the Day":     "Image astronomique du jour de la NASA",
+			"Wikimedia Commons Picture of the Day":  "Image du jour de Wikimedia Commons",
+			"RSS / Atom Image Feed":                 "Flux d'images RSS / Atom",
+			"WebDAV / Nextcloud Folder":             "Dossier WebDAV / Nextcloud",
+			"S3 Compatible Bucket":                  "Bucket compatible S3",
+			"External Command":                      "Commande externe",
+			"Email (IMAP Mailbox)":                  "E-mail (boîte IMAP)",
+			"Fit to Display":                        "Ajuster à l'écran",
+			"Crop to Centre":                        "Recadrer au centre",
+			"Smart Crop":                            "Recadrage intelligent",
+			"Letterbox over Blurred Image":          "Ajuster sur image floutée",
+			"Number of Images":                      "Nombre d'images",
+			"Pair Portrait Images":                  "Associer les images portrait",
+			"Off":                                   "Non",
+			"On":                                    "Oui",
+			"Display Data":                          "Données affichées",
+			"Current Weather and Forecast":          "Météo actuelle et prévisions",
+			"Hourly Forecast Chart":                 "Graphique des prévisions horaires",
+			"Severe Weather Alerts":                 "Alertes météo sévères",
+			"Calendar Events":                       "Événements du calendrier",
+			"Save Changes":                          "Enregistrer",
+			"Rebuild Display":                       "Reconstruire l'affichage",
+			"Refresh Display":                       "Actualiser l'affichage",
+			"Update was successful.":                "Mise à jour réussie.",
+			"Rebuilding display...":                 "Reconstruction de l'affichage...",
+			"Display rebuild successful.":           "Affichage reconstruit.",
+			"Refreshing display...":                 "Actualisation de l'affichage...",
+			"Display refresh successful.":           "Affichage actualisé.",
+			"The Resolution must be specified":      "La résolution doit être indiquée",
+			"Invalid Resolution value":              "Résolution non valide",
+			"The Image Provider must be selected":   "La source des images doit être choisie",
+			"Invalid Image Provider value":          "Source des images non valide",
+			"The Image Count must be provided":      "Le nombre d'images doit être indiqué",
+			"Image Count must be greater than zero": "Le nombre d'images doit être supérieur à zéro",
+			"Invalid Fit Mode value":                "Ajustement non valide",
+			"Invalid Language value":                "Langue non valide",
+			"Units":                                 "Unités",
+			"Temperature":                           "Température",
+			"Wind Speed":                            "Vitesse du vent",
+			"Air Pressure":                          "Pression atmosphérique",
+			"Invalid Unit value":                    "Unité non valide",
+			// Gallery page
+			"Configuration":                "Configuration",
+			"There are no images to show.": "Il n'y a aucune image à afficher.",
+			"Image removed":                "Image supprimée",
+			"Not rated":                    "Non notée",
+			"1 star":                       "1 étoile",
+			"2 stars":                      "2 étoiles",
+			"3 stars":                      "3 étoiles",
+			"4 stars":                      "4 étoiles",
+			"5 stars":                      "5 étoiles",
+			"Favourite":                    "Favorite",
+			"Ban":                          "Bannir",
+			"Unban":                        "Débannir",
+			"Ban this image? It will not be shown on the frame again.": "Bannir cette image ? Elle ne sera plus affichée sur le cadre.",
+			// Alert validity
+			"from":  "à partir de",
+			"until": "jusqu'à",
+			// Moon phases
+			"New Moon":        "Nouvelle lune",
+			"Waxing Crescent": "Premier croissant",
+			"First Quarter":   "Premier quartier",
+			"Waxing Gibbous":  "Gibbeuse croissante",
+			"Full Moon":       "Pleine lune",
+			"Waning Gibbous":  "Gibbeuse décroissante",
+			"Last Quarter":    "Dernier quartier",
+			"Third Quarter":   "Dernier quartier",
+			"Waning Crescent": "Dernier croissant",
+			// Weather descriptions
+			"Clear":         "Dégagé",
+			"Sunny":         "Ensoleillé",
+			"Mostly Sunny":  "Plutôt ensoleillé",
+			"Partly Cloudy": "Partiellement nuageux",
+			"Mostly Cloudy": "Plutôt nuageux",
+			"Cloudy":        "Nuageux",
+			"Overcast":      "Couvert",
+			"Fog":           "Brouillard",
+			"Mist":          "Brume",
+			"Haze":          "Brume sèche",
+			"Drizzle":       "Bruine",
+			"Light Rain":    "Pluie légère",
+			"Rain":          "Pluie",
+			"Heavy Rain":    "Forte pluie",
+			"Showers":       "Averses",
+			"Thunderstorm":  "Orage",
+			"Snow":          "Neige",
+			"Sleet":         "Neige fondue",
+			"Hail":          "Grêle",
+			"Windy":         "Venteux",
+		},
+	},
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanGetLocale(t *testing.T) {
+	if l := GetLocale("de-DE"); l.Code != "de" {
+		t.Error("Expected de for de-DE, got", l.Code)
+	}
+	if l := GetLocale("af_ZA"); l.Code != "af" {
+		t.Error("Expected af for af_ZA, got", l.Code)
+	}
+	if l := GetLocale("xx"); l.Code != "en" {
+		t.Error("Expected en for an unknown language, got", l.Code)
+	}
+	if l := GetLocale(""); l.Code != "en" {
+		t.Error("Expected en when no language is set, got", l.Code)
+	}
+}
+
+func TestCanFormatDatesForLocale(t *testing.T) {
+	d := time.Date(2023, 3, 6, 18, 5, 0, 0, time.UTC)
+	tests := []struct {
+		Code, Day, Short, Date, Time string
+	}{
+		{"en", "Monday", "Mon", "6 March 2023", "6:05PM"},
+		{"af", "Maandag", "Ma", "6 Maart 2023", "18:05"},
+		{"de", "Montag", "Mo", "6. März 2023", "18:05"},
+		{"fr", "Lundi", "Lun", "6 mars 2023", "18:05"},
+	}
+	for _, tc := range tests {
+		l := GetLocale(tc.Code)
+		if s := l.DayName(d); s != tc.Day {
+			t.Error(tc.Code, "day name", s)
+		}
+		if s := l.ShortDayName(d); s != tc.Short {
+			t.Error(tc.Code, "short day name", s)
+		}
+		if s := l.FormatDate(d); s != tc.Date {
+			t.Error(tc.Code, "date", s)
+		}
+		if s := l.FormatTime(d); s != tc.Time {
+			t.Error(tc.Code, "time", s)
+		}
+		if s := l.FormatClock(d); s != "18:05" {
+			t.Error(tc.Code, "clock", s)
+		}
+	}
+}
+
+func TestCanGetShortDayOfEnglishName(t *testing.T) {
+	l := GetLocale("de")
+	if s := l.ShortDayOf("Wednesday"); s != "Mi" {
+		t.Error("Unexpected short day", s)
+	}
+	if s := l.ShortDayOf("thu"); s != "Do" {
+		t.Error("Unexpected short day", s)
+	}
+	if s := l.ShortDayOf("Mañana"); s != "Mañ" {
+		t.Error("Unexpected short day", s)
+	}
+}
+
+func TestCanTranslateLabels(t *testing.T) {
+	l := GetLocale("fr")
+	if s := l.T("Full Moon"); s != "Pleine lune" {
+		t.Error("Unexpected translation", s)
+	}
+	if s := l.T("partly cloudy"); s != "Partiellement nuageux" {
+		t.Error("Unexpected translation", s)
+	}
+	if s := l.T("Volcanic Ash"); s != "Volcanic Ash" {
+		t.Error("Expected the label for a missing translation, got", s)
+	}
+	// Every translated label must be translated in all the languages
+	for _, a := range GetLocales() {
+		for k := range a.Labels {
+			for _, b := range GetLocales() {
+				if _, ok := b.Labels[k]; !ok && b.Code != defaultLocale {
+					t.Error(b.Code, "is missing a translation for", k)
+				}
+			}
+		}
+	}
+}
+
+func TestCanStripBirthday(t *testing.T) {
+	tests := []struct {
+		Code, Summary, Name string
+	}{
+		{"en", "Anna's birthday", "Anna"},
+		{"af", "Anna se verjaarsdag", "Anna"},
+		{"af", "Anna's birthday", "Anna"},
+		{"de", "Geburtstag von Anna", "Anna"},
+		{"fr", "Anniversaire de Anna", "Anna"},
+		{"fr", "Dentist", "Dentist"},
+	}
+	for _, tc := range tests {
+		if s := GetLocale(tc.Code).StripBirthday(tc.Summary); s != tc.Name {
+			t.Error(tc.Code, "unexpected name", s)
+		}
+	}
+}
+
+func TestCanTranslateConfigPage(t *testing.T) {
+	loc := GetLocale("de")
+	tp, err := template.New("config.html").Funcs(template.FuncMap{"t": loc.T}).ParseFiles("./html/config.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bytes.Buffer{}
+	err = tp.Execute(b, ConfigPageData{Locale: loc.Code, Locales: GetLocales(), ImgCount: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	for _, w := range []string{`<html lang="de">`, "Bildquelle", "Änderungen speichern", `selected="selected" value="de"`} {
+		if !strings.Contains(s, w) {
+			t.Error("Config page does not contain", w)
+		}
+	}
+}
+
+func TestCanTranslateGalleryPage(t *testing.T) {
+	loc := GetLocale("de")
+	tp, err := template.New("gallery.html").Funcs(template.FuncMap{"t": loc.T}).ParseFiles("./html/gallery.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bytes.Buffer{}
+	err = tp.Execute(b, ImagePageData{Title: "Currently on Frame", Locale: loc.Code, Images: []ImageRating{{ImagePath: "img/bing/a.jpg", Name: "a.jpg", Rating: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	for _, w := range []string{`<html lang="de">`, "<title>Derzeit im Rahmen</title>", "Konfiguration", `selected="selected" value="2">2 Sterne`, "Sperren", `"Dieses Bild sperren?`} {
+		if !strings.Contains(s, w) {
+			t.Error("Gallery page does not contain", w)
+		}
+	}
+}
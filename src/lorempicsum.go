@@ -2,8 +2,6 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
 )
@@ -36,34 +34,25 @@ func (p *LoremPicsum) GetImages() ([]DisplayImage, error) {
 		}
 	}
 
+	dl := []DownloadJob{}
 	for i := 0; i < p.Config.ImgCount; i++ {
-		fn := fmt.Sprintf("image%d.jpg", i)
-		fp := filepath.Join(path, fn)
-		url := fmt.Sprintf("https://picsum.photos%s?random", r)
+		fp := filepath.Join(path, fmt.Sprintf("image%d.jpg", i))
 		p.LogInfo("Downloading ", fp)
-		res, err := http.Get(url)
-		if res != nil {
-			defer res.Body.Close()
-			res.Close = true
-		}
-		if err != nil {
-			return l, err
-		}
-		fd, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return l, err
-		}
-		// Write the image data to the file
-		err = ioutil.WriteFile(fp, fd, 0666)
-		if err != nil {
-			return l, err
+		dl = append(dl, DownloadJob{URL: fmt.Sprintf("https://picsum.photos%s?random", r), Path: fp})
+	}
+	var err error
+	for n, e := range NewDownloader(p.Config).Download(dl) {
+		if e != nil {
+			p.LogError("Error downloading ", dl[n].Path, ". ", e.Error())
+			err = e
+			continue
 		}
 		l = append(l, DisplayImage{
-			Name:      fn,
-			ImagePath: fp,
+			Name:      filepath.Base(dl[n].Path),
+			ImagePath: dl[n].Path,
 		})
 	}
-	return l, nil
+	return l, err
 }
 
 // LogInfo is used to log information messages for this controller.
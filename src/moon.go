@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"time"
 )
 
@@ -20,16 +19,9 @@ type Moon struct {
 // GetMoon returns the details about the current phase of the moon
 func GetMoon(c Config) (Moon, error) {
 	m := Moon{}
-	resp, err := http.Get(fmt.Sprintf("%s/moon/get", c.WeatherUrl))
-	if resp != nil {
-		defer resp.Body.Close()
-		resp.Close = true
-	}
+	b, err := GetHTTPClient().Get(fmt.Sprintf("%s/moon/get", c.WeatherUrl))
 	if err == nil {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			err = json.Unmarshal(b, &m)
-		}
+		err = json.Unmarshal(b, &m)
 	}
 
 	if err == nil {
@@ -4,12 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/disintegration/imaging"
 )
 
 type natgeoData struct {
@@ -76,15 +73,7 @@ func (p *NatGeo) GetImages() ([]DisplayImage, error) {
 
 func (p *NatGeo) getNGData(url string) (natgeoData, error) {
 	// Get the data from the National Geographic site
-	res, err := http.Get(url)
-	if res != nil {
-		defer res.Body.Close()
-		res.Close = true
-	}
-	if err != nil {
-		return natgeoData{}, err
-	}
-	j, err := ioutil.ReadAll(res.Body)
+	j, err := GetHTTPClient().Get(url)
 	if err != nil {
 		return natgeoData{}, err
 	}
@@ -110,40 +99,48 @@ func (p *NatGeo) downloadImages(ngd *natgeoData) ([]DisplayImage, error) {
 
 	xRes, yRes := p.Config.GetResolution()
 
-	for n, i := range ngd.Items {
-		fn := p.getImageID(i.Image.URI)
-		fp := filepath.Join(path, fn)
-		load := true
-		p.LogInfo("Checking image ", fp)
-		if _, err := os.Stat(fp); os.IsNotExist(err) {
-			url := i.Image.URI
-			if url == "" {
-				load = false
-			} else {
-				p.LogInfo("Downloading ", i.Image.Title, fn)
-				err = p.downloadImage(fp, fn, url, xRes, yRes)
-				if err != nil {
-					load = false
+	r := GetRatings()
+	d := NewDownloader(p.Config)
+	items := ngd.Items
+	for len(items) != 0 && len(l) < p.Config.ImgCount {
+		// Download only as many images as are still needed, then try the next ones if any failed
+		il := []DisplayImage{}
+		dl := []DownloadJob{}
+		for len(items) != 0 && len(l)+len(il) < p.Config.ImgCount {
+			i := items[0]
+			items = items[1:]
+			fn := p.getImageID(i.Image.URI)
+			fp := filepath.Join(path, fn)
+			if r.IsBanned(fp) {
+				p.LogInfo("Skipping banned image '", fn, "'")
+				continue
+			}
+			p.LogInfo("Checking image ", fp)
+			if _, err := os.Stat(fp); os.IsNotExist(err) {
+				if i.Image.URI == "" {
+					continue
 				}
+				p.LogInfo("Downloading ", i.Image.Title, fn)
+				dl = append(dl, DownloadJob{URL: i.Image.URI, Path: fp, Width: xRes, Height: yRes})
 			}
-		}
-		if load {
-			// Add the image to the list to return
-			l = append(l, DisplayImage{
+			il = append(il, DisplayImage{
 				Name:      fn,
 				Copyright: fmt.Sprintf("%s - %s", i.Image.Title, i.Image.Credit),
 				ImagePath: fp,
 			})
-		} else {
-			// There was an issue processing the image,
-			// remove the file from the disk if anything was written
-			if _, err := os.Stat(fp); err == nil {
-				p.LogInfo("Removing file ", fp)
-				os.Remove(fp)
+		}
+		failed := map[string]bool{}
+		for n, err := range d.Download(dl) {
+			if err != nil {
+				p.LogError("Error downloading image file ", dl[n].Path, ". ", err.Error())
+				failed[dl[n].Path] = true
 			}
 		}
-		if n+1 == p.Config.ImgCount {
-			break
+		for _, i := range il {
+			// Add the image to the list to return
+			if !failed[i.ImagePath] {
+				l = append(l, i)
+			}
 		}
 	}
 
@@ -151,8 +148,8 @@ func (p *NatGeo) downloadImages(ngd *natgeoData) ([]DisplayImage, error) {
 	fi, err := ioutil.ReadDir(path)
 	if err == nil {
 		for _, f := range fi {
-			// Check if this file is in the list
-			remove := true
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
 			for _, i := range l {
 				if i.Name == f.Name() {
 					remove = false
@@ -179,41 +176,6 @@ func (p *NatGeo) downloadImages(ngd *natgeoData) ([]DisplayImage, error) {
 	return l, nil
 }
 
-func (p *NatGeo) downloadImage(fp string, fn string, url string, xRes int, yRes int) error {
-	res, err := http.Get(url)
-	if res != nil {
-		defer res.Body.Close()
-		res.Close = true
-	}
-	if err != nil {
-		p.LogError("Error getting image file from url", url, ". ", err.Error())
-		return err
-	}
-	fd, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		p.LogError("Error reading image file from response body.", url, ". ", err.Error())
-		return err
-	}
-	err = ioutil.WriteFile(fp, fd, 0666)
-	if err != nil {
-		p.LogError("Error writing image file", fp, ". ", err.Error())
-		return err
-	}
-	// Resize the image
-	img, err := imaging.Open(fp)
-	if err != nil {
-		p.LogError("Error opening image file", fp, "for resizing.", err.Error())
-	} else {
-		img = imaging.Fill(img, xRes, yRes, imaging.Center, imaging.Lanczos)
-		err = imaging.Save(img, fp)
-		if err != nil {
-			p.LogError("Error saving resized image file", fp, ".", err.Error())
-		}
-	}
-
-	return err
-}
-
 func (p *NatGeo) getImageID(url string) string {
 	a := strings.Split(url, "/")
 	l := len(a)
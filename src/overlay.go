@@ -0,0 +1,139 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Styles used to keep the overlay text readable
+const (
+	OverlayAuto    = 0 // Pick the style from the brightness and detail behind the text
+	OverlayShadow  = 1 // Drop shadow
+	OverlayOutline = 2 // Outline around each letter
+	OverlayScrim   = 3 // Translucent rectangle behind the text
+	OverlayPanel   = 4 // Translucent rounded panel behind the text
+)
+
+var (
+	overlayLight = color.RGBA{255, 255, 255, 255}
+	overlayDark  = color.RGBA{24, 24, 24, 255}
+)
+
+// textStyle holds how a piece of text is drawn
+type textStyle struct {
+	Style int         // Overlay style, never OverlayAuto
+	Text  color.Color // Colour of the text
+	Back  color.Color // Colour of the shadow, outline or scrim
+}
+
+// relLuminance returns the relative luminance of the colour, from 0 (black) to 1 (white)
+func relLuminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	lin := func(v uint32) float64 {
+		s := float64(v) / 0xffff
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*lin(r) + 0.7152*lin(g) + 0.0722*lin(b)
+}
+
+// contrastRatio returns the WCAG contrast ratio of the two luminances, from 1 to 21
+func contrastRatio(a float64, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	return (a + 0.05) / (b + 0.05)
+}
+
+// regionStats returns the mean and standard deviation of the luminance of the region of the image
+func regionStats(img image.Image, r image.Rectangle) (float64, float64) {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return 0, 0
+	}
+	// Sample about 1000 pixels, which is plenty for text sized regions
+	step := int(math.Max(1, math.Sqrt(float64(r.Dx()*r.Dy())/1000)))
+	sum, sq, n := 0.0, 0.0, 0.0
+	for y := r.Min.Y; y < r.Max.Y; y += step {
+		for x := r.Min.X; x < r.Max.X; x += step {
+			l := relLuminance(img.At(x, y))
+			sum += l
+			sq += l * l
+			n++
+		}
+	}
+	mean := sum / n
+	return mean, math.Sqrt(math.Max(0, sq/n-mean*mean))
+}
+
+// chooseTextStyle returns how to draw text over the region of the image.
+// The text colour is picked for the best contrast unless a colour is specified.
+func chooseTextStyle(img image.Image, r image.Rectangle, style int, c color.Color, opacity int) textStyle {
+	mean, dev := regionStats(img, r)
+	ts := textStyle{Style: style, Text: c}
+	if ts.Text == nil {
+		ts.Text = overlayLight
+		if contrastRatio(relLuminance(overlayDark), mean) > contrastRatio(relLuminance(overlayLight), mean) {
+			ts.Text = overlayDark
+		}
+	}
+	cr := contrastRatio(relLuminance(ts.Text), mean)
+	if ts.Style == OverlayAuto {
+		switch {
+		case dev > 0.2 || cr < 3:
+			// Busy or similar background, so cover it
+			ts.Style = OverlayScrim
+		case cr < 7:
+			ts.Style = OverlayOutline
+		default:
+			ts.Style = OverlayShadow
+		}
+	}
+
+	// The back colour is the opposite of the text
+	a := uint8(255)
+	if ts.Style == OverlayScrim || ts.Style == OverlayPanel {
+		a = uint8(math.Max(0, math.Min(255, float64(opacity)*255/100)))
+	}
+	if relLuminance(ts.Text) > 0.4 {
+		ts.Back = color.NRGBA{0, 0, 0, a}
+	} else {
+		ts.Back = color.NRGBA{255, 255, 255, a}
+	}
+	return ts
+}
+
+// drawStyledString draws the text with its top left corner at x,y using the style.
+// The font must already be loaded.
+func drawStyledString(dc *gg.Context, s string, x float64, y float64, ts textStyle) {
+	w, h := dc.MeasureString(s)
+	switch ts.Style {
+	case OverlayScrim:
+		dc.SetColor(ts.Back)
+		dc.DrawRectangle(x-4, y-2, w+8, h+8)
+		dc.Fill()
+	case OverlayPanel:
+		dc.SetColor(ts.Back)
+		dc.DrawRoundedRectangle(x-6, y-3, w+12, h+10, 5)
+		dc.Fill()
+	case OverlayOutline:
+		dc.SetColor(ts.Back)
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					dc.DrawString(s, x+float64(dx), y+h+float64(dy))
+				}
+			}
+		}
+	default:
+		dc.SetColor(ts.Back)
+		dc.DrawString(s, x+1, y+h+1)
+	}
+	dc.SetColor(ts.Text)
+	dc.DrawString(s, x, y+h)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+func TestCanChooseTextColour(t *testing.T) {
+	r := image.Rect(0, 0, 100, 30)
+
+	ts := chooseTextStyle(imaging.New(100, 100, color.White), r, OverlayAuto, nil, 50)
+	if ts.Text != overlayDark || ts.Style != OverlayShadow {
+		t.Error("Expected dark text with a shadow on snow", ts)
+	}
+	ts = chooseTextStyle(imaging.New(100, 100, color.Black), r, OverlayAuto, nil, 50)
+	if ts.Text != overlayLight || ts.Style != OverlayShadow {
+		t.Error("Expected light text with a shadow at night", ts)
+	}
+	ts = chooseTextStyle(imaging.New(100, 100, color.NRGBA{128, 128, 128, 255}), r, OverlayAuto, nil, 50)
+	if ts.Style != OverlayOutline {
+		t.Error("Expected an outline on a mid grey", ts)
+	}
+}
+
+func TestCanChooseScrim(t *testing.T) {
+	r := image.Rect(0, 0, 100, 30)
+
+	// A busy background is covered
+	busy := imaging.New(100, 100, color.White)
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if (x/2+y/2)%2 == 0 {
+				busy.Set(x, y, color.Black)
+			}
+		}
+	}
+	if ts := chooseTextStyle(busy, r, OverlayAuto, nil, 50); ts.Style != OverlayScrim {
+		t.Error("Expected a scrim on a busy background", ts)
+	}
+
+	// Yellow calendar text on a bright sky is covered with a dark scrim
+	ts := chooseTextStyle(imaging.New(100, 100, color.NRGBA{230, 240, 255, 255}), r, OverlayAuto, color.RGBA{255, 255, 0, 255}, 50)
+	if ts.Style != OverlayScrim || ts.Back != (color.NRGBA{0, 0, 0, 127}) {
+		t.Error("Expected a dark scrim behind yellow text", ts)
+	}
+
+	// The configured style is used as is
+	if ts := chooseTextStyle(busy, r, OverlayPanel, nil, 100); ts.Style != OverlayPanel || ts.Back.(color.NRGBA).A != 255 {
+		t.Error("Configured style not used", ts)
+	}
+}
+
+func TestCanDrawScrim(t *testing.T) {
+	dc := gg.NewContextForImage(imaging.New(200, 60, color.White))
+	ts := textStyle{Style: OverlayScrim, Text: overlayLight, Back: color.NRGBA{0, 0, 0, 255}}
+	drawStyledString(dc, "Snow", 20, 20, ts)
+	if r, _, _, _ := dc.Image().At(17, 20).RGBA(); r != 0 {
+		t.Error("Scrim not drawn behind the text")
+	}
+	if r, _, _, _ := dc.Image().At(5, 5).RGBA(); r == 0 {
+		t.Error("Scrim drawn outside the text")
+	}
+}
+
+func TestCanGetWidgetOverlayStyle(t *testing.T) {
+	c := Config{OverlayStyle: OverlayOutline, WidgetStyles: map[string]int{"credit": OverlayPanel}}
+	if c.GetOverlayStyle("credit") != OverlayPanel || c.GetOverlayStyle("temp") != OverlayOutline {
+		t.Error("Unexpected overlay styles", c.GetOverlayStyle("credit"), c.GetOverlayStyle("temp"))
+	}
+}
+
+func TestCanDrawInWidgetStyle(t *testing.T) {
+	c := Config{ScrimOpacity: 100, WidgetStyles: map[string]int{"credit": OverlayPanel}}
+	d := Display{Srv: &Server{Config: &c}}
+	grey := color.NRGBA{128, 128, 128, 255}
+	for _, w := range []string{"credit", "temp"} {
+		img := imaging.New(200, 60, grey)
+		dc := canvas{Context: gg.NewContextForImage(img), Base: img, Widget: w}
+		d.drawString(dc, "Snow", 14, 20, 20)
+		panel := dc.Image().At(15, 22) != color.Color(color.RGBA{128, 128, 128, 255})
+		if panel != (w == "credit") {
+			t.Error("Unexpected style drawn for", w, dc.Image().At(15, 22))
+		}
+	}
+}
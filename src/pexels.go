@@ -5,25 +5,44 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const pexelsStateFile = "pexelsstate.json"
+
 type pexelsData struct {
-	Page    int `json:"page"`
-	PerPage int `json:"per_page"`
-	Photos  []struct {
+	Page         int    `json:"page"`
+	PerPage      int    `json:"per_page"`
+	TotalResults int    `json:"total_results"`
+	NextPage     string `json:"next_page"`
+	Photos       []struct {
 		ID           int    `json:"id"`
 		Width        int    `json:"width"`
 		Height       int    `json:"height"`
 		URL          string `json:"url"`
 		Photographer string `json:"photographer"`
+		Src          struct {
+			Original string `json:"original"`
+		} `json:"src"`
 	} `json:"photos"`
 }
 
+// pexelsState holds the page to request next, so that each refresh shows different photos
+type pexelsState struct {
+	Query   string    `json:"query"`
+	Page    int       `json:"page"`
+	ResetAt time.Time `json:"resetAt"`
+}
+
 // Pexels is an image provider that selects images from Pexels.com
 type Pexels struct {
 	Config Config
+	apiURL string // Base URL of the Pexels API, defaults to https://api.pexels.com/v1
 }
 
 // SetConfig sets the configuration for this provider
@@ -36,28 +55,13 @@ func (p *Pexels) GetImages() ([]DisplayImage, error) {
 	p.LogInfo("Downloading images from Pexels.")
 
 	l := []DisplayImage{}
-	// Get the data from the Pexels API
-	url := fmt.Sprintf("https://api.pexels.com/v1/curated?per_page=%d&page=1", p.Config.ImgCount)
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	req.Header.Add("Authorization", "563492ad6f917000010000012b1ae72ef5bd4abb9ba8157e7b653f43")
-	resp, err := client.Do(req)
-	if resp != nil {
-		defer resp.Body.Close()
-		resp.Close = true
-	}
+	pd, err := p.getPhotos()
 	if err == nil {
-		j, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			pd := pexelsData{}
-			err = json.Unmarshal(j, &pd)
-			if err == nil {
-				l, err = p.downloadImages(&pd)
-			}
-		}
+		l, err = p.downloadImages(&pd)
 	}
 
 	if err != nil {
+		p.LogError("Error getting images. ", err.Error())
 		// Check to see if we already have the last response cached
 		fn := "lastpexels.json"
 		if _, err := os.Stat(fn); !os.IsNotExist(err) {
@@ -72,6 +76,101 @@ func (p *Pexels) GetImages() ([]DisplayImage, error) {
 	return l, err
 }
 
+// getPhotos gets the next page of curated photos, or search results if a query is configured
+func (p *Pexels) getPhotos() (pexelsData, error) {
+	pd := pexelsData{}
+	key := p.getKey()
+	if key == "" {
+		return pd, fmt.Errorf("The Pexels API key has not been configured")
+	}
+
+	st := pexelsState{}
+	if b, err := ioutil.ReadFile(pexelsStateFile); err == nil {
+		json.Unmarshal(b, &st)
+	}
+	if time.Now().Before(st.ResetAt) {
+		return pd, fmt.Errorf("The Pexels rate limit has been reached until %s", st.ResetAt.Format(time.RFC1123))
+	}
+	q := strings.TrimSpace(p.Config.PexelsQuery)
+	if st.Query != q || st.Page < 1 {
+		st = pexelsState{Query: q, Page: 1}
+	}
+
+	v := url.Values{}
+	v.Set("per_page", fmt.Sprintf("%d", p.Config.ImgCount))
+	v.Set("page", fmt.Sprintf("%d", st.Page))
+	path := "/curated"
+	if q != "" {
+		path = "/search"
+		v.Set("query", q)
+		v.Set("orientation", strings.Replace(p.Config.GetOrientation(), "squarish", "square", 1))
+		if p.Config.PexelsColor != "" {
+			v.Set("color", p.Config.PexelsColor)
+		}
+	}
+
+	b, err := p.callAPI(p.getAPIURL()+path+"?"+v.Encode(), key, &st)
+	if err == nil {
+		err = json.Unmarshal(b, &pd)
+	}
+	if err == nil {
+		// Rotate through the pages, going back to the start after the last one
+		st.Page++
+		if pd.NextPage == "" || len(pd.Photos) == 0 {
+			st.Page = 1
+		}
+	}
+	if b, err := json.Marshal(st); err == nil {
+		ioutil.WriteFile(pexelsStateFile, b, 0666)
+	}
+	if err == nil && len(pd.Photos) == 0 && pd.Page > 1 {
+		// Past the last page of results, so start again
+		return p.getPhotos()
+	}
+	return pd, err
+}
+
+// callAPI calls the Pexels API.  Short rate limit waits are retried by the HTTP client, and when the
+// limit still applies after that, no more calls are made until it resets.
+func (p *Pexels) callAPI(u string, key string, st *pexelsState) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", key)
+	res, err := GetHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Close = true
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		d := p.getRetryDelay(res)
+		st.ResetAt = time.Now().Add(d)
+		return nil, fmt.Errorf("The Pexels rate limit has been reached, retry in %s", d.Round(time.Second))
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Pexels API returned %s", res.Status)
+	}
+	if r := res.Header.Get("X-Ratelimit-Remaining"); r != "" {
+		p.LogInfo(r, " Pexels requests remaining this month")
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// getRetryDelay returns how long to wait before calling the API again once the rate limit has been reached
+func (p *Pexels) getRetryDelay(res *http.Response) time.Duration {
+	if d := retryAfter(res.Header.Get("Retry-After")); d > 0 {
+		return d
+	}
+	if s, err := strconv.ParseInt(res.Header.Get("X-Ratelimit-Reset"), 10, 64); err == nil {
+		if d := time.Until(time.Unix(s, 0)); d > 0 {
+			return d
+		}
+	}
+	return time.Minute
+}
+
 func (p *Pexels) downloadImages(pd *pexelsData) ([]DisplayImage, error) {
 	l := []DisplayImage{}
 	path := "./img/pexels"
@@ -86,31 +185,43 @@ func (p *Pexels) downloadImages(pd *pexelsData) ([]DisplayImage, error) {
 
 	xRes, yRes := p.Config.GetResolution()
 
+	r := GetRatings()
+	il := []DisplayImage{}
+	jl := []DisplayImage{}
+	dl := []DownloadJob{}
 	for _, i := range pd.Photos {
 		// Check if the file already exists
 		fn := fmt.Sprintf("%d.jpg", i.ID)
 		fp := filepath.Join(path, fn)
-		load := true
+		if r.IsBanned(fp) {
+			p.LogInfo("Skipping banned image '", fn, "'")
+			continue
+		}
+		di := DisplayImage{
+			Name:      fn,
+			Copyright: i.Photographer,
+			ImagePath: fp,
+		}
+		il = append(il, di)
 		if _, err := os.Stat(fp); os.IsNotExist(err) {
-			err = p.downloadImage(fp, fn, i.ID, xRes, yRes)
-			if err != nil {
-				p.LogError("Failed to download image '"+fn+"'.", err.Error())
-				load = false
-			}
+			// File does not exist, so download it
+			p.LogInfo("Downloading ", fn)
+			jl = append(jl, di)
+			dl = append(dl, DownloadJob{URL: p.getImageURL(i.ID, i.Src.Original, i.Width, i.Height, xRes, yRes), Path: fp, Width: xRes, Height: yRes})
 		}
-		if load {
-			l = append(l, DisplayImage{
-				Name:      fn,
-				Copyright: i.Photographer,
-				ImagePath: fp,
-			})
-		} else {
-			// There was an issue processing the image,
-			// remove the file from the disk if anything was written
-			if _, err := os.Stat(fp); err == nil {
-				p.LogInfo("Removing file ", fp)
-				os.Remove(fp)
-			}
+	}
+
+	// Download the missing images
+	failed := map[string]bool{}
+	for n, err := range NewDownloader(p.Config).Download(dl) {
+		if err != nil {
+			p.LogError("Failed to download image '"+jl[n].Name+"'.", err.Error())
+			failed[jl[n].Name] = true
+		}
+	}
+	for _, i := range il {
+		if !failed[i.Name] {
+			l = append(l, i)
 		}
 	}
 
@@ -118,8 +229,8 @@ func (p *Pexels) downloadImages(pd *pexelsData) ([]DisplayImage, error) {
 	fi, err := ioutil.ReadDir(path)
 	if err == nil {
 		for _, f := range fi {
-			// Check if this file is in the list
-			remove := true
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
 			for _, i := range l {
 				if i.Name == f.Name() {
 					remove = false
@@ -146,29 +257,29 @@ func (p *Pexels) downloadImages(pd *pexelsData) ([]DisplayImage, error) {
 	return l, nil
 }
 
-func (p *Pexels) downloadImage(fp string, fn string, id int, xRes int, yRes int) error {
-	// File does not exist, so download it
-	p.LogInfo("Downloading ", fn)
-	url := fmt.Sprintf("https://images.pexels.com/photos/%d/pexels-photo-%d.jpeg?auto=compress&cs=tinysrgb&fit=crop&h=%d&w=%d", id, id, yRes, xRes)
-	res, err := http.Get(url)
-	if res != nil {
-		defer res.Body.Close()
-		res.Close = true
-	}
-	if err != nil {
-		p.LogError("Error getting image file from url", url, ". ", err.Error())
-		return err
+// getImageURL returns the url of the photo, resized by Pexels to cover the display resolution.
+// The photo is not cropped, so that it can be fitted to the display using the fit mode.
+func (p *Pexels) getImageURL(id int, src string, w int, h int, xRes int, yRes int) string {
+	if src == "" {
+		src = fmt.Sprintf("https://images.pexels.com/photos/%d/pexels-photo-%d.jpeg", id, id)
 	}
-	fd, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		p.LogError("Error reading image file from response body.", url, ". ", err.Error())
-		return err
+	k, v := coverParam(w, h, xRes, yRes)
+	return fmt.Sprintf("%s?auto=compress&cs=tinysrgb&%s=%d", strings.Split(src, "?")[0], k, v)
+}
+
+// getKey returns the API key from the configuration or the PEXELS_API_KEY environment variable
+func (p *Pexels) getKey() string {
+	if p.Config.PexelsKey != "" {
+		return p.Config.PexelsKey
 	}
-	err = ioutil.WriteFile(fp, fd, 0666)
-	if err != nil {
-		p.LogError("Error writing image file", fp, ". ", err.Error())
+	return os.Getenv("PEXELS_API_KEY")
+}
+
+func (p *Pexels) getAPIURL() string {
+	if p.apiURL != "" {
+		return p.apiURL
 	}
-	return err
+	return "https://api.pexels.com/v1"
 }
 
 // LogInfo is used to log information messages for this controller.
@@ -1,18 +1,87 @@
 package main
 
 import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
+// newPexelsTestServer returns a stand-in for the Pexels API with 3 pages of photos
+func newPexelsTestServer(t *testing.T, pages *[]string, limited *int) *httptest.Server {
+	var srv *httptest.Server
+	api := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "testkey" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		if *limited > 0 {
+			*limited--
+			w.Header().Set("Retry-After", "2")
+			http.Error(w, "Too Many Requests", 429)
+			return
+		}
+		q := r.URL.Query()
+		pg, _ := strconv.Atoi(q.Get("page"))
+		pp, _ := strconv.Atoi(q.Get("per_page"))
+		*pages = append(*pages, strings.TrimPrefix(r.URL.Path, "/")+q.Get("page"))
+		w.Header().Set("X-Ratelimit-Remaining", "19999")
+		ph := []string{}
+		for n := 0; n < pp; n++ {
+			id := pg*100 + n
+			ph = append(ph, fmt.Sprintf(`{"id":%d,"width":6000,"height":3000,"photographer":"Photographer %d","src":{"original":"%s/photos/%d/pexels-photo-%d.jpeg"}}`, id, id, srv.URL, id, id))
+		}
+		next := ""
+		if pg < 3 {
+			next = fmt.Sprintf("%s%s?page=%d", srv.URL, r.URL.Path, pg+1)
+		}
+		fmt.Fprintf(w, `{"page":%d,"per_page":%d,"total_results":%d,"next_page":"%s","photos":[%s]}`, pg, pp, 3*pp, next, strings.Join(ph, ","))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/curated", api)
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("query") != "lighthouse" || q.Get("color") != "blue" || q.Get("orientation") != "landscape" {
+			t.Error("Unexpected search", r.URL.RawQuery)
+		}
+		api(w, r)
+	})
+	mux.HandleFunc("/photos/", func(w http.ResponseWriter, r *http.Request) {
+		// The photos are wider than the display, so only the height is set to cover it
+		if q := r.URL.Query(); q.Get("h") != "480" || q.Get("w") != "" || q.Get("fit") != "" {
+			t.Error("Image not requested to cover the display resolution", r.URL.RawQuery)
+		}
+		jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	})
+	srv = httptest.NewServer(mux)
+	return srv
+}
+
 func TestCanGetPexelsImages(t *testing.T) {
+	os.RemoveAll("./img/pexels")
+	os.Remove(pexelsStateFile)
+	defer os.Remove(pexelsStateFile)
+
+	pages := []string{}
+	limited := 0
+	srv := newPexelsTestServer(t, &pages, &limited)
+	defer srv.Close()
+
 	c := Config{
 		Provider:   2,
 		ImgCount:   8,
 		Resolution: 0,
+		PexelsKey:  "testkey",
 	}
 	c.SetDefaults()
 
-	i := Pexels{Config: c}
+	i := Pexels{Config: c, apiURL: srv.URL}
 	l, err := i.GetImages()
 	if err != nil {
 		t.Error(err)
@@ -20,4 +89,90 @@ func TestCanGetPexelsImages(t *testing.T) {
 	if len(l) != 8 {
 		t.Error("Only", len(l), "images returned, expected 8.")
 	}
+
+	// Each refresh should show the next page, going back to the start after the last
+	for n := 0; n < 3; n++ {
+		if _, err = i.GetImages(); err != nil {
+			t.Error(err)
+		}
+	}
+	if strings.Join(pages, ",") != "curated1,curated2,curated3,curated1" {
+		t.Error("Unexpected pages requested", pages)
+	}
+
+	// Changing to a search starts from the first page again
+	i.Config.PexelsQuery = "lighthouse"
+	i.Config.PexelsColor = "blue"
+	l, err = i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 8 || l[0].Name != "100.jpg" || pages[len(pages)-1] != "search1" {
+		t.Error("Unexpected search result", len(l), pages)
+	}
+}
+
+func TestCanGetPexelsKeyFromEnvironment(t *testing.T) {
+	t.Setenv("PEXELS_API_KEY", "envkey")
+	i := Pexels{}
+	if i.getKey() != "envkey" {
+		t.Error("Expected the key from the environment, got", i.getKey())
+	}
+	i.Config.PexelsKey = "configkey"
+	if i.getKey() != "configkey" {
+		t.Error("Expected the key from the config, got", i.getKey())
+	}
+}
+
+func TestPexelsBacksOffWhenRateLimited(t *testing.T) {
+	os.RemoveAll("./img/pexels")
+	os.Remove(pexelsStateFile)
+	defer os.Remove(pexelsStateFile)
+
+	pages := []string{}
+	limited := 2
+	srv := newPexelsTestServer(t, &pages, &limited)
+	defer srv.Close()
+
+	c := Config{
+		Provider:  2,
+		ImgCount:  2,
+		PexelsKey: "testkey",
+	}
+	c.SetDefaults()
+
+	waits := []time.Duration{}
+	h := NewHTTPClient(c)
+	h.wait = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+	SetHTTPClient(h)
+	defer SetHTTPClient(nil)
+
+	i := Pexels{Config: c, apiURL: srv.URL}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 2 || len(waits) != 2 || waits[0] != 2*time.Second {
+		t.Error("Expected to wait twice before getting images", waits, len(l))
+	}
+
+	// Give up when the rate limit won't reset soon, and don't call again until it has
+	limited = 10
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limited--
+		w.Header().Set("X-Ratelimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix()))
+		http.Error(w, "Too Many Requests", 429)
+	})
+	if _, err = i.GetImages(); err == nil {
+		t.Error("Expected a rate limit error")
+	}
+	if _, err = i.GetImages(); err == nil || !strings.Contains(err.Error(), "until") {
+		t.Error("Expected the rate limit to still apply, got", err)
+	}
+	// The HTTP client retries twice before giving up
+	if limited != 7 {
+		t.Error("API called", 10-limited, "times while rate limited, expected 3.")
+	}
 }
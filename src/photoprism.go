@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type photoprismPhoto struct {
+	UID        string    `json:"UID"`
+	Type       string    `json:"Type"`
+	Title      string    `json:"Title"`
+	TakenAt    time.Time `json:"TakenAt"`
+	PlaceLabel string    `json:"PlaceLabel"`
+	Hash       string    `json:"Hash"`
+	Files      []struct {
+		Hash    string `json:"Hash"`
+		Primary bool   `json:"Primary"`
+		Markers []struct {
+			Type    string `json:"Type"`
+			Name    string `json:"Name"`
+			Invalid bool   `json:"Invalid"`
+		} `json:"Markers"`
+	} `json:"Files"`
+}
+
+type photoprismConfig struct {
+	PreviewToken string `json:"previewToken"`
+}
+
+// photoprismThumbs lists the bounding box of the thumbnail sizes that PhotoPrism generates
+var photoprismThumbs = []struct {
+	Name   string
+	Width  int
+	Height int
+}{
+	{"fit_720", 720, 720},
+	{"fit_1280", 1280, 1024},
+	{"fit_1920", 1920, 1200},
+	{"fit_2048", 2048, 2048},
+	{"fit_2560", 2560, 1600},
+	{"fit_3840", 3840, 2400},
+	{"fit_4096", 4096, 4096},
+	{"fit_7680", 7680, 4320},
+}
+
+// PhotoPrism is an image provider that selects images from
+// a self-hosted PhotoPrism server
+type PhotoPrism struct {
+	Config Config
+}
+
+// SetConfig sets the configuration for this provider
+func (p *PhotoPrism) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *PhotoPrism) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Downloading images from PhotoPrism.")
+
+	l := []DisplayImage{}
+	pl, err := p.getPhotos()
+	if err == nil {
+		l, err = p.downloadImages(pl)
+	}
+
+	if err != nil {
+		p.LogError("Error getting images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastphotoprism.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// getPhotos gets the list of photos from the PhotoPrism API for the configured mode
+func (p *PhotoPrism) getPhotos() ([]photoprismPhoto, error) {
+	if p.Config.GalleryUrl == "" {
+		return nil, fmt.Errorf("The PhotoPrism url has not been configured")
+	}
+
+	v := url.Values{}
+	v.Set("count", fmt.Sprintf("%d", p.Config.ImgCount))
+	v.Set("offset", "0")
+	v.Set("merged", "true")
+	v.Set("primary", "true")
+	v.Set("order", "random")
+	q := strings.TrimSpace(p.Config.GalleryQuery)
+	switch p.Config.GalleryMode {
+	case 0: // Random from the library
+	case 1: // Album
+		if q == "" {
+			return nil, fmt.Errorf("No PhotoPrism album has been configured")
+		}
+		v.Set("album", q)
+	case 2: // Person
+		if q == "" {
+			return nil, fmt.Errorf("No PhotoPrism person has been configured")
+		}
+		v.Set("subject", q)
+	default:
+		return nil, fmt.Errorf("Gallery mode '%d' is invalid", p.Config.GalleryMode)
+	}
+
+	b, err := p.callAPI("/api/v1/photos?" + v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	pl := []photoprismPhoto{}
+	err = json.Unmarshal(b, &pl)
+	return pl, err
+}
+
+// getPreviewToken gets the token that is needed to download thumbnails
+func (p *PhotoPrism) getPreviewToken() (string, error) {
+	b, err := p.callAPI("/api/v1/config")
+	if err != nil {
+		return "", err
+	}
+	pc := photoprismConfig{}
+	err = json.Unmarshal(b, &pc)
+	if err == nil && pc.PreviewToken == "" {
+		err = fmt.Errorf("PhotoPrism did not return a preview token")
+	}
+	return pc.PreviewToken, err
+}
+
+func (p *PhotoPrism) downloadImages(pl []photoprismPhoto) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/photoprism"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	xRes, yRes := p.Config.GetResolution()
+	ts := p.getThumbSize(xRes, yRes)
+	tk := ""
+
+	r := GetRatings()
+	il := []DisplayImage{}
+	dl := []DownloadJob{}
+	for _, i := range pl {
+		if i.Type != "" && i.Type != "image" {
+			continue
+		}
+		h := p.getHash(i)
+		if h == "" {
+			continue
+		}
+		fn := i.UID + ".jpg"
+		fp := filepath.Join(path, fn)
+		if r.IsBanned(fp) {
+			p.LogInfo("Skipping banned image '", fn, "'")
+			continue
+		}
+		if _, err := os.Stat(fp); os.IsNotExist(err) {
+			if tk == "" {
+				tk, err = p.getPreviewToken()
+				if err != nil {
+					return l, err
+				}
+			}
+			p.LogInfo("Downloading ", i.Title)
+			dl = append(dl, p.getDownloadJob(fp, fmt.Sprintf("/api/v1/t/%s/%s/%s", h, tk, ts), xRes, yRes))
+		}
+		il = append(il, DisplayImage{
+			Name:      fn,
+			Caption:   p.getCaption(i),
+			ImagePath: fp,
+		})
+	}
+
+	// Download the missing images
+	failed := map[string]bool{}
+	for n, err := range NewDownloader(p.Config).Download(dl) {
+		if err != nil {
+			p.LogError("Error getting thumbnail ", dl[n].URL, ". ", err.Error())
+			failed[dl[n].Path] = true
+		}
+	}
+	for _, i := range il {
+		if !failed[i.ImagePath] {
+			l = append(l, i)
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			}
+		}
+	}
+	if err == nil {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastphotoprism.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// getDownloadJob returns the job that downloads the thumbnail at the path, resized to the frame
+func (p *PhotoPrism) getDownloadJob(fp string, path string, xRes int, yRes int) DownloadJob {
+	j := DownloadJob{
+		URL:    strings.TrimSuffix(p.Config.GalleryUrl, "/") + path,
+		Path:   fp,
+		Width:  xRes,
+		Height: yRes,
+		Header: http.Header{},
+	}
+	if p.Config.GalleryKey != "" {
+		j.Header.Set("Authorization", "Bearer "+p.Config.GalleryKey)
+	}
+	return j
+}
+
+// callAPI calls the PhotoPrism API and returns the body of the response
+func (p *PhotoPrism) callAPI(path string) ([]byte, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.Config.GalleryUrl, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.Config.GalleryKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Config.GalleryKey)
+	}
+	return GetHTTPClient().Fetch(req)
+}
+
+// getThumbSize returns the smallest thumbnail size that covers the frame
+func (p *PhotoPrism) getThumbSize(xRes int, yRes int) string {
+	for _, t := range photoprismThumbs {
+		if t.Width >= xRes && t.Height >= yRes {
+			return t.Name
+		}
+	}
+	return photoprismThumbs[len(photoprismThumbs)-1].Name
+}
+
+// getHash returns the hash of the primary file of the photo
+func (p *PhotoPrism) getHash(i photoprismPhoto) string {
+	for _, f := range i.Files {
+		if f.Primary && f.Hash != "" {
+			return f.Hash
+		}
+	}
+	return i.Hash
+}
+
+// getCaption returns the caption for the photo from the people, place and date it was taken
+func (p *PhotoPrism) getCaption(i photoprismPhoto) string {
+	pl := []string{}
+	for _, f := range i.Files {
+		for _, m := range f.Markers {
+			if m.Type == "face" && m.Name != "" && !m.Invalid {
+				pl = append(pl, m.Name)
+			}
+		}
+	}
+	place := []string{}
+	if i.PlaceLabel != "Unknown" {
+		place = strings.Split(i.PlaceLabel, ",")
+	}
+	return getGalleryCaption(pl, place, i.TakenAt, p.Config.GetLocale())
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *PhotoPrism) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("PhotoPrism: [Inf] ", a)
+	} else {
+		fmt.Println("PhotoPrism: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *PhotoPrism) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("PhotoPrism: [Err] ", a)
+	} else {
+		fmt.Println("PhotoPrism: [Err] ", a)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func newPhotoPrismTestServer(t *testing.T, thumbs map[string]bool) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/photos", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("count") != "5" || q.Get("order") != "random" || q.Get("merged") != "true" {
+			t.Error("Unexpected photo search", r.URL.RawQuery)
+		}
+		if q.Get("album") != "" && q.Get("album") != "ar2xu7myk7wrbk2q" {
+			t.Error("Unexpected album", q.Get("album"))
+		}
+		b, _ := ioutil.ReadFile("./testdata/photoprism_photos.json")
+		w.Write(b)
+	})
+	mux.HandleFunc("/api/v1/config", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadFile("./testdata/photoprism_config.json")
+		w.Write(b)
+	})
+	mux.HandleFunc("/api/v1/t/", func(w http.ResponseWriter, r *http.Request) {
+		thumbs[r.URL.Path] = true
+		jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 1280, 960)), nil)
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer testpass" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func TestCanGetPhotoPrismImages(t *testing.T) {
+	os.RemoveAll("./img/photoprism")
+
+	thumbs := map[string]bool{}
+	srv := newPhotoPrismTestServer(t, thumbs)
+	defer srv.Close()
+
+	c := Config{
+		Provider:     12,
+		ImgCount:     5,
+		GalleryUrl:   srv.URL,
+		GalleryKey:   "testpass",
+		GalleryMode:  1,
+		GalleryQuery: "ar2xu7myk7wrbk2q",
+	}
+	c.SetDefaults()
+
+	i := PhotoPrism{Config: c}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 2 {
+		t.Fatal(len(l), "images returned, expected 2.")
+	}
+	if l[0].Name != "pr2xu7myk7wrbk2q.jpg" || l[0].Caption != "Jane Doe, John Doe - Hamburg, Germany - 19 June 2021" {
+		t.Error("Unexpected image", l[0])
+	}
+	if l[1].Caption != "31 December 2020" {
+		t.Error("Unexpected caption", l[1].Caption)
+	}
+	if !thumbs["/api/v1/t/2cad9168fa6acc5c5c2965ddf6ec465ca42fd818/2q5a8yql/fit_1280"] {
+		t.Error("Thumbnail not requested at frame size", thumbs)
+	}
+}
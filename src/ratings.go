@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const ratingsFile = "ratings.json"
+
+// ratingsLock serializes updates to the ratings file
+var ratingsLock sync.Mutex
+
+// ImageRating holds the favourite, ban and rating details the user has set for an image
+type ImageRating struct {
+	ImagePath string    `json:"imagePath"`         // Path to the provider image
+	Name      string    `json:"name"`              // Name of the image
+	Copyright string    `json:"copyright"`         // Copyright of the image
+	Favourite bool      `json:"favourite"`         // Image is shown more often
+	Banned    bool      `json:"banned"`            // Image is never shown again
+	Rating    int       `json:"rating"`            // Star rating, 0=not rated, 1-5
+	Updated   time.Time `json:"updated"`           // Last time the rating was changed
+	Missing   bool      `json:"missing,omitempty"` // Image file has been removed, only set for the gallery
+}
+
+// Ratings holds the image ratings keyed by image path
+type Ratings map[string]ImageRating
+
+// GetRatings returns the image ratings saved to disk.
+// An empty list is returned if the ratings cannot be read.
+func GetRatings() Ratings {
+	ratingsLock.Lock()
+	defer ratingsLock.Unlock()
+
+	r := Ratings{}
+	r.ReadFromFile(ratingsFile)
+	return r
+}
+
+// UpdateRating reads the ratings from disk, applies the update to the rating
+// for the specified image and writes the ratings back to disk.
+func UpdateRating(i DisplayImage, update func(r *ImageRating)) (ImageRating, error) {
+	ratingsLock.Lock()
+	defer ratingsLock.Unlock()
+
+	r := Ratings{}
+	r.ReadFromFile(ratingsFile)
+
+	k := ratingKey(i.ImagePath)
+	ir, ok := r[k]
+	if !ok {
+		ir = ImageRating{ImagePath: k}
+	}
+	if i.Name != "" {
+		ir.Name = i.Name
+	}
+	if i.Copyright != "" {
+		ir.Copyright = i.Copyright
+	}
+	update(&ir)
+	ir.Updated = time.Now()
+
+	if !ir.Favourite && !ir.Banned && ir.Rating == 0 {
+		delete(r, k)
+	} else {
+		r[k] = ir
+	}
+	return ir, r.WriteToFile(ratingsFile)
+}
+
+// ReadFromFile will read the ratings from the specified file
+func (r Ratings) ReadFromFile(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &r)
+}
+
+// WriteToFile will write the ratings to the specified file
+func (r Ratings) WriteToFile(path string) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0666)
+}
+
+// Get returns the rating for the image at the specified path
+func (r Ratings) Get(path string) ImageRating {
+	k := ratingKey(path)
+	if ir, ok := r[k]; ok {
+		return ir
+	}
+	return ImageRating{ImagePath: k}
+}
+
+// IsBanned returns true if the image at the specified path has been banned
+func (r Ratings) IsBanned(path string) bool {
+	return r[ratingKey(path)].Banned
+}
+
+// IsFavourite returns true if the image at the specified path is a favourite
+func (r Ratings) IsFavourite(path string) bool {
+	ir := r[ratingKey(path)]
+	return ir.Favourite && !ir.Banned
+}
+
+// Apply removes the banned images from the list and adds the favourites.
+// Favourites that are no longer returned by the provider are added back if they
+// are still in one of the provider folders, and every favourite is repeated
+// weight times so that it is shown more often.
+func (r Ratings) Apply(l []DisplayImage, weight int) []DisplayImage {
+	if weight < 1 {
+		weight = 1
+	}
+	rl := []DisplayImage{}
+	dirs := map[string]bool{}
+	found := map[string]bool{}
+	for _, i := range l {
+		k := ratingKey(i.ImagePath)
+		dirs[filepath.Dir(k)] = true
+		if r.IsBanned(k) {
+			continue
+		}
+		found[k] = true
+		rl = append(rl, i)
+		if r.IsFavourite(k) {
+			for n := 1; n < weight; n++ {
+				rl = append(rl, i)
+			}
+		}
+	}
+
+	keys := []string{}
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ir := r[k]
+		if found[k] || !ir.Favourite || ir.Banned || !dirs[filepath.Dir(k)] {
+			continue
+		}
+		if _, err := os.Stat(k); err != nil {
+			continue
+		}
+		for n := 0; n < weight; n++ {
+			rl = append(rl, DisplayImage{
+				Name:      ir.Name,
+				Copyright: ir.Copyright,
+				ImagePath: k,
+			})
+		}
+	}
+	return rl
+}
+
+// ratingKey returns the key used to store the rating for an image path
+func ratingKey(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanApplyRatings(t *testing.T) {
+	path, err := ioutil.TempDir("", "ratings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	// A favourite that the provider no longer returns but is still on disk
+	old := filepath.Join(path, "old.jpg")
+	ioutil.WriteFile(old, []byte{}, 0666)
+
+	l := []DisplayImage{
+		{Name: "a.jpg", ImagePath: filepath.Join(path, "a.jpg")},
+		{Name: "b.jpg", ImagePath: filepath.Join(path, "b.jpg")},
+		{Name: "c.jpg", ImagePath: filepath.Join(path, "c.jpg")},
+	}
+	r := Ratings{
+		ratingKey(l[0].ImagePath): {ImagePath: ratingKey(l[0].ImagePath), Favourite: true},
+		ratingKey(l[1].ImagePath): {ImagePath: ratingKey(l[1].ImagePath), Banned: true},
+		ratingKey(old):            {ImagePath: ratingKey(old), Name: "old.jpg", Favourite: true},
+		ratingKey("gone/x.jpg"):   {ImagePath: "gone/x.jpg", Favourite: true},
+	}
+
+	rl := r.Apply(l, 2)
+	n := map[string]int{}
+	for _, i := range rl {
+		n[filepath.Base(i.ImagePath)]++
+	}
+	if n["a.jpg"] != 2 {
+		t.Error("Favourite shown", n["a.jpg"], "times, expected 2.")
+	}
+	if n["b.jpg"] != 0 {
+		t.Error("Banned image was not removed.")
+	}
+	if n["c.jpg"] != 1 {
+		t.Error("Image shown", n["c.jpg"], "times, expected 1.")
+	}
+	if n["old.jpg"] != 2 {
+		t.Error("Cached favourite shown", n["old.jpg"], "times, expected 2.")
+	}
+	if n["x.jpg"] != 0 {
+		t.Error("Favourite from another folder was added.")
+	}
+}
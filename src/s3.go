@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const s3StateFile = "s3state.json"
+
+// s3EmptyHash is the SHA256 hash of an empty request body
+const s3EmptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// s3Object holds the details about an object in the bucket
+type s3Object struct {
+	Key      string    `json:"key"`
+	ETag     string    `json:"etag"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// S3 is an image provider that mirrors the images in an S3 compatible
+// bucket (AWS, MinIO, Backblaze) to a local cache folder.
+// The prefix is used to select the album.
+type S3 struct {
+	Config Config
+}
+
+// SetConfig sets the configuration for this provider
+func (p *S3) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *S3) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Synchronizing images from S3 bucket.")
+
+	l := []DisplayImage{}
+	ol, err := p.listObjects()
+	if err == nil {
+		l, err = p.downloadImages(ol)
+	}
+
+	if err != nil {
+		p.LogError("Error getting Images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lasts3.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// listObjects returns the images under the configured prefix, newest first
+func (p *S3) listObjects() ([]s3Object, error) {
+	if p.Config.S3Bucket == "" {
+		return nil, fmt.Errorf("The S3 bucket has not been configured")
+	}
+
+	ol := []s3Object{}
+	token := ""
+	for {
+		v := url.Values{}
+		v.Set("list-type", "2")
+		if p.Config.S3Prefix != "" {
+			v.Set("prefix", p.Config.S3Prefix)
+		}
+		if token != "" {
+			v.Set("continuation-token", token)
+		}
+		b, err := p.callAPI("", v)
+		if err != nil {
+			return nil, err
+		}
+		lr := s3ListResult{}
+		if err := xml.Unmarshal(b, &lr); err != nil {
+			return nil, err
+		}
+		for _, c := range lr.Contents {
+			if !p.isImage(c.Key) {
+				continue
+			}
+			ol = append(ol, s3Object{
+				Key:      c.Key,
+				ETag:     strings.Trim(c.ETag, `"`),
+				Size:     c.Size,
+				Modified: c.LastModified,
+			})
+		}
+		if !lr.IsTruncated || lr.NextContinuationToken == "" {
+			break
+		}
+		token = lr.NextContinuationToken
+	}
+
+	sort.SliceStable(ol, func(i, j int) bool {
+		return ol[i].Modified.After(ol[j].Modified)
+	})
+	return ol, nil
+}
+
+func (p *S3) downloadImages(ol []s3Object) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/s3"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	// Get the ETags of the files already in the cache folder
+	state := map[string]s3Object{}
+	if b, err := ioutil.ReadFile(s3StateFile); err == nil {
+		json.Unmarshal(b, &state)
+	}
+	newState := map[string]s3Object{}
+
+	r := GetRatings()
+	d := NewDownloader(p.Config)
+	for len(ol) != 0 && len(l) < p.Config.ImgCount {
+		// Download only as many images as are still needed, then try the next ones if any failed
+		il := []DisplayImage{}
+		dl := []DownloadJob{}
+		for len(ol) != 0 && len(l)+len(il) < p.Config.ImgCount {
+			o := ol[0]
+			ol = ol[1:]
+			fn := p.getImageName(o.Key)
+			fp := filepath.Join(path, fn)
+			if r.IsBanned(fp) {
+				p.LogInfo("Skipping banned image '", fn, "'")
+				continue
+			}
+			_, err := os.Stat(fp)
+			if os.IsNotExist(err) || state[fn].ETag != o.ETag {
+				u, err := p.getObjectURL(o.Key)
+				if err != nil {
+					p.LogError("Error getting object ", o.Key, ". ", err.Error())
+					continue
+				}
+				p.LogInfo("Downloading ", o.Key)
+				dl = append(dl, DownloadJob{URL: u.String(), Path: fp, Sign: p.sign})
+			}
+			newState[fn] = o
+			il = append(il, DisplayImage{
+				Name:      fn,
+				ImagePath: fp,
+				Album:     p.getAlbum(o.Key),
+			})
+		}
+		failed := map[string]bool{}
+		for n, err := range d.Download(dl) {
+			if err != nil {
+				p.LogError("Error getting object ", dl[n].URL, ". ", err.Error())
+				failed[dl[n].Path] = true
+			}
+		}
+		for _, i := range il {
+			if failed[i.ImagePath] {
+				// Only keep the ETag of an object that has been downloaded and verified
+				delete(newState, i.Name)
+				continue
+			}
+			l = append(l, i)
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			} else if _, ok := newState[f.Name()]; !ok {
+				newState[f.Name()] = state[f.Name()]
+			}
+		}
+	}
+	if err == nil {
+		if b, err := json.Marshal(newState); err == nil {
+			ioutil.WriteFile(s3StateFile, b, 0666)
+		}
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lasts3.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// callAPI sends a signed GET request for the key (or the bucket if the key is empty)
+// and returns the response body
+func (p *S3) callAPI(key string, v url.Values) ([]byte, error) {
+	u, err := p.getObjectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		u.RawQuery = strings.Replace(v.Encode(), "+", "%20", -1)
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	p.sign(req)
+	return GetHTTPClient().Fetch(req)
+}
+
+// sign signs the request with the access key, if one has been configured
+func (p *S3) sign(req *http.Request) {
+	if p.Config.S3AccessKey != "" {
+		signS3Request(req, p.Config.S3AccessKey, p.Config.S3SecretKey, p.Config.S3Region, time.Now())
+	}
+}
+
+// getObjectURL returns the url for the key using path style or virtual hosted style addressing
+func (p *S3) getObjectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(p.Config.S3Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if p.Config.S3PathStyle {
+		u.Path = u.Path + "/" + p.Config.S3Bucket + "/" + key
+	} else {
+		u.Host = p.Config.S3Bucket + "." + u.Host
+		u.Path = u.Path + "/" + key
+	}
+	// Send the path encoded the same way it is signed
+	u.RawPath = s3Escape(u.Path, false)
+	return u, nil
+}
+
+// getImageName returns the local file name for the object key
+func (p *S3) getImageName(key string) string {
+	return mirrorName(strings.TrimPrefix(key, p.Config.S3Prefix))
+}
+
+// getAlbum returns the folder of the object key under the prefix
+func (p *S3) getAlbum(key string) string {
+	k := strings.Trim(strings.TrimPrefix(key, p.Config.S3Prefix), "/")
+	if d := path.Dir(k); d != "." {
+		return d
+	}
+	return ""
+}
+
+func (p *S3) isImage(key string) bool {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// signS3Request signs the request using AWS Signature Version 4.
+// The host, range and x-amz-* headers are signed and the payload is assumed to be empty.
+func signS3Request(req *http.Request, accessKey string, secretKey string, region string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", s3EmptyHash)
+
+	// Canonical headers
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "range" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := []string{}
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	ch := ""
+	for _, k := range names {
+		ch = ch + k + ":" + headers[k] + "\n"
+	}
+	signed := strings.Join(names, ";")
+
+	// Canonical query string
+	q := req.URL.Query()
+	keys := []string{}
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	qp := []string{}
+	for _, k := range keys {
+		vl := q[k]
+		sort.Strings(vl)
+		for _, v := range vl {
+			qp = append(qp, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+
+	uri := req.URL.EscapedPath()
+	if p, err := url.PathUnescape(uri); err == nil {
+		uri = s3Escape(p, false)
+	}
+	if uri == "" {
+		uri = "/"
+	}
+
+	cr := strings.Join([]string{req.Method, uri, strings.Join(qp, "&"), ch, signed, s3EmptyHash}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	h := sha256.Sum256([]byte(cr))
+	sts := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(h[:])
+
+	key := s3HMAC([]byte("AWS4"+secretKey), day)
+	key = s3HMAC(key, region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	sig := hex.EncodeToString(s3HMAC(key, sts))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s", accessKey, scope, signed, sig))
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape URI encodes the string as required by Signature Version 4.
+// The slash is only encoded in query string values.
+func s3Escape(s string, encodeSlash bool) string {
+	b := strings.Builder{}
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *S3) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("S3: [Inf] ", a)
+	} else {
+		fmt.Println("S3: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *S3) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("S3: [Err] ", a)
+	} else {
+		fmt.Println("S3: [Err] ", a)
+	}
+}
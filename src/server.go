@@ -85,6 +85,7 @@ func (s *Server) run() {
 	}
 	s.Config.ReadFromFile("config.json")
 	s.Config.SetDefaults()
+	SetHTTPClient(NewHTTPClient(*s.Config))
 
 	// Create a router
 	s.router = mux.NewRouter().StrictSlash(true)
@@ -94,6 +95,7 @@ func (s *Server) run() {
 	s.addController(new(LogController))
 	s.addController(new(ConfigController))
 	s.addController(new(DisplayController))
+	s.addController(new(ImageController))
 
 	// Create an HTTP server
 	s.http = &http.Server{
@@ -148,6 +150,9 @@ func (s *Server) startSchedule() {
 	}
 	s.cw = clockwerk.New()
 	s.cw.Every(30 * time.Minute).Do(&s.Display)
+	if s.Config.AlertCheckMins > 0 {
+		s.cw.Every(time.Duration(s.Config.AlertCheckMins) * time.Minute).Do(&AlertWatcher{Srv: s})
+	}
 	s.cw.Start()
 
 	s.logDebug("Schedule set.")
@@ -0,0 +1,3 @@
+#!/bin/sh
+echo "unable to reach the photo service" >&2
+exit 3
@@ -0,0 +1,12 @@
+#!/bin/sh
+# Lists a local image and a downloaded image, along with some invalid entries.
+# $1 is the url of the test server and $2 the folder holding the local image.
+echo "listing images for ${PHOTOFRAME_WIDTH}x${PHOTOFRAME_HEIGHT}" >&2
+echo '{"name":"local","copyright":"Local image","path":"'"$2"'/local.png"}'
+echo ''
+echo 'this is not json'
+echo '{"name":"missing","path":"'"$2"'/missing.jpg"}'
+echo '{"name":"notimage","path":"'"$2"'/notes.txt"}'
+echo '{"name":"folder","path":"'"$2"'"}'
+echo '{"name":"secret","url":"file:///etc/passwd"}'
+echo '{"name":"../../remote","copyright":"Remote image","url":"'"$1"'/photos/remote"}'
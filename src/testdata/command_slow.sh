@@ -0,0 +1,4 @@
+#!/bin/sh
+# Takes longer than the configured timeout
+echo '{"name":"late","url":"http://localhost/late.jpg"}'
+sleep 10
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Units of the weather readings
+const (
+	TempCelsius    = "c"
+	TempFahrenheit = "f"
+	WindKmh        = "kmh"
+	WindMs         = "ms"
+	WindMph        = "mph"
+	WindBeaufort   = "bft"
+	PressureHpa    = "hpa"
+	PressureInHg   = "inhg"
+	PressureMmHg   = "mmhg"
+)
+
+// Conversion factors between the units
+const (
+	hpaPerInHg = 33.8639
+	hpaPerMmHg = 1.33322
+	kmhPerMs   = 3.6
+	mphPerMs   = 2.23694
+)
+
+// compassInterval is the number of degrees between the points of the compass
+const compassInterval = 22.5
+
+// beaufortLimits holds the upper wind speed in m/s of each force on the Beaufort scale
+var beaufortLimits = []float64{0.5, 1.5, 3.3, 5.5, 7.9, 10.7, 13.8, 17.1, 20.7, 24.4, 28.4, 32.6}
+
+// unitLabels holds the label drawn next to a value in the unit
+var unitLabels = map[string]string{
+	TempCelsius:    "°C",
+	TempFahrenheit: "°F",
+	WindKmh:        "km/h",
+	WindMs:         "m/s",
+	WindMph:        "mph",
+	WindBeaufort:   "Bft",
+	PressureHpa:    "hPa",
+	PressureInHg:   "inHg",
+	PressureMmHg:   "mmHg",
+}
+
+// Units holds the units of the temperature, wind speed and air pressure
+type Units struct {
+	Temp     string
+	Wind     string
+	Pressure string
+}
+
+// newUnits returns the units, using the metric unit for any that are not recognised
+func newUnits(temp string, wind string, pressure string) Units {
+	u := Units{
+		Temp:     strings.ToLower(strings.TrimSpace(temp)),
+		Wind:     strings.ToLower(strings.TrimSpace(wind)),
+		Pressure: strings.ToLower(strings.TrimSpace(pressure)),
+	}
+	if u.Temp != TempFahrenheit {
+		u.Temp = TempCelsius
+	}
+	if u.Wind != WindMs && u.Wind != WindMph && u.Wind != WindBeaufort {
+		u.Wind = WindKmh
+	}
+	if u.Pressure != PressureInHg && u.Pressure != PressureMmHg {
+		u.Pressure = PressureHpa
+	}
+	return u
+}
+
+// isUnit returns whether the unit is one of the units given
+func isUnit(u string, l ...string) bool {
+	for _, v := range l {
+		if u == v {
+			return true
+		}
+	}
+	return false
+}
+
+// unitLabel returns the label drawn next to a value in the unit
+func unitLabel(u string) string {
+	return unitLabels[u]
+}
+
+// convertTemp converts the temperature between Celsius and Fahrenheit
+func convertTemp(v float64, from string, to string) float64 {
+	if from == to {
+		return v
+	}
+	if to == TempFahrenheit {
+		return v*9/5 + 32
+	}
+	return (v - 32) * 5 / 9
+}
+
+// convertWind converts the wind speed between the units
+func convertWind(v float64, from string, to string) float64 {
+	if from == to {
+		return v
+	}
+	// Convert to m/s first
+	ms := v
+	switch from {
+	case WindKmh:
+		ms = v / kmhPerMs
+	case WindMph:
+		ms = v / mphPerMs
+	case WindBeaufort:
+		ms = 0.836 * math.Pow(v, 1.5)
+	}
+	switch to {
+	case WindKmh:
+		return ms * kmhPerMs
+	case WindMph:
+		return ms * mphPerMs
+	case WindBeaufort:
+		for n, l := range beaufortLimits {
+			if ms < l {
+				return float64(n)
+			}
+		}
+		return float64(len(beaufortLimits))
+	}
+	return ms
+}
+
+// convertPressure converts the air pressure between the units
+func convertPressure(v float64, from string, to string) float64 {
+	if from == to {
+		return v
+	}
+	// Convert to hPa first
+	hpa := v
+	switch from {
+	case PressureInHg:
+		hpa = v * hpaPerInHg
+	case PressureMmHg:
+		hpa = v * hpaPerMmHg
+	}
+	switch to {
+	case PressureInHg:
+		return hpa / hpaPerInHg
+	case PressureMmHg:
+		return hpa / hpaPerMmHg
+	}
+	return hpa
+}
+
+// FormatTemp returns the temperature from the weather service in these units, to the number of decimals
+func (u Units) FormatTemp(v float32, from Units, decimals int) string {
+	return fmt.Sprintf("%.*f", decimals, convertTemp(float64(v), from.Temp, u.Temp))
+}
+
+// FormatWind returns the wind speed from the weather service in these units
+func (u Units) FormatWind(v float32, from Units) string {
+	s := convertWind(float64(v), from.Wind, u.Wind)
+	if u.Wind == WindMs {
+		return fmt.Sprintf("%.1f", s)
+	}
+	return fmt.Sprintf("%.0f", s)
+}
+
+// FormatPressure returns the air pressure from the weather service in these units
+func (u Units) FormatPressure(v float32, from Units) string {
+	p := convertPressure(float64(v), from.Pressure, u.Pressure)
+	if u.Pressure == PressureInHg {
+		return fmt.Sprintf("%.2f", p)
+	}
+	return fmt.Sprintf("%.0f", p)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCanConvertTemperature(t *testing.T) {
+	if v := convertTemp(100, TempCelsius, TempFahrenheit); v != 212 {
+		t.Error("Expected 212F, got", v)
+	}
+	if v := convertTemp(-40, TempFahrenheit, TempCelsius); v != -40 {
+		t.Error("Expected -40C, got", v)
+	}
+	if v := convertTemp(21.5, TempCelsius, TempCelsius); v != 21.5 {
+		t.Error("Expected the same value, got", v)
+	}
+}
+
+func TestCanConvertWindSpeed(t *testing.T) {
+	tests := []struct {
+		V        float64
+		From, To string
+		Want     float64
+	}{
+		{10, WindMs, WindKmh, 36},
+		{36, WindKmh, WindMs, 10},
+		{10, WindMs, WindMph, 22.3694},
+		{36, WindKmh, WindMph, 22.3694},
+		{0.2, WindMs, WindBeaufort, 0},
+		{36, WindKmh, WindBeaufort, 5},
+		{118, WindKmh, WindBeaufort, 12},
+	}
+	for _, tc := range tests {
+		if v := convertWind(tc.V, tc.From, tc.To); math.Abs(v-tc.Want) > 0.001 {
+			t.Error(tc.V, tc.From, "to", tc.To, "expected", tc.Want, "got", v)
+		}
+	}
+}
+
+func TestCanConvertPressure(t *testing.T) {
+	if v := convertPressure(1013.25, PressureHpa, PressureInHg); math.Abs(v-29.92) > 0.01 {
+		t.Error("Expected 29.92inHg, got", v)
+	}
+	if v := convertPressure(1013.25, PressureHpa, PressureMmHg); math.Abs(v-760) > 0.1 {
+		t.Error("Expected 760mmHg, got", v)
+	}
+	if v := convertPressure(29.92, PressureInHg, PressureMmHg); math.Abs(v-760) > 0.1 {
+		t.Error("Expected 760mmHg, got", v)
+	}
+}
+
+func TestCanFormatWeatherValues(t *testing.T) {
+	c := Config{TempUnit: "F", WindUnit: "mph", PressureUnit: "inhg", WeatherWindUnit: "ms"}
+	u := c.GetUnits()
+	wu := c.GetWeatherUnits()
+	if s := u.FormatTemp(20, wu, 1); s != "68.0" {
+		t.Error("Unexpected temperature", s)
+	}
+	if s := u.FormatWind(10, wu); s != "22" {
+		t.Error("Unexpected wind speed", s)
+	}
+	if s := u.FormatPressure(1013.25, wu); s != "29.92" {
+		t.Error("Unexpected pressure", s)
+	}
+	if unitLabel(u.Temp) != "°F" || unitLabel(u.Wind) != "mph" || unitLabel(u.Pressure) != "inHg" {
+		t.Error("Unexpected unit labels")
+	}
+
+	// Unknown units fall back to metric
+	u = newUnits("K", "knots", "")
+	if u.Temp != TempCelsius || u.Wind != WindKmh || u.Pressure != PressureHpa {
+		t.Error("Unexpected units", u)
+	}
+}
+
+func TestCanGetCompassPoint(t *testing.T) {
+	en := GetLocale("en")
+	tests := map[float32]string{0: "N", 11: "N", 12: "NNE", 45: "NE", 180: "S", 350: "N", 360: "N", 292.5: "WNW", -90: "W"}
+	for deg, want := range tests {
+		if s := en.CompassPoint(deg); s != want {
+			t.Error(deg, "expected", want, "got", s)
+		}
+	}
+	if s := GetLocale("de").CompassPoint(90); s != "O" {
+		t.Error("Expected O for east in German, got", s)
+	}
+	if s := GetLocale("fr").CompassPoint(270); s != "O" {
+		t.Error("Expected O for west in French, got", s)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type unsplashPhoto struct {
+	ID             string `json:"id"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	Description    string `json:"description"`
+	AltDescription string `json:"alt_description"`
+	URLs           struct {
+		Raw     string `json:"raw"`
+		Regular string `json:"regular"`
+	} `json:"urls"`
+	Links struct {
+		HTML             string `json:"html"`
+		DownloadLocation string `json:"download_location"`
+	} `json:"links"`
+	User struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+type unsplashSearch struct {
+	Total   int             `json:"total"`
+	Results []unsplashPhoto `json:"results"`
+}
+
+// Unsplash is an image provider that selects images from Unsplash.com
+type Unsplash struct {
+	Config Config
+	apiURL string // Base URL of the Unsplash API, defaults to https://api.unsplash.com
+}
+
+// SetConfig sets the configuration for this provider
+func (p *Unsplash) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *Unsplash) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Downloading images from Unsplash.")
+
+	l := []DisplayImage{}
+	ud, err := p.getPhotos()
+	if err == nil {
+		l, err = p.downloadImages(ud)
+	}
+
+	if err != nil {
+		p.LogError("Error getting images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastunsplash.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// getPhotos gets the list of photos from the Unsplash API for the configured mode
+func (p *Unsplash) getPhotos() ([]unsplashPhoto, error) {
+	if p.Config.UnsplashKey == "" {
+		return nil, fmt.Errorf("The Unsplash access key has not been configured")
+	}
+
+	v := url.Values{}
+	v.Set("orientation", p.Config.GetOrientation())
+	q := strings.TrimSpace(p.Config.UnsplashQuery)
+	path := ""
+	switch p.Config.UnsplashMode {
+	case 0: // Random
+		path = "/photos/random"
+		v.Set("count", fmt.Sprintf("%d", p.Config.ImgCount))
+	case 1: // Collection
+		if q == "" {
+			return nil, fmt.Errorf("No Unsplash collection has been configured")
+		}
+		path = "/photos/random"
+		v.Set("count", fmt.Sprintf("%d", p.Config.ImgCount))
+		v.Set("collections", q)
+	case 2: // Topic
+		if q == "" {
+			return nil, fmt.Errorf("No Unsplash topic has been configured")
+		}
+		path = "/photos/random"
+		v.Set("count", fmt.Sprintf("%d", p.Config.ImgCount))
+		v.Set("topics", q)
+	case 3: // Search
+		if q == "" {
+			return nil, fmt.Errorf("No Unsplash search query has been configured")
+		}
+		path = "/search/photos"
+		v.Set("per_page", fmt.Sprintf("%d", p.Config.ImgCount))
+		v.Set("query", q)
+	default:
+		return nil, fmt.Errorf("Unsplash mode '%d' is invalid", p.Config.UnsplashMode)
+	}
+
+	b, err := p.callAPI(p.getAPIURL() + path + "?" + v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if p.Config.UnsplashMode == 3 {
+		sd := unsplashSearch{}
+		err = json.Unmarshal(b, &sd)
+		return sd.Results, err
+	}
+	ud := []unsplashPhoto{}
+	err = json.Unmarshal(b, &ud)
+	return ud, err
+}
+
+func (p *Unsplash) downloadImages(ud []unsplashPhoto) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/unsplash"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	xRes, yRes := p.Config.GetResolution()
+
+	r := GetRatings()
+	il := []DisplayImage{}
+	dl := []DownloadJob{}
+	pl := []unsplashPhoto{}
+	for _, i := range ud {
+		// Check if the file already exists
+		fn := i.ID + ".jpg"
+		fp := filepath.Join(path, fn)
+		if r.IsBanned(fp) {
+			p.LogInfo("Skipping banned image '", fn, "'")
+			continue
+		}
+		if _, err := os.Stat(fp); os.IsNotExist(err) {
+			u, err := p.getImageURL(i, xRes, yRes)
+			if err != nil {
+				p.LogError("Failed to download image '"+fn+"'. ", err.Error())
+				continue
+			}
+			p.LogInfo("Downloading ", fp)
+			dl = append(dl, DownloadJob{URL: u, Path: fp, Width: xRes, Height: yRes})
+			pl = append(pl, i)
+		}
+		il = append(il, DisplayImage{
+			Name:      fn,
+			Copyright: p.getAttribution(i),
+			ImagePath: fp,
+		})
+	}
+
+	// Download the missing images
+	failed := map[string]bool{}
+	for n, err := range NewDownloader(p.Config).Download(dl) {
+		if err != nil {
+			p.LogError("Error getting image file from url ", dl[n].URL, ". ", err.Error())
+			failed[dl[n].Path] = true
+			continue
+		}
+		p.trackDownload(pl[n])
+	}
+	for _, i := range il {
+		if !failed[i.ImagePath] {
+			l = append(l, i)
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error ", err.Error())
+				}
+			}
+		}
+	}
+	if err == nil {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastunsplash.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// getImageURL returns the url of the photo, resized by Unsplash to cover the display resolution
+func (p *Unsplash) getImageURL(i unsplashPhoto, xRes int, yRes int) (string, error) {
+	u := i.URLs.Raw
+	if u == "" {
+		u = i.URLs.Regular
+	}
+	if u == "" {
+		return "", fmt.Errorf("No image url for photo '%s'", i.ID)
+	}
+	// The raw url accepts imgix parameters to resize the image to cover the display size.
+	// The image is not cropped, so that it can be fitted to the display using the fit mode.
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	k, v := coverParam(i.Width, i.Height, xRes, yRes)
+	return fmt.Sprintf("%s%sfm=jpg&q=85&%s=%d", u, sep, k, v), nil
+}
+
+// trackDownload tells Unsplash that the photo has been downloaded, as the API guidelines require when a photo is used
+func (p *Unsplash) trackDownload(i unsplashPhoto) {
+	if i.Links.DownloadLocation != "" {
+		if _, err := p.callAPI(i.Links.DownloadLocation); err != nil {
+			p.LogError("Error tracking download of photo '", i.ID, "'. ", err.Error())
+		}
+	}
+}
+
+// callAPI calls the Unsplash API with the access key and returns the response body
+func (p *Unsplash) callAPI(u string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Client-ID "+p.Config.UnsplashKey)
+	req.Header.Add("Accept-Version", "v1")
+	return GetHTTPClient().Fetch(req)
+}
+
+// getAttribution returns the photographer attribution required by Unsplash
+func (p *Unsplash) getAttribution(i unsplashPhoto) string {
+	n := i.User.Name
+	if n == "" {
+		n = i.User.Username
+	}
+	if n == "" {
+		return "Unsplash"
+	}
+	return fmt.Sprintf("Photo by %s on Unsplash", n)
+}
+
+func (p *Unsplash) getAPIURL() string {
+	if p.apiURL != "" {
+		return p.apiURL
+	}
+	return "https://api.unsplash.com"
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *Unsplash) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Unsplash: [Inf] ", a)
+	} else {
+		fmt.Println("Unsplash: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *Unsplash) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Unsplash: [Err] ", a)
+	} else {
+		fmt.Println("Unsplash: [Err] ", a)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func newUnsplashTestServer(t *testing.T, tracked map[string]bool) *httptest.Server {
+	var srv *httptest.Server
+	photos := func(ids ...string) string {
+		s := ""
+		for n, id := range ids {
+			if n > 0 {
+				s += ","
+			}
+			s += fmt.Sprintf(`{"id":"%s","width":3000,"height":4000,"urls":{"raw":"%s/raw/%s?ixid=1"},"links":{"download_location":"%s/photos/%s/download"},"user":{"name":"Photographer %s","username":"user%s"}}`,
+				id, srv.URL, id, srv.URL, id, id, id)
+		}
+		return s
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photos/random", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Client-ID testkey" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		if r.URL.Query().Get("orientation") != "landscape" {
+			t.Error("Expected landscape orientation, got", r.URL.Query().Get("orientation"))
+		}
+		if r.URL.Query().Get("collections") != "" {
+			fmt.Fprintf(w, "[%s]", photos("c1"))
+			return
+		}
+		fmt.Fprintf(w, "[%s]", photos("r1", "r2"))
+	})
+	mux.HandleFunc("/search/photos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") != "mountains" {
+			t.Error("Unexpected search query", r.URL.Query().Get("query"))
+		}
+		fmt.Fprintf(w, `{"total":1,"results":[%s]}`, photos("s1"))
+	})
+	mux.HandleFunc("/raw/", func(w http.ResponseWriter, r *http.Request) {
+		// The photos are taller than the display, so only the width is set to cover it
+		if q := r.URL.Query(); q.Get("w") != "800" || q.Get("h") != "" || q.Get("fit") != "" {
+			t.Error("Image not requested to cover the display resolution", r.URL.RawQuery)
+		}
+		jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	})
+	mux.HandleFunc("/photos/", func(w http.ResponseWriter, r *http.Request) {
+		tracked[r.URL.Path] = true
+		fmt.Fprint(w, `{"url":""}`)
+	})
+	srv = httptest.NewServer(mux)
+	return srv
+}
+
+func TestCanGetUnsplashImages(t *testing.T) {
+	os.RemoveAll("./img/unsplash")
+
+	tracked := map[string]bool{}
+	srv := newUnsplashTestServer(t, tracked)
+	defer srv.Close()
+
+	c := Config{
+		Provider:    5,
+		ImgCount:    2,
+		UnsplashKey: "testkey",
+	}
+	c.SetDefaults()
+
+	i := Unsplash{Config: c, apiURL: srv.URL}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 2 {
+		t.Fatal("Only", len(l), "images returned, expected 2.")
+	}
+	if l[0].Copyright != "Photo by Photographer r1 on Unsplash" {
+		t.Error("Unexpected attribution", l[0].Copyright)
+	}
+	if !tracked["/photos/r1/download"] || !tracked["/photos/r2/download"] {
+		t.Error("Downloads were not tracked.", tracked)
+	}
+}
+
+func TestCanSearchUnsplashImages(t *testing.T) {
+	srv := newUnsplashTestServer(t, map[string]bool{})
+	defer srv.Close()
+
+	c := Config{
+		Provider:      5,
+		ImgCount:      2,
+		UnsplashKey:   "testkey",
+		UnsplashMode:  3,
+		UnsplashQuery: "mountains",
+	}
+	c.SetDefaults()
+
+	i := Unsplash{Config: c, apiURL: srv.URL}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 1 || l[0].Name != "s1.jpg" {
+		t.Error("Unexpected search result", l)
+	}
+}
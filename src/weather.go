@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"time"
 )
 
@@ -35,20 +34,26 @@ type Weather struct {
 		WeatherIcon int       `json:"weatherIcon"`
 		WeatherDesc string    `json:"weatherDesc"`
 	} `json:"forecast"`
+	Hourly []HourlyForecast `json:"hourly"`
+	Alerts []WeatherAlert   `json:"alerts"`
 }
 
 // GetForecast returns the current weather forecast
 func GetForecast(c Config) (Weather, error) {
 	f := Weather{}
-	resp, err := http.Get(fmt.Sprintf("%s/weather/forecast", c.WeatherUrl))
-	if resp != nil {
-		defer resp.Body.Close()
-		resp.Close = true
-	}
+	b, err := GetHTTPClient().Get(fmt.Sprintf("%s/weather/forecast", c.WeatherUrl))
 	if err == nil {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			err = json.Unmarshal(b, &f)
+		err = json.Unmarshal(b, &f)
+	}
+
+	// Not all weather services have an hourly forecast, so get it from Open-Meteo for the location
+	if err == nil && c.HourlyChart && len(f.Hourly) == 0 && (c.Latitude != 0 || c.Longitude != 0) {
+		if h, herr := GetHourlyForecast(c); herr == nil {
+			f.Hourly = h
+		} else if logger != nil {
+			logger.Error("Weather: [Err] ", "Error getting hourly forecast. ", herr.Error())
+		} else {
+			fmt.Println("Weather: [Err] ", "Error getting hourly forecast. ", herr.Error())
 		}
 	}
 
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const webdavStateFile = "webdavstate.json"
+
+const webdavPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:resourcetype/>
+    <d:getetag/>
+    <d:getcontentlength/>
+    <d:getcontenttype/>
+    <d:getlastmodified/>
+  </d:prop>
+</d:propfind>`
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ETag         string `xml:"DAV: getetag"`
+				Length       int64  `xml:"DAV: getcontentlength"`
+				Type         string `xml:"DAV: getcontenttype"`
+				Modified     string `xml:"DAV: getlastmodified"`
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// davFile holds the details about a file in the WebDAV collection
+type davFile struct {
+	URL      string    `json:"url"`
+	Path     string    `json:"path"`
+	ETag     string    `json:"etag"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// WebDAV is an image provider that mirrors the images in a
+// WebDAV collection (e.g. a Nextcloud share) to a local cache folder
+type WebDAV struct {
+	Config Config
+}
+
+// SetConfig sets the configuration for this provider
+func (p *WebDAV) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *WebDAV) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Synchronizing images from WebDAV.")
+
+	l := []DisplayImage{}
+	fl, err := p.listFiles()
+	if err == nil {
+		l, err = p.downloadImages(fl)
+	}
+
+	if err != nil {
+		p.LogError("Error getting Images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastwebdav.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// listFiles returns the images in the configured collection, newest first
+func (p *WebDAV) listFiles() ([]davFile, error) {
+	if p.Config.WebDAVUrl == "" {
+		return nil, fmt.Errorf("The WebDAV url has not been configured")
+	}
+	base, err := url.Parse(strings.TrimSuffix(p.Config.WebDAVUrl, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	fl := []davFile{}
+	todo := []*url.URL{base}
+	done := map[string]bool{}
+	for len(todo) != 0 {
+		u := todo[0]
+		todo = todo[1:]
+		if done[u.Path] {
+			continue
+		}
+		done[u.Path] = true
+
+		ms, err := p.propfind(u)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range ms.Responses {
+			h, err := url.Parse(r.Href)
+			if err != nil {
+				continue
+			}
+			fu := u.ResolveReference(h)
+			if strings.TrimSuffix(fu.Path, "/") == strings.TrimSuffix(u.Path, "/") {
+				// This is the collection itself
+				continue
+			}
+			for _, ps := range r.Propstat {
+				if !strings.Contains(ps.Status, " 200 ") {
+					continue
+				}
+				if ps.Prop.ResourceType.Collection != nil {
+					if p.Config.WebDAVRecursive {
+						if !strings.HasSuffix(fu.Path, "/") {
+							fu.Path = fu.Path + "/"
+						}
+						todo = append(todo, fu)
+					}
+					continue
+				}
+				if !p.isImage(fu.Path, ps.Prop.Type) {
+					continue
+				}
+				f := davFile{
+					URL:  fu.String(),
+					Path: strings.TrimPrefix(fu.Path, base.Path),
+					ETag: ps.Prop.ETag,
+					Size: ps.Prop.Length,
+				}
+				if t, err := http.ParseTime(ps.Prop.Modified); err == nil {
+					f.Modified = t
+				}
+				fl = append(fl, f)
+			}
+		}
+	}
+
+	sort.SliceStable(fl, func(i, j int) bool {
+		return fl[i].Modified.After(fl[j].Modified)
+	})
+	return fl, nil
+}
+
+// propfind lists the members of the collection at the specified url
+func (p *WebDAV) propfind(u *url.URL) (davMultistatus, error) {
+	ms := davMultistatus{}
+	req, err := http.NewRequest("PROPFIND", u.String(), bytes.NewBufferString(webdavPropfind))
+	if err != nil {
+		return ms, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	p.setAuth(req)
+	resp, err := GetHTTPClient().Do(req)
+	if resp != nil {
+		defer resp.Body.Close()
+		resp.Close = true
+	}
+	if err != nil {
+		return ms, err
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return ms, fmt.Errorf("PROPFIND %s returned %s", u.Path, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ms, err
+	}
+	err = xml.Unmarshal(b, &ms)
+	return ms, err
+}
+
+func (p *WebDAV) downloadImages(fl []davFile) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/webdav"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	// Get the ETags of the files already in the cache folder
+	state := map[string]davFile{}
+	if b, err := ioutil.ReadFile(webdavStateFile); err == nil {
+		json.Unmarshal(b, &state)
+	}
+	newState := map[string]davFile{}
+
+	max := int64(p.Config.WebDAVCacheMB) * 1024 * 1024
+	total := int64(0)
+
+	r := GetRatings()
+	d := NewDownloader(p.Config)
+	for len(fl) != 0 && len(l) < p.Config.ImgCount {
+		// Download only as many images as are still needed, then try the next ones if any failed
+		il := []DisplayImage{}
+		dl := []DownloadJob{}
+		for len(fl) != 0 && len(l)+len(il) < p.Config.ImgCount {
+			f := fl[0]
+			fl = fl[1:]
+			fn := p.getImageName(f.Path)
+			fp := filepath.Join(path, fn)
+			if r.IsBanned(fp) {
+				p.LogInfo("Skipping banned image '", fn, "'")
+				continue
+			}
+			if max > 0 && total+f.Size > max {
+				p.LogInfo("Cache size limit reached, skipping ", f.Path)
+				continue
+			}
+			_, err := os.Stat(fp)
+			if os.IsNotExist(err) || f.ETag == "" || state[fn].ETag != f.ETag {
+				p.LogInfo("Downloading ", f.Path)
+				dl = append(dl, DownloadJob{URL: f.URL, Path: fp, Header: p.getAuthHeader()})
+			}
+			total = total + f.Size
+			newState[fn] = f
+			il = append(il, DisplayImage{
+				Name:      fn,
+				ImagePath: fp,
+				Album:     p.getAlbum(f.Path),
+			})
+		}
+		failed := map[string]bool{}
+		for n, err := range d.Download(dl) {
+			if err != nil {
+				p.LogError("Error getting image file from url ", dl[n].URL, ". ", err.Error())
+				failed[dl[n].Path] = true
+			}
+		}
+		for _, i := range il {
+			if failed[i.ImagePath] {
+				// Only keep the ETag of a file that has been downloaded and verified
+				total = total - newState[i.Name].Size
+				delete(newState, i.Name)
+				continue
+			}
+			l = append(l, i)
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			} else if _, ok := newState[f.Name()]; !ok {
+				newState[f.Name()] = state[f.Name()]
+			}
+		}
+	}
+	if err == nil {
+		if b, err := json.Marshal(newState); err == nil {
+			ioutil.WriteFile(webdavStateFile, b, 0666)
+		}
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastwebdav.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+func (p *WebDAV) setAuth(req *http.Request) {
+	if p.Config.WebDAVUser != "" {
+		req.SetBasicAuth(p.Config.WebDAVUser, p.Config.WebDAVPassword)
+	}
+}
+
+// getAuthHeader returns the headers that authenticate a download with the server
+func (p *WebDAV) getAuthHeader() http.Header {
+	req := http.Request{Header: http.Header{}}
+	p.setAuth(&req)
+	return req.Header
+}
+
+// getImageName returns the local file name for the file at the relative path
+func (p *WebDAV) getImageName(rp string) string {
+	if s, err := url.PathUnescape(rp); err == nil {
+		rp = s
+	}
+	return mirrorName(rp)
+}
+
+// mirrorName returns the local file name for the file at the relative path of a mirrored folder.
+// The files in sub folders are flattened, with a hash of the path added so that they cannot
+// collide with a file of the same name at the top level.
+func mirrorName(rp string) string {
+	rp = strings.Trim(rp, "/")
+	if !strings.Contains(rp, "/") {
+		return rp
+	}
+	h := sha1.Sum([]byte(rp))
+	ext := path.Ext(rp)
+	return fmt.Sprintf("%s_%x%s", strings.Replace(strings.TrimSuffix(rp, ext), "/", "_", -1), h[:4], ext)
+}
+
+// getAlbum returns the folder of the file at the relative path
+func (p *WebDAV) getAlbum(rp string) string {
+	if s, err := url.PathUnescape(rp); err == nil {
+		rp = s
+	}
+	if d := path.Dir(strings.Trim(rp, "/")); d != "." {
+		return d
+	}
+	return ""
+}
+
+func (p *WebDAV) isImage(fp string, ct string) bool {
+	switch strings.Split(ct, ";")[0] {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	switch strings.ToLower(path.Ext(fp)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *WebDAV) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("WebDAV: [Inf] ", a)
+	} else {
+		fmt.Println("WebDAV: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *WebDAV) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("WebDAV: [Err] ", a)
+	} else {
+		fmt.Println("WebDAV: [Err] ", a)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+func writeWebDAVImage(t *testing.T, fs webdav.FileSystem, name string, size int) {
+	b := bytes.Buffer{}
+	jpeg.Encode(&b, image.NewRGBA(image.Rect(0, 0, size, size)), nil)
+	f, err := fs.OpenFile(context.Background(), name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(b.Bytes())
+	f.Close()
+}
+
+func TestCanGetWebDAVImages(t *testing.T) {
+	os.RemoveAll("./img/webdav")
+	os.Remove(webdavStateFile)
+
+	ctx := context.Background()
+	fs := webdav.NewMemFS()
+	fs.Mkdir(ctx, "/photos", 0777)
+	fs.Mkdir(ctx, "/photos/2023", 0777)
+	writeWebDAVImage(t, fs, "/photos/beach.jpg", 10)
+	writeWebDAVImage(t, fs, "/photos/2023/hike.jpg", 10)
+	writeWebDAVImage(t, fs, "/photos/2023_hike.jpg", 10)
+	f, _ := fs.OpenFile(ctx, "/photos/notes.txt", os.O_RDWR|os.O_CREATE, 0666)
+	f.Write([]byte("not an image"))
+	f.Close()
+
+	gets := map[string]int{}
+	dav := &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "nana" || p != "secret" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		if r.Method == "GET" {
+			gets[r.URL.Path]++
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c := Config{
+		Provider:        9,
+		ImgCount:        8,
+		WebDAVUrl:       srv.URL + "/photos",
+		WebDAVUser:      "nana",
+		WebDAVPassword:  "secret",
+		WebDAVRecursive: true,
+	}
+	c.SetDefaults()
+
+	i := WebDAV{Config: c}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 3 {
+		t.Fatal(len(l), "images returned, expected 3.")
+	}
+	if _, err := os.Stat("./img/webdav/" + mirrorName("2023/hike.jpg")); err != nil {
+		t.Error("Image in sub collection was not mirrored.", err)
+	}
+	if _, err := os.Stat("./img/webdav/2023_hike.jpg"); err != nil {
+		t.Error("Image with the flattened name was not mirrored.", err)
+	}
+
+	// Only the changed file should be downloaded again
+	writeWebDAVImage(t, fs, "/photos/beach.jpg", 20)
+	l, err = i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 3 {
+		t.Error(len(l), "images returned, expected 3.")
+	}
+	if gets["/photos/beach.jpg"] != 2 {
+		t.Error("Changed image downloaded", gets["/photos/beach.jpg"], "times, expected 2.")
+	}
+	if gets["/photos/2023/hike.jpg"] != 1 {
+		t.Error("Unchanged image downloaded", gets["/photos/2023/hike.jpg"], "times, expected 1.")
+	}
+
+	// Without recursion only the top level images are mirrored
+	i.Config.WebDAVRecursive = false
+	l, err = i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 2 {
+		t.Error("Unexpected images returned", l)
+	}
+
+	// Only the number of images configured are mirrored
+	i.Config.ImgCount = 1
+	l, err = i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 1 {
+		t.Error("Unexpected images returned", l)
+	}
+	if fi, _ := ioutil.ReadDir("./img/webdav"); len(fi) != 1 {
+		t.Error("Expected the other images to be removed, got", len(fi))
+	}
+}
+
+func TestWebDAVSkipsCorruptImages(t *testing.T) {
+	os.RemoveAll("./img/webdav")
+	os.Remove(webdavStateFile)
+	defer os.Remove(webdavStateFile)
+
+	ctx := context.Background()
+	fs := webdav.NewMemFS()
+	fs.Mkdir(ctx, "/photos", 0777)
+	writeWebDAVImage(t, fs, "/photos/beach.jpg", 10)
+	f, _ := fs.OpenFile(ctx, "/photos/broken.jpg", os.O_RDWR|os.O_CREATE, 0666)
+	f.Write([]byte("not an image"))
+	f.Close()
+
+	gets := map[string]int{}
+	dav := &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			gets[r.URL.Path]++
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c := Config{
+		Provider:  9,
+		ImgCount:  8,
+		WebDAVUrl: srv.URL + "/photos",
+	}
+	c.SetDefaults()
+
+	i := WebDAV{Config: c}
+	for n := 0; n < 2; n++ {
+		l, err := i.GetImages()
+		if err != nil {
+			t.Error(err)
+		}
+		if len(l) != 1 || l[0].Name != "beach.jpg" {
+			t.Error("Expected only the valid image, got", l)
+		}
+	}
+	if _, err := os.Stat("./img/webdav/broken.jpg"); !os.IsNotExist(err) {
+		t.Error("Corrupt image written to the cache folder")
+	}
+	// The corrupt file is not recorded as mirrored, so it is tried again
+	b, _ := ioutil.ReadFile(webdavStateFile)
+	if bytes.Contains(b, []byte("broken.jpg")) {
+		t.Error("Corrupt image recorded in the state", string(b))
+	}
+	if gets["/photos/broken.jpg"] != 2 || gets["/photos/beach.jpg"] != 1 {
+		t.Error("Unexpected downloads", gets)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type wikiQuery struct {
+	Query struct {
+		Pages []struct {
+			Title   string `json:"title"`
+			Missing bool   `json:"missing"`
+			Images  []struct {
+				Title string `json:"title"`
+			} `json:"images"`
+			ImageInfo []wikiImageInfo `json:"imageinfo"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+type wikiImageInfo struct {
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Mime        string `json:"mime"`
+	URL         string `json:"url"`
+	ThumbURL    string `json:"thumburl"`
+	ExtMetadata map[string]struct {
+		Value string `json:"value"`
+	} `json:"extmetadata"`
+}
+
+// wikiPotd holds the details about a Picture of the Day
+type wikiPotd struct {
+	Date  time.Time
+	Title string
+	Info  wikiImageInfo
+}
+
+var htmlTagRegex = regexp.MustCompile("<[^>]*>")
+
+// Wikimedia is an image provider that selects images from
+// the Wikimedia Commons Picture of the Day
+type Wikimedia struct {
+	Config Config
+	apiURL string // URL of the MediaWiki API, defaults to https://commons.wikimedia.org/w/api.php
+}
+
+// SetConfig sets the configuration for this provider
+func (p *Wikimedia) SetConfig(c Config) {
+	p.Config = c
+}
+
+// GetImages returns a slice of images to be used for display
+func (p *Wikimedia) GetImages() ([]DisplayImage, error) {
+	p.LogInfo("Downloading images from Wikimedia Commons Picture of the Day.")
+
+	l := []DisplayImage{}
+	pl, err := p.getPotdList()
+	if err == nil {
+		// Download the images
+		l, err = p.downloadImages(pl)
+	}
+
+	if err != nil {
+		p.LogError("Error getting Images. ", err.Error())
+		// Check to see if we already have the last response cached
+		fn := "lastwikimedia.json"
+		if _, err := os.Stat(fn); !os.IsNotExist(err) {
+			// Deserialize the last cached list
+			b, err := ioutil.ReadFile(fn)
+			if err == nil {
+				err = json.Unmarshal(b, &l)
+			}
+		}
+	}
+
+	return l, err
+}
+
+// getPotdList returns the Pictures of the Day for the last days, newest first
+func (p *Wikimedia) getPotdList() ([]wikiPotd, error) {
+	// The API accepts a maximum of 50 titles per query
+	days := p.Config.ImgCount
+	if days > 50 {
+		days = 50
+	}
+
+	// Get the file names from the Potd templates
+	now := time.Now()
+	dates := map[string]time.Time{}
+	tl := []string{}
+	for n := 0; n < days; n++ {
+		d := now.AddDate(0, 0, -n)
+		t := "Template:Potd/" + d.Format("2006-01-02")
+		dates[t] = d
+		tl = append(tl, t)
+	}
+	v := url.Values{}
+	v.Set("prop", "images")
+	v.Set("titles", strings.Join(tl, "|"))
+	tq, err := p.callAPI(v)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	fl := []string{}
+	for _, pg := range tq.Query.Pages {
+		if pg.Missing || len(pg.Images) == 0 {
+			continue
+		}
+		files[pg.Title] = pg.Images[0].Title
+		fl = append(fl, pg.Images[0].Title)
+	}
+	if len(fl) == 0 {
+		return nil, fmt.Errorf("No Pictures of the Day found")
+	}
+
+	// Get the size and metadata of the files
+	v = url.Values{}
+	v.Set("prop", "imageinfo")
+	v.Set("iiprop", "size|mime|extmetadata")
+	v.Set("titles", strings.Join(fl, "|"))
+	fq, err := p.callAPI(v)
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]wikiImageInfo{}
+	for _, pg := range fq.Query.Pages {
+		if len(pg.ImageInfo) != 0 {
+			info[pg.Title] = pg.ImageInfo[0]
+		}
+	}
+
+	pl := []wikiPotd{}
+	for _, t := range tl {
+		f, ok := files[t]
+		if !ok {
+			continue
+		}
+		ii, ok := info[f]
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(ii.Mime, "image/") {
+			p.LogInfo("Skipping ", ii.Mime, " file ", f)
+			continue
+		}
+		pl = append(pl, wikiPotd{
+			Date:  dates[t],
+			Title: f,
+			Info:  ii,
+		})
+	}
+	return pl, nil
+}
+
+func (p *Wikimedia) downloadImages(pl []wikiPotd) ([]DisplayImage, error) {
+	l := []DisplayImage{}
+	path := "./img/wikimedia"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// path does not exist, create it
+		p.LogInfo(fmt.Sprintf("Creating path '%s'", path))
+		err = os.MkdirAll(path, 0666)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	xRes, yRes := p.Config.GetResolution()
+
+	r := GetRatings()
+	il := []DisplayImage{}
+	dl := []DownloadJob{}
+	for _, i := range pl {
+		fn := p.getImageName(i)
+		fp := filepath.Join(path, fn)
+		if r.IsBanned(fp) {
+			p.LogInfo("Skipping banned image '", fn, "'")
+			continue
+		}
+		p.LogInfo("Checking image ", fp)
+		if _, err := os.Stat(fp); os.IsNotExist(err) {
+			u, err := p.getImageURL(i, xRes, yRes)
+			if err != nil {
+				p.LogError("Error getting image url for ", i.Title, ". ", err.Error())
+				continue
+			}
+			p.LogInfo("Downloading ", i.Title, " ", fn)
+			dl = append(dl, DownloadJob{URL: u, Path: fp, Width: xRes, Height: yRes})
+		}
+		il = append(il, DisplayImage{
+			Name:      fn,
+			Copyright: p.getAttribution(i),
+			ImagePath: fp,
+		})
+	}
+
+	// Download the missing images
+	failed := map[string]bool{}
+	for n, err := range NewDownloader(p.Config).Download(dl) {
+		if err != nil {
+			p.LogError("Error getting image file from url ", dl[n].URL, ". ", err.Error())
+			failed[dl[n].Path] = true
+		}
+	}
+	for _, i := range il {
+		// Add the image to the list to return
+		if !failed[i.ImagePath] {
+			l = append(l, i)
+		}
+	}
+
+	// Remove any other file in this folder
+	fi, err := ioutil.ReadDir(path)
+	if err == nil {
+		for _, f := range fi {
+			// Check if this file is in the list or is a favourite
+			remove := !r.IsFavourite(filepath.Join(path, f.Name()))
+			for _, i := range l {
+				if i.Name == f.Name() {
+					remove = false
+					break
+				}
+			}
+			if remove {
+				p.LogInfo("Removing ", f.Name())
+				err = os.Remove(filepath.Join(path, f.Name()))
+				if err != nil {
+					p.LogInfo("Error removing image file ", f.Name(), ". ", err.Error())
+				}
+			}
+		}
+	}
+	if err == nil {
+		b, err := json.Marshal(l)
+		if err != nil {
+			return nil, err
+		}
+		ioutil.WriteFile("lastwikimedia.json", b, 0666)
+	}
+
+	return l, nil
+}
+
+// getImageURL returns the url of a thumbnail of the picture that will fill the display
+func (p *Wikimedia) getImageURL(i wikiPotd, xRes int, yRes int) (string, error) {
+	v := url.Values{}
+	v.Set("prop", "imageinfo")
+	v.Set("iiprop", "url")
+	v.Set("titles", i.Title)
+	if tw := p.getThumbWidth(i.Info.Width, i.Info.Height, xRes, yRes); tw < i.Info.Width {
+		v.Set("iiurlwidth", fmt.Sprintf("%d", tw))
+	}
+	q, err := p.callAPI(v)
+	if err != nil {
+		return "", err
+	}
+	u := ""
+	for _, pg := range q.Query.Pages {
+		if len(pg.ImageInfo) != 0 {
+			u = pg.ImageInfo[0].ThumbURL
+			if u == "" {
+				u = pg.ImageInfo[0].URL
+			}
+		}
+	}
+	if u == "" {
+		return "", fmt.Errorf("No image url found for %s", i.Title)
+	}
+	return u, nil
+}
+
+// callAPI calls the MediaWiki query API with the specified parameters
+func (p *Wikimedia) callAPI(v url.Values) (wikiQuery, error) {
+	q := wikiQuery{}
+	v.Set("action", "query")
+	v.Set("format", "json")
+	v.Set("formatversion", "2")
+
+	// The shared client sends the User-Agent required by the Wikimedia API etiquette
+	b, err := GetHTTPClient().Get(p.getAPIURL() + "?" + v.Encode())
+	if err != nil {
+		return q, err
+	}
+	err = json.Unmarshal(b, &q)
+	return q, err
+}
+
+// getThumbWidth returns the width of the smallest thumbnail that will fill the display
+func (p *Wikimedia) getThumbWidth(w int, h int, xRes int, yRes int) int {
+	if w <= 0 || h <= 0 {
+		return xRes
+	}
+	s := math.Max(float64(xRes)/float64(w), float64(yRes)/float64(h))
+	return int(math.Ceil(float64(w) * s))
+}
+
+// getImageName returns the file name for the Picture of the Day
+func (p *Wikimedia) getImageName(i wikiPotd) string {
+	ext := strings.ToLower(path.Ext(i.Title))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif":
+	case ".svg":
+		// SVG thumbnails are rendered as PNG
+		ext = ".png"
+	default:
+		ext = ".jpg"
+	}
+	return "potd" + i.Date.Format("20060102") + ext
+}
+
+// getAttribution returns the title, author and licence of the image
+func (p *Wikimedia) getAttribution(i wikiPotd) string {
+	meta := func(n string) string {
+		v := htmlTagRegex.ReplaceAllString(i.Info.ExtMetadata[n].Value, "")
+		return strings.Join(strings.Fields(html.UnescapeString(v)), " ")
+	}
+	t := meta("ObjectName")
+	if t == "" {
+		t = strings.TrimSuffix(strings.TrimPrefix(i.Title, "File:"), path.Ext(i.Title))
+	}
+	a := meta("Artist")
+	if a == "" {
+		a = meta("Credit")
+	}
+	lic := meta("LicenseShortName")
+
+	s := t
+	if a != "" {
+		s = fmt.Sprintf("%s - %s", s, a)
+	}
+	if lic != "" {
+		s = fmt.Sprintf("%s (%s)", s, lic)
+	}
+	return s
+}
+
+func (p *Wikimedia) getAPIURL() string {
+	if p.apiURL != "" {
+		return p.apiURL
+	}
+	return "https://commons.wikimedia.org/w/api.php"
+}
+
+// LogInfo is used to log information messages for this controller.
+func (p *Wikimedia) LogInfo(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Wikimedia: [Inf] ", a)
+	} else {
+		fmt.Println("Wikimedia: [Inf] ", a)
+	}
+}
+
+// LogError is used to log error messages for this controller.
+func (p *Wikimedia) LogError(v ...interface{}) {
+	a := fmt.Sprint(v...)
+	if logger != nil {
+		logger.Info("Wikimedia: [Err] ", a)
+	} else {
+		fmt.Println("Wikimedia: [Err] ", a)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanGetWikimediaImages(t *testing.T) {
+	os.RemoveAll("./img/wikimedia")
+
+	today := "Template:Potd/" + time.Now().Format("2006-01-02")
+	yesterday := "Template:Potd/" + time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	thumbWidths := map[string]string{}
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/upload/") {
+			jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 1000, 480)), nil)
+			return
+		}
+		if r.Header.Get("User-Agent") != httpUserAgent {
+			http.Error(w, "Forbidden", 403)
+			return
+		}
+		q := r.URL.Query()
+		pages := []map[string]interface{}{}
+		for _, t := range strings.Split(q.Get("titles"), "|") {
+			switch {
+			case q.Get("prop") == "images" && t == today:
+				pages = append(pages, map[string]interface{}{"title": t, "images": []map[string]string{{"title": "File:Table Mountain.jpg"}}})
+			case q.Get("prop") == "images" && t == yesterday:
+				pages = append(pages, map[string]interface{}{"title": t, "images": []map[string]string{{"title": "File:Launch.webm"}}})
+			case q.Get("prop") == "images":
+				pages = append(pages, map[string]interface{}{"title": t, "missing": true})
+			case q.Get("iiprop") == "url":
+				thumbWidths[t] = q.Get("iiurlwidth")
+				pages = append(pages, map[string]interface{}{"title": t, "imageinfo": []map[string]string{{
+					"url":      srv.URL + "/upload/full.jpg",
+					"thumburl": srv.URL + "/upload/thumb.jpg",
+				}}})
+			case t == "File:Table Mountain.jpg":
+				pages = append(pages, map[string]interface{}{"title": t, "imageinfo": []map[string]interface{}{{
+					"width":  4000,
+					"height": 2000,
+					"mime":   "image/jpeg",
+					"extmetadata": map[string]interface{}{
+						"ObjectName":       map[string]string{"value": "Table Mountain"},
+						"Artist":           map[string]string{"value": "<a href=\"//commons.wikimedia.org/wiki/User:Jane\">Jane &amp; John</a>"},
+						"LicenseShortName": map[string]string{"value": "CC BY-SA 4.0"},
+					},
+				}}})
+			case t == "File:Launch.webm":
+				pages = append(pages, map[string]interface{}{"title": t, "imageinfo": []map[string]interface{}{{"mime": "video/webm"}}})
+			}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"query": map[string]interface{}{"pages": pages}})
+		fmt.Fprint(w, string(b))
+	}))
+	defer srv.Close()
+
+	c := Config{
+		Provider: 7,
+		ImgCount: 3,
+	}
+	c.SetDefaults()
+
+	i := Wikimedia{Config: c, apiURL: srv.URL + "/w/api.php"}
+	l, err := i.GetImages()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l) != 1 {
+		t.Fatal(len(l), "images returned, expected 1.")
+	}
+	if l[0].Copyright != "Table Mountain - Jane & John (CC BY-SA 4.0)" {
+		t.Error("Unexpected attribution", l[0].Copyright)
+	}
+	if thumbWidths["File:Table Mountain.jpg"] != "960" {
+		t.Error("Unexpected thumbnail width", thumbWidths["File:Table Mountain.jpg"])
+	}
+}